@@ -1,17 +1,23 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	"github.com/manjurulhoque/foodie/backend/docs"
 	"github.com/manjurulhoque/foodie/backend/internal/config"
 	"github.com/manjurulhoque/foodie/backend/internal/db"
+	"github.com/manjurulhoque/foodie/backend/internal/db/migrations"
 	"github.com/manjurulhoque/foodie/backend/internal/handlers"
 	"github.com/manjurulhoque/foodie/backend/internal/middlewares"
-	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
 	"github.com/manjurulhoque/foodie/backend/internal/repositories"
 	"github.com/manjurulhoque/foodie/backend/internal/services"
 	"github.com/manjurulhoque/foodie/backend/pkg/utils"
@@ -19,37 +25,6 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func init() {
-	// load the configuration
-	config.LoadConfig()
-
-	// initialize the database
-	_, err := db.InitializeDB()
-	if err != nil {
-		slog.Error("Failed to initialize database", "error", err.Error())
-		panic(err)
-	}
-
-	err = db.DB.AutoMigrate(
-		&models.User{},
-		&models.Address{},
-		&models.Restaurant{},
-		&models.MenuItem{},
-		&models.Order{},
-		&models.OrderItem{},
-		&models.Category{},
-		&models.Cuisine{},
-		&models.Cart{},
-		&models.CartItem{},
-		&models.WorkingHour{},
-		&models.OrderStatusHistory{},
-	)
-	if err != nil {
-		slog.Error("Error migrating database", "error", err.Error())
-		panic(fmt.Sprintf("Error migrating database: %v", err))
-	}
-}
-
 // @title Foodie API
 // @version 1.0
 // @description This is a sample server.
@@ -66,66 +41,134 @@ func init() {
 // @BasePath /api
 // @schemes http
 func main() {
+	// load the configuration from the config file, environment and flags
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// initialize the database
+	database, err := db.Connect(cfg.DB)
+	if err != nil {
+		slog.Error("Failed to initialize database", "error", err.Error())
+		os.Exit(1)
+	}
+	defer db.CloseDB(database)
+
+	// refuse to run against a schema this build does not expect
+	migrator, err := migrations.New(database)
+	if err != nil {
+		slog.Error("Error loading migrations", "error", err.Error())
+		panic(err)
+	}
+	if err := migrator.Check(); err != nil {
+		slog.Error("Database schema is not up to date, run `go run ./cmd/migrate up`", "error", err.Error())
+		panic(err)
+	}
+
 	// create a new gin server and run it
 	router := gin.Default()
-	router.Use(middlewares.CORSMiddleware())
-	defer db.CloseDB(db.DB)
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		slog.Error("Invalid trusted proxies", "error", err.Error())
+		panic(err)
+	}
+	router.Use(middlewares.CORSMiddleware(cfg.CORS))
 
 	// Initialize repositories with pointer receivers
-	userRepo := repositories.NewUserRepository(db.DB)
-	restaurantRepo := repositories.NewRestaurantRepository(db.DB)
-	menuRepo := repositories.NewMenuRepository(db.DB)
-	orderRepo := repositories.NewOrderRepository(db.DB)
-	categoryRepo := repositories.NewCategoryRepository(db.DB)
-	cuisineRepo := repositories.NewCuisineRepository(db.DB)
-	cartRepo := repositories.NewCartRepository(db.DB)
-	customerRepo := repositories.NewCustomerRepository(db.DB)
-	addressRepo := repositories.NewAddressRepository(db.DB)
+	userRepo := repositories.NewUserRepository(database)
+	restaurantRepo := repositories.NewRestaurantRepository(database)
+	menuRepo := repositories.NewMenuRepository(database)
+	orderRepo := repositories.NewOrderRepository(database)
+	categoryRepo := repositories.NewCategoryRepository(database)
+	cuisineRepo := repositories.NewCuisineRepository(database)
+	cartRepo := repositories.NewCartRepository(database)
+	customerRepo := repositories.NewCustomerRepository(database)
+	addressRepo := repositories.NewAddressRepository(database)
+	membershipRepo := repositories.NewMembershipRepository(database)
+	tokenRepo := repositories.NewTokenRepository(database)
+	loginFailureRepo := repositories.NewLoginFailureRepository(database)
+	twoFactorRepo := repositories.NewTwoFactorRepository(database)
+	identityRepo := repositories.NewIdentityRepository(database)
+	paymentRepo := repositories.NewPaymentRepository(database)
+	refundRepo := repositories.NewRefundRepository(database)
+	idempotencyRepo := repositories.NewIdempotencyRepository(database)
+	promotionRepo := repositories.NewPromotionRepository(database)
+
+	jwtKeys, err := services.NewKeySet(cfg.JWT)
+	if err != nil {
+		slog.Error("Failed to load JWT keys", "error", err.Error())
+		panic(err)
+	}
+
+	mailer, err := services.NewMailer(cfg.Mail)
+	if err != nil {
+		slog.Error("Failed to configure mailer", "error", err.Error())
+		panic(err)
+	}
+	passwordHasher := services.NewPasswordHasher(cfg.Security.PasswordHashCost)
 
 	// Initialize services with pointer receivers
-	userService := services.NewUserService(userRepo)
+	loginGuard := services.NewLoginGuard(services.NewMemoryAttemptStore(), loginFailureRepo, cfg.LoginProtection)
+	twoFactorService := services.NewTwoFactorService(twoFactorRepo, cfg.TwoFactor)
+	userService := services.NewUserService(userRepo, tokenRepo, jwtKeys, loginGuard, twoFactorService, passwordHasher)
 	restaurantService := services.NewRestaurantService(restaurantRepo)
 	menuService := services.NewMenuService(menuRepo)
-	orderService := services.NewOrderService(orderRepo, menuRepo)
+	notifier := services.NewLogNotifier()
+	pricingService := services.NewPricingService(menuRepo, cfg.Pricing)
+	orderService := services.NewOrderService(orderRepo, menuRepo, cartRepo, restaurantRepo, promotionRepo, pricingService, notifier)
 	categoryService := services.NewCategoryService(categoryRepo)
 	cuisineService := services.NewCuisineService(cuisineRepo)
-	cartService := services.NewCartService(cartRepo)
+	cartService := services.NewCartService(cartRepo, menuRepo, promotionRepo, restaurantRepo, pricingService)
 	customerService := services.NewCustomerService(customerRepo)
 	addressService := services.NewAddressService(addressRepo)
+	membershipService := services.NewMembershipService(membershipRepo, userRepo, restaurantRepo, notifier, cfg.BaseURL)
+	verificationService := services.NewEmailVerificationService(userRepo, jwtKeys, mailer, cfg.Verification)
+	passwordResetService := services.NewPasswordResetService(userRepo, tokenRepo, mailer, passwordHasher, cfg.PasswordReset)
+	paymentService := services.NewPaymentService(paymentRepo, refundRepo, orderRepo, services.NewPaymentProviders(cfg.Payments))
+	promotionService := services.NewPromotionService(promotionRepo, restaurantRepo)
+	idempotencyService := services.NewIdempotencyService(idempotencyRepo, cfg.Idempotency)
+	oidcService := services.NewOIDCService(services.NewOIDCProviders(cfg.OIDCProviders), identityRepo, userRepo, userService, passwordHasher)
 
 	// Initialize handlers with pointer receivers
-	userHandler := handlers.NewUserHandler(userService, db.DB)
-	restaurantHandler := handlers.NewRestaurantHandler(restaurantService, db.DB)
-	menuHandler := handlers.NewMenuHandler(menuService, db.DB)
-	orderHandler := handlers.NewOrderHandler(orderService, cartService, db.DB)
-	categoryHandler := handlers.NewCategoryHandler(categoryService, db.DB)
-	cuisineHandler := handlers.NewCuisineHandler(cuisineService, db.DB)
-	cartHandler := handlers.NewCartHandler(cartService, db.DB)
-	customerHandler := handlers.NewCustomerHandler(customerService, db.DB)
+	userHandler := handlers.NewUserHandler(userService, verificationService, passwordResetService, database)
+	restaurantHandler := handlers.NewRestaurantHandler(restaurantService, database, cfg.Server.UploadDir)
+	menuHandler := handlers.NewMenuHandler(menuService, database, cfg.Server.UploadDir)
+	orderHandler := handlers.NewOrderHandler(orderService, paymentService, database)
+	categoryHandler := handlers.NewCategoryHandler(categoryService, database)
+	cuisineHandler := handlers.NewCuisineHandler(cuisineService, database)
+	cartHandler := handlers.NewCartHandler(cartService, database)
+	customerHandler := handlers.NewCustomerHandler(customerService, database)
 	addressHandler := handlers.NewAddressHandler(addressService)
-	ownerHandler := handlers.NewOwnerHandler(restaurantService, orderService, db.DB)
-
-	// CORS configuration - using a single config instance
-	//corsConfig := cors.Config{
-	//	AllowOrigins:     []string{"http://localhost:3000", "http://foodie-backend.manjurulhoque.com", "http://foodie.manjurulhoque.com"},
-	//	AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-	//	AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-	//	ExposeHeaders:    []string{"Content-Length"},
-	//	AllowCredentials: true,
-	//	MaxAge:           12 * time.Hour,
-	//}
+	ownerHandler := handlers.NewOwnerHandler(restaurantService, orderService, paymentService, database)
+	membershipHandler := handlers.NewMembershipHandler(membershipService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService)
+	adminUserHandler := handlers.NewAdminUserHandler(userService, orderService, addressService, loginGuard)
+	adminHandler := handlers.NewAdminHandler(database)
+	paymentHandler := handlers.NewPaymentHandler(paymentService, orderService)
+	promotionHandler := handlers.NewPromotionHandler(promotionService)
+	healthHandler := handlers.NewHealthHandler(database, migrator, cfg.Server.UploadDir)
 
 	docs.SwaggerInfo.BasePath = "/api"
-	docs.SwaggerInfo.Host = "localhost:9000"
+	docs.SwaggerInfo.Host = cfg.Server.PublicHost
 	docs.SwaggerInfo.Title = "Foodie API"
 	docs.SwaggerInfo.Description = "Foodie API"
 
 	// Group API routes for better organization and middleware reuse
 	api := router.Group("/api")
-	authMiddleware := middlewares.AuthMiddleware(userRepo, userService)
+	authMiddleware := middlewares.AuthMiddleware(userRepo, userService, twoFactorService)
 	adminMiddleware := middlewares.AdminMiddleware(userRepo, userService)
 	ownerMiddleware := middlewares.OwnerMiddleware(userRepo, userService)
-	adminOrOwnerMiddleware := middlewares.AdminOrOwnerMiddleware(userRepo, userService)
+	staffMiddleware := middlewares.StaffMiddleware(userRepo, userService)
+	verifiedEmailMiddleware := middlewares.VerifiedEmailMiddleware(cfg.Verification.RequiredForCheckout)
+	idempotent := middlewares.IdempotencyMiddleware(idempotencyService)
+	canUpdateRestaurant := middlewares.PolicyMiddleware(policy.ActionUpdate, middlewares.RestaurantLoader(restaurantRepo))
+	canDeleteRestaurant := middlewares.PolicyMiddleware(policy.ActionDelete, middlewares.RestaurantLoader(restaurantRepo))
+	canManageStaff := middlewares.PolicyMiddleware(policy.ActionManageStaff, middlewares.RestaurantLoader(restaurantRepo))
+	canViewOrder := middlewares.PolicyMiddleware(policy.ActionView, middlewares.OrderLoader(orderRepo))
+	canPayOrder := middlewares.PolicyMiddleware(policy.ActionPay, middlewares.OrderLoader(orderRepo))
+	canManagePayment := middlewares.PolicyMiddleware(policy.ActionManagePayment, middlewares.OrderLoader(orderRepo))
 	{
 		api.GET("/ping", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
@@ -136,13 +179,39 @@ func main() {
 		// Auth routes
 		api.POST("/register", userHandler.Register)
 		api.POST("/login", userHandler.Login)
+		api.POST("/login/2fa", userHandler.LoginTwoFactor)
+		api.POST("/token/refresh", userHandler.RefreshToken)
+		api.POST("/logout", authMiddleware, userHandler.Logout)
+		api.GET("/verify-email", userHandler.VerifyEmail)
+		api.POST("/verify-email", userHandler.VerifyEmail)
+		api.POST("/verify-email/resend", authMiddleware, userHandler.ResendVerification)
 		api.GET("/me", authMiddleware, userHandler.Me)
 		api.PUT("/me", authMiddleware, userHandler.UpdateUser)
+		api.PUT("/me/password", authMiddleware, userHandler.ChangePassword)
+		api.POST("/password/forgot", userHandler.ForgotPassword)
+		api.POST("/password/reset", userHandler.ResetPassword)
+
+		// OpenID Connect login routes
+		api.GET("/auth/oidc/providers", oidcHandler.GetProviders)
+		api.GET("/auth/oidc/:provider/login", oidcHandler.BeginLogin)
+		api.POST("/auth/oidc/:provider/callback", oidcHandler.Callback)
+
+		// Two-factor authentication routes
+		twoFactor := api.Group("/2fa")
+		{
+			twoFactor.Use(authMiddleware)
+			twoFactor.GET("", twoFactorHandler.GetStatus)
+			twoFactor.POST("/setup", twoFactorHandler.BeginSetup)
+			twoFactor.POST("/confirm", twoFactorHandler.ConfirmSetup)
+			twoFactor.POST("/disable", twoFactorHandler.Disable)
+			twoFactor.POST("/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+		}
+
 		// Menu routes
 		menu := api.Group("/menu")
 		{
 			menu.GET("", menuHandler.GetAllMenuItems)
-			menu.GET("/:id", menuHandler.GetMenuItem)
+			menu.GET("/:itemId", menuHandler.GetMenuItem)
 		}
 
 		// Restaurant routes
@@ -152,23 +221,29 @@ func main() {
 			restaurants.GET("/:id", restaurantHandler.GetRestaurant)
 
 			restaurants.POST("", authMiddleware, restaurantHandler.CreateRestaurant)
-			restaurants.PUT("/:id", authMiddleware, restaurantHandler.UpdateRestaurant)
+			restaurants.PUT("/:id", authMiddleware, canUpdateRestaurant, restaurantHandler.UpdateRestaurant)
 			restaurants.PUT("/:id/owner", authMiddleware, adminMiddleware, restaurantHandler.UpdateRestaurantOwner)
-			restaurants.DELETE("/:id", authMiddleware, restaurantHandler.DeleteRestaurant)
+			restaurants.DELETE("/:id", authMiddleware, canDeleteRestaurant, restaurantHandler.DeleteRestaurant)
 
 			restaurantWorkingHours := restaurants.Group("/:id/working-hours")
 			{
-				restaurantWorkingHours.PUT("", authMiddleware, restaurantHandler.UpdateWorkingHours)
+				restaurantWorkingHours.PUT("", authMiddleware, canUpdateRestaurant, restaurantHandler.UpdateWorkingHours)
 			}
 
 			restaurantMenu := restaurants.Group("/:id/menu")
 			{
 				restaurantMenu.GET("", menuHandler.GetRestaurantMenuItems)
-				restaurantMenu.POST("", authMiddleware, adminOrOwnerMiddleware, menuHandler.CreateMenuItem)
-				restaurantMenu.PUT("/:id", authMiddleware, adminOrOwnerMiddleware, menuHandler.UpdateMenuItem)
-				restaurantMenu.GET("/:id", menuHandler.GetMenuItem)
+				restaurantMenu.POST("", authMiddleware, canUpdateRestaurant, menuHandler.CreateMenuItem)
+				restaurantMenu.PUT("/:itemId", authMiddleware, canUpdateRestaurant, menuHandler.UpdateMenuItem)
+				restaurantMenu.PUT("/:itemId/modifiers", authMiddleware, canUpdateRestaurant, menuHandler.SetModifierGroups)
+				restaurantMenu.GET("/:itemId", menuHandler.GetMenuItem)
 			}
 
+			restaurants.GET("/:id/members", authMiddleware, canManageStaff, membershipHandler.GetMembers)
+			restaurants.DELETE("/:id/members/:memberId", authMiddleware, canManageStaff, membershipHandler.RemoveMember)
+			restaurants.GET("/:id/invitations", authMiddleware, canManageStaff, membershipHandler.GetInvitations)
+			restaurants.POST("/:id/invitations", authMiddleware, canManageStaff, membershipHandler.InviteMember)
+
 			restaurantCuisine := restaurants.Group("/cuisine/:id")
 			{
 				restaurantCuisine.GET("", restaurantHandler.GetRestaurantsByCuisine)
@@ -197,22 +272,35 @@ func main() {
 		// Cart routes
 		cart := api.Group("/cart")
 		{
-			cart.Use(authMiddleware)
+			cart.Use(authMiddleware, idempotent)
 			cart.GET("", cartHandler.GetCart)
 			cart.POST("/items", cartHandler.AddToCart)
 			cart.PUT("/items/:id", cartHandler.UpdateCartItem)
 			cart.DELETE("/items/:id", cartHandler.RemoveFromCart)
 			cart.DELETE("", cartHandler.ClearCart)
+			cart.POST("/promo", cartHandler.ApplyPromotion)
+			cart.DELETE("/promo", cartHandler.RemovePromotion)
 		}
 
 		// Order routes
 		orders := api.Group("/orders")
 		{
-			orders.POST("", authMiddleware, orderHandler.CreateOrder)
+			orders.POST("", authMiddleware, verifiedEmailMiddleware, idempotent, orderHandler.CreateOrder)
 			orders.GET("/user", authMiddleware, orderHandler.GetUserOrders)
-			orders.GET("/:id/status-history", authMiddleware, orderHandler.GetOrderStatusHistory)
+			orders.GET("/:id/status-history", authMiddleware, canViewOrder, orderHandler.GetOrderStatusHistory)
+			orders.POST("/:id/cancel", authMiddleware, orderHandler.CancelOrder)
+			orders.GET("/:id/payments", authMiddleware, canViewOrder, paymentHandler.GetOrderPayments)
+			orders.POST("/:id/payments", authMiddleware, canPayOrder, idempotent, paymentHandler.StartPayment)
+			orders.POST("/:id/payments/capture", authMiddleware, canManagePayment, idempotent, paymentHandler.CapturePayment)
+			orders.GET("/:id/refunds", authMiddleware, canViewOrder, paymentHandler.GetOrderRefunds)
 		}
 
+		// Payment routes; webhooks are authenticated by the provider's signature
+		api.GET("/payments/methods", paymentHandler.GetMethods)
+		api.POST("/payments/webhooks/:provider", paymentHandler.Webhook)
+
+		api.POST("/invitations/accept", authMiddleware, membershipHandler.AcceptInvitation)
+
 		// Customer routes
 		customers := api.Group("/customers")
 		{
@@ -241,27 +329,98 @@ func main() {
 			owner.GET("/restaurants", authMiddleware, ownerMiddleware, ownerHandler.GetRestaurants)
 			owner.GET("/orders", authMiddleware, ownerMiddleware, ownerHandler.GetAllOrders)
 			owner.PUT("/orders/:id", authMiddleware, ownerMiddleware, ownerHandler.UpdateOrderStatus)
+			owner.POST("/orders/:id/refunds", authMiddleware, ownerMiddleware, canManagePayment, idempotent, paymentHandler.RefundOrder)
+			owner.GET("/promotions", authMiddleware, ownerMiddleware, promotionHandler.GetPromotions)
+			owner.POST("/promotions", authMiddleware, ownerMiddleware, promotionHandler.CreatePromotion)
+			owner.PUT("/promotions/:id", authMiddleware, ownerMiddleware, promotionHandler.UpdatePromotion)
+			owner.DELETE("/promotions/:id", authMiddleware, ownerMiddleware, promotionHandler.DeletePromotion)
+		}
+
+		// Staff routes, limited to order handling at the restaurants they work at
+		staff := api.Group("/staff")
+		{
+			staff.GET("/restaurants", authMiddleware, staffMiddleware, membershipHandler.GetMyMemberships)
+			staff.GET("/orders", authMiddleware, staffMiddleware, ownerHandler.GetAllOrders)
+			staff.PUT("/orders/:id", authMiddleware, staffMiddleware, ownerHandler.UpdateOrderStatus)
 		}
 	}
 
 	// Setup admin routes
 	adminRoutes := router.Group("/api/admin")
 	{
-		adminRoutes.GET("/overview", authMiddleware, adminMiddleware, handlers.GetAdminOverview)
-		adminRoutes.GET("/analytics", authMiddleware, adminMiddleware, handlers.GetAdminAnalytics)
-		adminRoutes.GET("/reports", authMiddleware, adminMiddleware, handlers.GetAdminReports)
+		adminRoutes.GET("/overview", authMiddleware, adminMiddleware, adminHandler.GetAdminOverview)
+		adminRoutes.GET("/analytics", authMiddleware, adminMiddleware, adminHandler.GetAdminAnalytics)
+		adminRoutes.GET("/reports", authMiddleware, adminMiddleware, adminHandler.GetAdminReports)
+		adminRoutes.POST("/orders/:id/refunds", authMiddleware, adminMiddleware, canManagePayment, idempotent, paymentHandler.RefundOrder)
+		adminRoutes.GET("/promotions", authMiddleware, adminMiddleware, promotionHandler.GetPromotions)
+		adminRoutes.POST("/promotions", authMiddleware, adminMiddleware, promotionHandler.CreatePromotion)
+		adminRoutes.PUT("/promotions/:id", authMiddleware, adminMiddleware, promotionHandler.UpdatePromotion)
+		adminRoutes.DELETE("/promotions/:id", authMiddleware, adminMiddleware, promotionHandler.DeletePromotion)
+		adminRoutes.POST("/users/:id/unlock", authMiddleware, adminMiddleware, adminUserHandler.UnlockUser)
+		adminRoutes.GET("/users/:id/login-failures", authMiddleware, adminMiddleware, adminUserHandler.GetLoginFailures)
+		adminRoutes.POST("/users/:id/deactivate", authMiddleware, adminMiddleware, adminUserHandler.DeactivateUser)
+		adminRoutes.POST("/users/:id/reactivate", authMiddleware, adminMiddleware, adminUserHandler.ReactivateUser)
+		adminRoutes.PUT("/users/:id/role", authMiddleware, adminMiddleware, adminUserHandler.ChangeUserRole)
+		adminRoutes.POST("/users/:id/logout", authMiddleware, adminMiddleware, adminUserHandler.LogoutUser)
+		adminRoutes.GET("/users/:id/orders", authMiddleware, adminMiddleware, adminUserHandler.GetUserOrders)
+		adminRoutes.GET("/users/:id/addresses", authMiddleware, adminMiddleware, adminUserHandler.GetUserAddresses)
 	}
 
+	// Public keys for services that verify our tokens
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, jwtKeys.JWKS())
+	})
+
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	router.Static("/web/uploads", "./web/uploads")
+	if err := os.MkdirAll(cfg.Server.UploadDir, 0755); err != nil {
+		slog.Error("Failed to create upload directory", "dir", cfg.Server.UploadDir, "error", err.Error())
+		panic(err)
+	}
+	router.Static("/web/uploads", cfg.Server.UploadDir)
+
+	// Probes for the orchestrator, outside /api so they skip API middleware and logs stay quiet
+	router.GET("/healthz", healthHandler.Healthz)
+	router.GET("/readyz", healthHandler.Readyz)
 
 	// Set the user repository in the utils package
 	utils.SetUserRepo(userRepo)
 
-	// run the server
-	if err := router.Run(":9000"); err != nil {
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	// run the server until SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server listening", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
 		slog.Error("Failed to start server", "error", err.Error())
 		panic(err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// stop accepting connections and let in-flight requests finish; the deferred
+	// CloseDB runs once they have
+	slog.Info("Shutting down server", "timeout", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server did not shut down cleanly", "error", err.Error())
+		return
 	}
+	slog.Info("Server stopped")
 }
@@ -0,0 +1,139 @@
+// Command migrate manages the database schema.
+//
+//	go run ./cmd/migrate up             apply all pending migrations
+//	go run ./cmd/migrate down [n]       roll back the latest n migrations, 1 by default
+//	go run ./cmd/migrate status         list migrations and when they were applied
+//	go run ./cmd/migrate create <name>  add empty up and down files to internal/db/migrations/sql
+//	go run ./cmd/migrate force <v>      mark migrations up to v as applied without running them
+//
+// A database created before migrations existed already has the tables of
+// 0001_initial; adopt it with "force 1" and then run "up".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/db"
+	"github.com/manjurulhoque/foodie/backend/internal/db/migrations"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate [-dir dir] [config flags] up | down [n] | status | create <name> | force <version>")
+	os.Exit(2)
+}
+
+func main() {
+	dir := flag.String("dir", "internal/db/migrations/sql", "where create writes new migrations")
+	flag.Usage = usage
+	// the configuration flags, such as -db-driver, are accepted alongside -dir
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+	}
+
+	// create only writes files and needs no database
+	if args[0] == "create" {
+		if len(args) != 2 {
+			usage()
+		}
+		paths, err := migrations.Create(*dir, args[1])
+		if err != nil {
+			slog.Error("Error creating migration", "error", err.Error())
+			os.Exit(1)
+		}
+		for _, path := range paths {
+			fmt.Println("Created", path)
+		}
+		return
+	}
+
+	switch args[0] {
+	case "up", "down", "status", "force":
+	default:
+		usage()
+	}
+
+	// initialize the database
+	database, err := db.Connect(cfg.DB)
+	if err != nil {
+		slog.Error("Failed to initialize database", "error", err.Error())
+		os.Exit(1)
+	}
+	defer db.CloseDB(database)
+
+	migrator, err := migrations.New(database)
+	if err != nil {
+		slog.Error("Error loading migrations", "error", err.Error())
+		os.Exit(1)
+	}
+	if err := run(migrator, args); err != nil {
+		slog.Error("Migration failed", "command", args[0], "error", err.Error())
+		db.CloseDB(database)
+		os.Exit(1)
+	}
+}
+
+func run(migrator *migrations.Migrator, args []string) error {
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up()
+		for _, migration := range done {
+			fmt.Println("Applied", migration)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		done, err := migrator.Down(steps)
+		for _, migration := range done {
+			fmt.Println("Rolled back", migration)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-40s %s\n", status.String(), applied)
+		}
+		return nil
+	case "force":
+		if len(args) != 2 {
+			usage()
+		}
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := migrator.Force(uint(version)); err != nil {
+			return err
+		}
+		fmt.Println("Marked migrations up to", version, "as applied")
+		return nil
+	}
+	return nil
+}
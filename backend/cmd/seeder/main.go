@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log/slog"
+	"os"
 
 	"github.com/manjurulhoque/foodie/backend/internal/config"
 	"github.com/manjurulhoque/foodie/backend/internal/db"
@@ -11,18 +13,22 @@ import (
 
 func main() {
 	// load the configuration
-	config.LoadConfig()
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	// initialize the database
-	_, err := db.InitializeDB()
+	database, err := db.Connect(cfg.DB)
 	if err != nil {
 		slog.Error("Failed to initialize database", "error", err.Error())
 		panic(err)
 	}
-	defer db.CloseDB(db.DB)
+	defer db.CloseDB(database)
 
 	// Run seeders
-	if err := seeders.Seed(db.DB); err != nil {
+	if err := seeders.Seed(database); err != nil {
 		slog.Error("Error seeding database", "error", err.Error())
 		panic(err)
 	}
@@ -1,37 +1,353 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"github.com/joho/godotenv"
-	"os"
+	"net"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
-// LoadConfig initializes environment variables
-func LoadConfig() {
-	err := godotenv.Load()
-	if err != nil {
-		fmt.Println("Error loading .env file. Using environment variables.")
-	}
+// Config is the whole application configuration. Every setting has a key in the
+// config file (its yaml tags joined by dots), an environment variable (env tag) and a
+// command line flag named after the variable, e.g. DB_DRIVER and -db-driver. See Load
+// for the order in which they apply.
+type Config struct {
+	// BaseURL is the frontend address used in links sent by email and OIDC redirects
+	BaseURL         string                `yaml:"base_url" env:"APP_BASE_URL"`
+	Server          ServerConfig          `yaml:"server"`
+	CORS            CORSConfig            `yaml:"cors"`
+	Security        SecurityConfig        `yaml:"security"`
+	DB              DBConfig              `yaml:"db"`
+	JWT             JWTConfig             `yaml:"jwt"`
+	Mail            MailConfig            `yaml:"mail"`
+	Verification    VerificationConfig    `yaml:"verification"`
+	PasswordReset   PasswordResetConfig   `yaml:"password_reset"`
+	LoginProtection LoginProtectionConfig `yaml:"login_protection"`
+	TwoFactor       TwoFactorConfig       `yaml:"two_factor"`
+	Pricing         PricingConfig         `yaml:"pricing"`
+	Payments        PaymentsConfig        `yaml:"payments"`
+	Idempotency     IdempotencyConfig     `yaml:"idempotency"`
+	// OIDCProviders come from the oidc_providers list in the config file or, when
+	// OIDC_PROVIDERS is set, from the environment
+	OIDCProviders []OIDCProviderConfig `yaml:"oidc_providers"`
+}
+
+// ServerConfig holds the HTTP server settings
+type ServerConfig struct {
+	Port int `yaml:"port" env:"PORT"`
+	// PublicHost is the host:port shown in the Swagger documentation
+	PublicHost string `yaml:"public_host" env:"SWAGGER_HOST"`
+	// UploadDir is where uploaded images are stored. They are served under /web/uploads.
+	UploadDir string `yaml:"upload_dir" env:"UPLOAD_DIR"`
+
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"` // whole request including an uploaded image
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"` // keep-alive connections are closed after this long unused
+	// ShutdownTimeout is how long in-flight requests may take to finish on SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+	// TrustedProxies are the IPs or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For header is believed for the client IP. None by default, so
+	// the client IP is the address of the connection.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+// CORSConfig controls which browser origins may call the API
+type CORSConfig struct {
+	AllowOrigins     []string      `yaml:"allow_origins" env:"CORS_ALLOW_ORIGINS"` // "*" allows any origin
+	AllowMethods     []string      `yaml:"allow_methods" env:"CORS_ALLOW_METHODS"`
+	AllowHeaders     []string      `yaml:"allow_headers" env:"CORS_ALLOW_HEADERS"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE"` // how long browsers may cache a preflight response
+}
+
+// SecurityConfig holds settings that trade security against speed
+type SecurityConfig struct {
+	PasswordHashCost int `yaml:"password_hash_cost" env:"PASSWORD_HASH_COST"` // bcrypt cost for new password hashes
 }
 
 // DBConfig holds the database connection details
 type DBConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
-}
-
-// GetDBConfig initializes the DBConfig structure from environment variables
-func GetDBConfig() DBConfig {
-	return DBConfig{
-		Host:     os.Getenv("DB_HOST"),
-		Port:     os.Getenv("DB_PORT"),
-		User:     os.Getenv("DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-		DBName:   os.Getenv("DB_NAME"),
-		SSLMode:  os.Getenv("DB_SSLMODE"),
+	Driver          string        `yaml:"driver" env:"DB_DRIVER"` // sqlite or postgres
+	DSN             string        `yaml:"dsn" env:"DATABASE_URL"` // full connection string; overrides the fields below when set
+	Path            string        `yaml:"path" env:"DB_PATH"`     // sqlite database file
+	Host            string        `yaml:"host" env:"DB_HOST"`
+	Port            string        `yaml:"port" env:"DB_PORT"`
+	User            string        `yaml:"user" env:"DB_USER"`
+	Password        string        `yaml:"password" env:"DB_PASSWORD"`
+	DBName          string        `yaml:"name" env:"DB_NAME"`
+	SSLMode         string        `yaml:"sslmode" env:"DB_SSLMODE"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+}
+
+// ConnectionString returns the DSN for the configured driver
+func (c DBConfig) ConnectionString() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	if c.Driver == "sqlite" {
+		return c.Path
+	}
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		c.Host, c.User, c.Password, c.DBName, c.Port, c.SSLMode)
+}
+
+// PricingConfig holds the tax and fee settings used to price orders
+type PricingConfig struct {
+	TaxRate     float64 `yaml:"tax_rate" env:"PRICING_TAX_RATE"`         // fraction of the subtotal, e.g. 0.08 for 8%
+	DeliveryFee float64 `yaml:"delivery_fee" env:"PRICING_DELIVERY_FEE"` // flat fee added to every order
+	Tolerance   float64 `yaml:"tolerance" env:"PRICING_TOLERANCE"`       // max allowed difference between client and server totals
+}
+
+// PaymentsConfig selects the payment methods customers can choose at checkout
+type PaymentsConfig struct {
+	// Providers are the enabled methods: cash for cash on delivery and card, which is
+	// the fake card processor meant for development
+	Providers []string `yaml:"providers" env:"PAYMENT_PROVIDERS"`
+	// FakeCardWebhookSecret signs the webhooks of the fake card processor
+	FakeCardWebhookSecret string `yaml:"fake_card_webhook_secret" env:"FAKE_CARD_WEBHOOK_SECRET"`
+}
+
+// IdempotencyConfig controls how requests sent with an Idempotency-Key header are replayed
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_KEY_TTL"` // how long a key's response is kept for replay
+}
+
+// JWTConfig holds the keys used to sign and verify tokens. Keys can be given inline
+// (secret, private_key) or as files (private_key_file, previous_keys as kid=path).
+type JWTConfig struct {
+	Algorithm        string   `yaml:"algorithm" env:"JWT_ALGORITHM"`     // HS256, RS256 or EdDSA
+	KeyID            string   `yaml:"key_id" env:"JWT_KEY_ID"`           // sent as the "kid" header of every new token
+	Secret           string   `yaml:"secret" env:"JWT_SECRET"`           // signing secret for HS256
+	PrivateKey       []byte   `yaml:"private_key" env:"JWT_PRIVATE_KEY"` // PEM encoded signing key for RS256 and EdDSA
+	PrivateKeyFile   string   `yaml:"private_key_file" env:"JWT_PRIVATE_KEY_FILE"`
+	PreviousKeyFiles []string `yaml:"previous_keys" env:"JWT_PREVIOUS_KEYS"` // kid=path entries
+	// PreviousKeys are no longer used for signing but still accepted when verifying,
+	// keyed by kid. Each is a PEM key or, for HS256, a raw secret. Load reads them
+	// from PreviousKeyFiles.
+	PreviousKeys map[string][]byte `yaml:"-"`
+}
+
+// MailConfig selects and configures the mailer
+type MailConfig struct {
+	Driver   string `yaml:"driver" env:"MAIL_DRIVER"` // smtp, file or log
+	Host     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	Port     int    `yaml:"smtp_port" env:"SMTP_PORT"`
+	Username string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	Password string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+	From     string `yaml:"from" env:"MAIL_FROM"`
+	Dir      string `yaml:"dir" env:"MAIL_DIR"` // where the file driver writes messages
+}
+
+// VerificationConfig controls email verification
+type VerificationConfig struct {
+	LinkBaseURL    string        `yaml:"-"`                                                        // frontend URL the verification link points to, from Config.BaseURL
+	TokenTTL       time.Duration `yaml:"token_ttl" env:"EMAIL_VERIFICATION_TTL"`                   // how long a verification link stays valid
+	ResendInterval time.Duration `yaml:"resend_interval" env:"EMAIL_VERIFICATION_RESEND_INTERVAL"` // minimum time between two verification emails
+	// RequiredForCheckout blocks checkout until the user's email is verified
+	RequiredForCheckout bool `yaml:"required_for_checkout" env:"EMAIL_VERIFICATION_REQUIRED_FOR_CHECKOUT"`
+}
+
+// PasswordResetConfig controls forgotten password emails
+type PasswordResetConfig struct {
+	LinkBaseURL string        `yaml:"-"`                                  // frontend URL the reset link points to, from Config.BaseURL
+	TokenTTL    time.Duration `yaml:"token_ttl" env:"PASSWORD_RESET_TTL"` // how long a reset link stays valid
+}
+
+// LoginProtectionConfig controls how failed logins lock an account or IP address
+type LoginProtectionConfig struct {
+	MaxAccountFailures int           `yaml:"max_account_failures" env:"LOGIN_MAX_ACCOUNT_FAILURES"` // failures before an account is locked
+	MaxIPFailures      int           `yaml:"max_ip_failures" env:"LOGIN_MAX_IP_FAILURES"`           // failures before an IP address is locked, across accounts
+	BaseLockout        time.Duration `yaml:"base_lockout" env:"LOGIN_BASE_LOCKOUT"`                 // first lockout, doubled with every further failure
+	MaxLockout         time.Duration `yaml:"max_lockout" env:"LOGIN_MAX_LOCKOUT"`
+	FailureWindow      time.Duration `yaml:"failure_window" env:"LOGIN_FAILURE_WINDOW"` // failures are forgotten after this long without a new one
+}
+
+// TwoFactorConfig controls two-factor authentication
+type TwoFactorConfig struct {
+	Issuer string `yaml:"issuer" env:"TOTP_ISSUER"` // shown in authenticator apps next to the account
+	// RequiredRoles must enable two-factor authentication before they can use the API
+	RequiredRoles []string `yaml:"required_roles" env:"TWO_FACTOR_REQUIRED_ROLES"`
+}
+
+// OIDCProviderConfig holds the client registration at one OpenID provider. In the
+// environment, OIDC_PROVIDERS lists the names, e.g. "google,okta", and each provider
+// is configured with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and
+// _SCOPES.
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer" env:"ISSUER"`
+	ClientID     string   `yaml:"client_id" env:"CLIENT_ID"`
+	ClientSecret string   `yaml:"client_secret" env:"CLIENT_SECRET"`
+	RedirectURL  string   `yaml:"redirect_url" env:"REDIRECT_URL"` // the frontend page the provider sends the user back to
+	Scopes       []string `yaml:"scopes" env:"SCOPES"`
+}
+
+// Default returns the configuration used for anything not set elsewhere
+func Default() Config {
+	return Config{
+		BaseURL: "http://localhost:3000",
+		Server: ServerConfig{
+			Port:       9000,
+			PublicHost: "localhost:9000",
+			UploadDir:  "./web/uploads",
+
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       60 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		CORS: CORSConfig{
+			AllowOrigins:     []string{"*"},
+			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowHeaders:     []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Origin", "Cache-Control", "X-Requested-With", "Idempotency-Key"},
+			AllowCredentials: false,
+			MaxAge:           12 * time.Hour,
+		},
+		Security: SecurityConfig{PasswordHashCost: 14},
+		DB: DBConfig{
+			Driver:       "sqlite",
+			Path:         "./foodie.sqlite3",
+			MaxIdleConns: 2,
+		},
+		JWT: JWTConfig{Algorithm: "HS256", KeyID: "default"},
+		Mail: MailConfig{
+			Driver: "log",
+			Port:   587,
+			From:   "Foodie <no-reply@foodie.local>",
+			Dir:    "./web/mail",
+		},
+		Verification: VerificationConfig{
+			TokenTTL:       24 * time.Hour,
+			ResendInterval: time.Minute,
+		},
+		PasswordReset: PasswordResetConfig{TokenTTL: time.Hour},
+		LoginProtection: LoginProtectionConfig{
+			MaxAccountFailures: 5,
+			MaxIPFailures:      20,
+			BaseLockout:        time.Minute,
+			MaxLockout:         time.Hour,
+			FailureWindow:      15 * time.Minute,
+		},
+		TwoFactor:   TwoFactorConfig{Issuer: "Foodie"},
+		Pricing:     PricingConfig{Tolerance: 0.01},
+		Payments:    PaymentsConfig{Providers: []string{"cash"}},
+		Idempotency: IdempotencyConfig{TTL: 24 * time.Hour},
+	}
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	baseURL, err := url.Parse(c.BaseURL)
+	check(err == nil && baseURL.Scheme != "" && baseURL.Host != "", "base_url", "must be an absolute URL, got %q", c.BaseURL)
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.UploadDir != "", "server.upload_dir", "must be set")
+	check(c.Server.ReadHeaderTimeout > 0, "server.read_header_timeout", "must be positive")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout", "must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout", "must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout", "must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+	for _, proxy := range c.Server.TrustedProxies {
+		_, ipErr := netip.ParseAddr(proxy)
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(ipErr == nil || cidrErr == nil, "server.trusted_proxies", "%q is not an IP address or CIDR range", proxy)
+	}
+
+	for _, origin := range c.CORS.AllowOrigins {
+		if origin == "*" {
+			// any site could make requests with the user's cookies
+			check(!c.CORS.AllowCredentials, "cors.allow_credentials", `cannot be used with the "*" origin, list the allowed origins instead`)
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		check(err == nil && parsed.Scheme != "" && parsed.Host != "", "cors.allow_origins", "%q is not an origin like https://example.com", origin)
+	}
+
+	check(c.Security.PasswordHashCost >= bcrypt.MinCost && c.Security.PasswordHashCost <= bcrypt.MaxCost,
+		"security.password_hash_cost", "must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, c.Security.PasswordHashCost)
+
+	switch c.DB.Driver {
+	case "sqlite":
+		check(c.DB.DSN != "" || c.DB.Path != "", "db.path", "must be set for sqlite")
+	case "postgres":
+		check(c.DB.DSN != "" || (c.DB.Host != "" && c.DB.DBName != ""), "db.host", "db.host and db.name, or db.dsn, must be set for postgres")
+	default:
+		check(false, "db.driver", "must be sqlite or postgres, got %q", c.DB.Driver)
+	}
+	check(c.DB.MaxOpenConns >= 0, "db.max_open_conns", "must not be negative")
+	check(c.DB.MaxIdleConns >= 0, "db.max_idle_conns", "must not be negative")
+
+	switch c.JWT.Algorithm {
+	case "HS256":
+	case "RS256", "EdDSA":
+		check(len(c.JWT.PrivateKey) > 0, "jwt.private_key", "a private key is required for %s", c.JWT.Algorithm)
+	default:
+		check(false, "jwt.algorithm", "must be HS256, RS256 or EdDSA, got %q", c.JWT.Algorithm)
 	}
+	check(c.JWT.KeyID != "", "jwt.key_id", "must be set")
+
+	switch c.Mail.Driver {
+	case "smtp":
+		check(c.Mail.Host != "", "mail.smtp_host", "must be set for the smtp driver")
+	case "file":
+		check(c.Mail.Dir != "", "mail.dir", "must be set for the file driver")
+	case "log":
+	default:
+		check(false, "mail.driver", "must be smtp, file or log, got %q", c.Mail.Driver)
+	}
+
+	check(c.Verification.TokenTTL > 0, "verification.token_ttl", "must be positive")
+	check(c.Verification.ResendInterval >= 0, "verification.resend_interval", "must not be negative")
+	check(c.PasswordReset.TokenTTL > 0, "password_reset.token_ttl", "must be positive")
+
+	lp := c.LoginProtection
+	check(lp.MaxAccountFailures > 0, "login_protection.max_account_failures", "must be positive")
+	check(lp.MaxIPFailures > 0, "login_protection.max_ip_failures", "must be positive")
+	check(lp.BaseLockout > 0, "login_protection.base_lockout", "must be positive")
+	check(lp.MaxLockout >= lp.BaseLockout, "login_protection.max_lockout", "must not be shorter than base_lockout")
+	check(lp.FailureWindow > 0, "login_protection.failure_window", "must be positive")
+
+	check(c.Pricing.TaxRate >= 0 && c.Pricing.TaxRate < 1, "pricing.tax_rate", "must be a fraction between 0 and 1, got %v", c.Pricing.TaxRate)
+	check(c.Pricing.DeliveryFee >= 0, "pricing.delivery_fee", "must not be negative")
+	check(c.Pricing.Tolerance >= 0, "pricing.tolerance", "must not be negative")
+
+	check(len(c.Payments.Providers) > 0, "payments.providers", "at least one payment method must be enabled")
+	for _, provider := range c.Payments.Providers {
+		switch provider {
+		case "cash":
+		case "card":
+			check(c.Payments.FakeCardWebhookSecret != "", "payments.fake_card_webhook_secret", "must be set when card is enabled")
+		default:
+			check(false, "payments.providers", "unknown payment method %q, use cash or card", provider)
+		}
+	}
+
+	check(c.Idempotency.TTL > 0, "idempotency.ttl", "must be positive")
+
+	names := map[string]bool{}
+	for i, provider := range c.OIDCProviders {
+		key := fmt.Sprintf("oidc_providers[%d]", i)
+		check(provider.Name != "", key+".name", "must be set")
+		check(!names[provider.Name], key+".name", "%q is configured twice", provider.Name)
+		names[provider.Name] = true
+		check(provider.Issuer != "", key+".issuer", "must be set")
+		check(provider.ClientID != "", key+".client_id", "must be set")
+	}
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	return Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func TestDefaultsAreValid(t *testing.T) {
+	cfg := Default()
+	require.NoError(t, cfg.Validate())
+
+	loaded, err := load(t)
+	require.NoError(t, err)
+	assert.Equal(t, 9000, loaded.Server.Port)
+	assert.Equal(t, loaded.BaseURL, loaded.Verification.LinkBaseURL)
+	assert.Equal(t, loaded.BaseURL, loaded.PasswordReset.LinkBaseURL)
+	assert.Empty(t, loaded.Server.TrustedProxies, "no proxy is trusted unless configured")
+}
+
+func TestPrecedence(t *testing.T) {
+	path := writeFile(t, "foodie.yaml", `
+server:
+  port: 7000
+  upload_dir: /srv/uploads
+db:
+  driver: sqlite
+  path: /srv/foodie.sqlite3
+cors:
+  allow_origins: [https://foodie.example.com, https://admin.example.com]
+pricing:
+  tax_rate: 0.08
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("PORT", "7100")
+	t.Setenv("DB_PATH", "/env/foodie.sqlite3")
+
+	cfg, err := load(t, "-port", "7200")
+	require.NoError(t, err)
+
+	assert.Equal(t, 7200, cfg.Server.Port, "flags win over the environment")
+	assert.Equal(t, "/env/foodie.sqlite3", cfg.DB.Path, "the environment wins over the file")
+	assert.Equal(t, "/srv/uploads", cfg.Server.UploadDir, "the file wins over the defaults")
+	assert.Equal(t, []string{"https://foodie.example.com", "https://admin.example.com"}, cfg.CORS.AllowOrigins)
+	assert.Equal(t, 0.08, cfg.Pricing.TaxRate)
+	assert.Equal(t, 14, cfg.Security.PasswordHashCost, "unset settings keep their default")
+}
+
+func TestTOMLFile(t *testing.T) {
+	path := writeFile(t, "foodie.toml", `
+base_url = "https://foodie.example.com"
+
+[login_protection]
+base_lockout = "2m"
+max_lockout = "2h"
+
+[[oidc_providers]]
+name = "Google"
+issuer = "https://accounts.google.com"
+client_id = "foodie"
+scopes = ["openid", "email"]
+`)
+
+	cfg, err := load(t, "-config", path)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, cfg.LoginProtection.BaseLockout)
+	assert.Equal(t, 2*time.Hour, cfg.LoginProtection.MaxLockout)
+	require.Len(t, cfg.OIDCProviders, 1)
+	provider := cfg.OIDCProviders[0]
+	assert.Equal(t, "google", provider.Name)
+	assert.Equal(t, []string{"openid", "email"}, provider.Scopes)
+	assert.Equal(t, "https://foodie.example.com/auth/google/callback", provider.RedirectURL)
+}
+
+func TestOIDCProvidersFromEnv(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "okta")
+	t.Setenv("OIDC_OKTA_ISSUER", "https://example.okta.com")
+	t.Setenv("OIDC_OKTA_CLIENT_ID", "foodie")
+	t.Setenv("OIDC_OKTA_SCOPES", "openid email profile")
+
+	cfg, err := load(t)
+	require.NoError(t, err)
+	require.Len(t, cfg.OIDCProviders, 1)
+	assert.Equal(t, "https://example.okta.com", cfg.OIDCProviders[0].Issuer)
+	assert.Equal(t, []string{"openid", "email", "profile"}, cfg.OIDCProviders[0].Scopes)
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Run("unknown file key", func(t *testing.T) {
+		path := writeFile(t, "foodie.yaml", "server:\n  prot: 9000\n")
+		_, err := load(t, "-config", path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "server.prot: unknown setting")
+	})
+
+	t.Run("unsupported file type", func(t *testing.T) {
+		path := writeFile(t, "foodie.json", "{}")
+		_, err := load(t, "-config", path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must end in .yaml, .yml or .toml")
+	})
+
+	t.Run("invalid environment value", func(t *testing.T) {
+		t.Setenv("LOGIN_BASE_LOCKOUT", "5")
+		t.Setenv("PORT", "http")
+		_, err := load(t)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "LOGIN_BASE_LOCKOUT: invalid duration")
+		assert.Contains(t, err.Error(), `PORT: invalid integer "http"`)
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		_, err := load(t, "-db-driver", "mysql", "-password-hash-cost", "2", "-mail-driver", "smtp")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `db.driver: must be sqlite or postgres, got "mysql"`)
+		assert.Contains(t, err.Error(), "security.password_hash_cost: must be between 4 and 31, got 2")
+		assert.Contains(t, err.Error(), "mail.smtp_host: must be set for the smtp driver")
+	})
+
+	t.Run("credentials with any origin", func(t *testing.T) {
+		t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+		_, err := load(t)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `cors.allow_credentials: cannot be used with the "*" origin`)
+	})
+
+	t.Run("invalid trusted proxy", func(t *testing.T) {
+		_, err := load(t, "-trusted-proxies", "10.0.0.0/8,proxy.internal")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `server.trusted_proxies: "proxy.internal" is not an IP address or CIDR range`)
+		assert.NotContains(t, err.Error(), `"10.0.0.0/8"`)
+	})
+
+	t.Run("missing key file", func(t *testing.T) {
+		_, err := load(t, "-jwt-private-key-file", filepath.Join(t.TempDir(), "missing.pem"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "jwt.private_key_file")
+	})
+}
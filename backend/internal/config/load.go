@@ -0,0 +1,348 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, a YAML or TOML file, environment variables (including a .env file) and
+// command line flags, then validates it. The file is named by the -config flag or
+// CONFIG_FILE. Load registers its flags on fs and parses args, so callers can add
+// flags of their own and read the remaining arguments from fs.Args().
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		fmt.Println("Error loading .env file. Using environment variables.")
+	}
+
+	cfg := Default()
+	settings := collectSettings(reflect.ValueOf(&cfg).Elem(), "", "")
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	flagValues := map[string]*flagValue{}
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		value := &flagValue{isBool: s.value.Kind() == reflect.Bool}
+		name := strings.ToLower(strings.ReplaceAll(s.env, "_", "-"))
+		fs.Var(value, name, fmt.Sprintf("overrides %s (%s in the config file)", s.env, s.key))
+		flagValues[name] = value
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if *configFile != "" {
+		errs = append(errs, applyFile(&cfg, settings, *configFile)...)
+	}
+
+	for _, s := range settings {
+		if raw := os.Getenv(s.env); s.env != "" && raw != "" {
+			if err := s.set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	if names := os.Getenv("OIDC_PROVIDERS"); names != "" {
+		providers, providerErrs := oidcProvidersFromEnv(names)
+		cfg.OIDCProviders = providers
+		errs = append(errs, providerErrs...)
+	}
+
+	bySettingName := map[string]setting{}
+	for _, s := range settings {
+		bySettingName[strings.ToLower(strings.ReplaceAll(s.env, "_", "-"))] = s
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if value, ok := flagValues[f.Name]; ok {
+			if err := bySettingName[f.Name].set(value.raw); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", f.Name, err))
+			}
+		}
+	})
+
+	errs = append(errs, cfg.resolve()...)
+	if len(errs) == 0 {
+		if err := cfg.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return &cfg, nil
+}
+
+// resolve fills the settings derived from others and reads key files
+func (c *Config) resolve() []error {
+	var errs []error
+	c.Verification.LinkBaseURL = c.BaseURL
+	c.PasswordReset.LinkBaseURL = c.BaseURL
+
+	if c.JWT.PrivateKeyFile != "" {
+		data, err := os.ReadFile(c.JWT.PrivateKeyFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("jwt.private_key_file: %w", err))
+		}
+		c.JWT.PrivateKey = data
+	}
+	c.JWT.PreviousKeys = map[string][]byte{}
+	for _, entry := range c.JWT.PreviousKeyFiles {
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || path == "" {
+			errs = append(errs, fmt.Errorf("jwt.previous_keys: invalid entry %q, expected kid=path", entry))
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("jwt.previous_keys: reading key %s: %w", kid, err))
+			continue
+		}
+		c.JWT.PreviousKeys[kid] = data
+	}
+
+	for i := range c.OIDCProviders {
+		provider := &c.OIDCProviders[i]
+		provider.Name = strings.ToLower(provider.Name)
+		if provider.RedirectURL == "" {
+			provider.RedirectURL = strings.TrimSuffix(c.BaseURL, "/") + "/auth/" + provider.Name + "/callback"
+		}
+	}
+	return errs
+}
+
+// setting is one configurable field
+type setting struct {
+	key   string // config file key, e.g. "db.driver"
+	env   string // environment variable, empty for settings only the file can set
+	value reflect.Value
+}
+
+// collectSettings walks the yaml and env tags of a config struct
+func collectSettings(v reflect.Value, keyPrefix, envPrefix string) []setting {
+	var settings []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := field.Tag.Get("yaml")
+		if key == "" || key == "-" {
+			continue
+		}
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			settings = append(settings, collectSettings(v.Field(i), keyPrefix+key+".", envPrefix)...)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			// lists of sections, such as oidc_providers, are handled by the caller
+		default:
+			env := field.Tag.Get("env")
+			if env != "" {
+				env = envPrefix + env
+			}
+			settings = append(settings, setting{key: keyPrefix + key, env: env, value: v.Field(i)})
+		}
+	}
+	return settings
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses raw into the setting. Lists are separated by commas or spaces.
+func (s setting) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if s.value.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, use a value like 90s or 15m", raw)
+		}
+		s.value.SetInt(int64(d))
+		return nil
+	}
+
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		s.value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		s.value.SetBool(b)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		s.value.SetFloat(f)
+	case reflect.Slice:
+		if s.value.Type().Elem().Kind() == reflect.Uint8 {
+			// PEM keys in environment variables often have escaped newlines
+			s.value.SetBytes([]byte(strings.ReplaceAll(raw, `\n`, "\n")))
+			return nil
+		}
+		items := strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+		s.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
+
+// applyFile sets everything in a YAML or TOML file and rejects unknown keys
+func applyFile(cfg *Config, settings []setting, path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("reading config file: %w", err)}
+	}
+	values := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return []error{fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)}
+	}
+	if err != nil {
+		return []error{fmt.Errorf("parsing %s: %w", path, err)}
+	}
+
+	var errs []error
+	if raw, ok := values["oidc_providers"]; ok {
+		delete(values, "oidc_providers")
+		providers, ok := raw.([]any)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: oidc_providers must be a list", path))
+		}
+		cfg.OIDCProviders = nil
+		for i, raw := range providers {
+			fields, ok := raw.(map[string]any)
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: oidc_providers[%d] must be a table of settings", path, i))
+				continue
+			}
+			var provider OIDCProviderConfig
+			providerSettings := collectSettings(reflect.ValueOf(&provider).Elem(), "", "")
+			errs = append(errs, applyValues(fmt.Sprintf("%s: oidc_providers[%d].", path, i), flatten("", fields), providerSettings)...)
+			cfg.OIDCProviders = append(cfg.OIDCProviders, provider)
+		}
+	}
+	return append(errs, applyValues(path+": ", flatten("", values), settings)...)
+}
+
+func applyValues(errPrefix string, values map[string]any, settings []setting) []error {
+	var errs []error
+	for _, s := range settings {
+		raw, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		delete(values, s.key)
+		if err := s.set(fileString(raw)); err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", errPrefix, s.key, err))
+		}
+	}
+	unknown := make([]string, 0, len(values))
+	for key := range values {
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("%s%s: unknown setting", errPrefix, key))
+	}
+	return errs
+}
+
+// flatten turns nested tables into dotted keys
+func flatten(prefix string, values map[string]any) map[string]any {
+	flat := map[string]any{}
+	for key, value := range values {
+		if nested, ok := value.(map[string]any); ok {
+			for k, v := range flatten(prefix+key+".", nested) {
+				flat[k] = v
+			}
+			continue
+		}
+		flat[prefix+key] = value
+	}
+	return flat
+}
+
+func fileString(raw any) string {
+	switch v := raw.(type) {
+	case string:
+		return v
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// oidcProvidersFromEnv reads the providers listed in OIDC_PROVIDERS
+func oidcProvidersFromEnv(names string) ([]OIDCProviderConfig, []error) {
+	var providers []OIDCProviderConfig
+	var errs []error
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		provider := OIDCProviderConfig{Name: name}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		for _, s := range collectSettings(reflect.ValueOf(&provider).Elem(), "", prefix) {
+			if raw := os.Getenv(s.env); s.env != "" && raw != "" {
+				if err := s.set(raw); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+				}
+			}
+		}
+		providers = append(providers, provider)
+	}
+	return providers, errs
+}
+
+// flagValue records a flag as given; it is parsed once the other sources are applied
+type flagValue struct {
+	raw    string
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.raw
+}
+
+func (f *flagValue) Set(raw string) error {
+	f.raw = raw
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
@@ -3,69 +3,64 @@ package db
 import (
 	"fmt"
 	"log/slog"
-	"sync"
 
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-var (
-	DB     *gorm.DB
-	dbOnce sync.Once
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
 )
 
-// InitializeDB creates a new GORM DB connection
-// func InitializeDB2() (*gorm.DB, error) {
-// 	var err error
-
-// 	// Use sync.Once to initialize the database connection only once
-// 	dbOnce.Do(func() {
-// 		conf := config.GetDBConfig()
-// 		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-// 			conf.Host, conf.User, conf.Password, conf.DBName, conf.Port, conf.SSLMode)
-
-// 		DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-// 			Logger: logger.Default.LogMode(logger.Info),
-// 		})
-// 		if err != nil {
-// 			slog.Error("Failed to connect to database", "error", err.Error())
-// 		} else {
-// 			slog.Info("Database connected successfully")
-// 		}
-// 	})
-
-// 	if DB == nil {
-// 		return nil, fmt.Errorf("failed to connect to database: %w", err)
-// 	}
-
-// 	return DB, nil
-// }
-
-// InitializeDB creates a new GORM DB connection
-func InitializeDB() (*gorm.DB, error) {
-	var err error
+// Open connects to the database selected by cfg.Driver and applies the pool settings
+func Open(cfg config.DBConfig, gormConfig *gorm.Config) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case DriverSQLite:
+		dialector = sqlite.Open(cfg.ConnectionString())
+	case DriverPostgres:
+		dialector = postgres.Open(cfg.ConnectionString())
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
 
-	// Use sync.Once to initialize the database connection only once
-	dbOnce.Do(func() {
-		// Update the DSN to use the SQLite file
-		dsn := "./foodie.sqlite3"
+	conn, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, err
+	}
+	// zero keeps the database/sql default
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	return conn, nil
+}
 
-		DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Info),
-		})
-		if err != nil {
-			slog.Error("Failed to connect to database", "error", err.Error())
-		} else {
-			slog.Info("Database connected successfully")
-		}
+// Connect opens the application database with query logging
+func Connect(cfg config.DBConfig) (*gorm.DB, error) {
+	conn, err := Open(cfg, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Info),
 	})
-
-	if DB == nil {
+	if err != nil {
+		slog.Error("Failed to connect to database", "driver", cfg.Driver, "error", err.Error())
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-
-	return DB, nil
+	slog.Info("Database connected successfully", "driver", cfg.Driver)
+	return conn, nil
 }
 
 // CloseDB closes the GORM DB connection
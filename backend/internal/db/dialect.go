@@ -0,0 +1,23 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// DayExpr returns an SQL expression formatting column as YYYY-MM-DD in the dialect of tx
+func DayExpr(tx *gorm.DB, column string) string {
+	if tx.Dialector.Name() == DriverPostgres {
+		return fmt.Sprintf("to_char(%s, 'YYYY-MM-DD')", column)
+	}
+	return fmt.Sprintf("strftime('%%Y-%%m-%%d', %s)", column)
+}
+
+// MonthExpr returns an SQL expression formatting column as YYYY-MM in the dialect of tx
+func MonthExpr(tx *gorm.DB, column string) string {
+	if tx.Dialector.Name() == DriverPostgres {
+		return fmt.Sprintf("to_char(%s, 'YYYY-MM')", column)
+	}
+	return fmt.Sprintf("strftime('%%Y-%%m', %s)", column)
+}
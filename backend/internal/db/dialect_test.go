@@ -0,0 +1,66 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/db"
+	"github.com/manjurulhoque/foodie/backend/internal/db/dbtest"
+)
+
+type event struct {
+	ID        uint
+	CreatedAt time.Time
+}
+
+func TestDateExpressions(t *testing.T) {
+	conn := dbtest.Open(t)
+	require.NoError(t, conn.AutoMigrate(&event{}))
+
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, time.Local)
+	events := []event{
+		{CreatedAt: day},
+		{CreatedAt: day.Add(time.Hour)},
+		{CreatedAt: day.AddDate(0, 0, -1)},
+		{CreatedAt: day.AddDate(0, -3, 0)}, // outside the window below
+	}
+	require.NoError(t, conn.Create(&events).Error)
+
+	var days []struct {
+		Date  string
+		Count int64
+	}
+	expr := db.DayExpr(conn, "created_at")
+	err := conn.Raw(`SELECT `+expr+` as date, COUNT(*) as count FROM events WHERE created_at >= ? GROUP BY `+expr+` ORDER BY date DESC`,
+		day.AddDate(0, -1, 0)).Scan(&days).Error
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+	assert.Equal(t, day.Format("2006-01-02"), days[0].Date)
+	assert.Equal(t, int64(2), days[0].Count)
+	assert.Equal(t, day.AddDate(0, 0, -1).Format("2006-01-02"), days[1].Date)
+
+	var months []string
+	expr = db.MonthExpr(conn, "created_at")
+	err = conn.Raw(`SELECT DISTINCT ` + expr + ` FROM events`).Scan(&months).Error
+	require.NoError(t, err)
+	assert.Contains(t, months, day.Format("2006-01"))
+	assert.Contains(t, months, day.AddDate(0, -3, 0).Format("2006-01"))
+}
+
+func TestOpen(t *testing.T) {
+	_, err := db.Open(config.DBConfig{Driver: "oracle"}, &gorm.Config{})
+	assert.Error(t, err)
+
+	conn, err := db.Open(config.DBConfig{Driver: db.DriverSQLite, Path: ":memory:", MaxOpenConns: 1}, &gorm.Config{})
+	require.NoError(t, err)
+	defer db.CloseDB(conn)
+	sqlDB, err := conn.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+}
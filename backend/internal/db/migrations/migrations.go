@@ -0,0 +1,369 @@
+// Package migrations applies the versioned SQL migrations in sql/ and records them
+// in the schema_migrations table.
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql. When the SQL
+// differs between databases, the dialect goes before the direction, for example
+// 0001_initial.postgres.up.sql; a dialect specific file wins over a generic one.
+// Each migration runs in a transaction together with its schema_migrations row.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+var (
+	ErrPendingMigrations = errors.New("database has pending migrations")
+	ErrUnmanagedSchema   = errors.New("database has tables but no migration history")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+?)(?:\.(sqlite|postgres))?\.(up|down)\.sql$`)
+
+// Migration is one schema change. Up and Down map a dialect name to its SQL; the
+// empty dialect holds SQL shared by all databases.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      map[string]string
+	Down    map[string]string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// sql returns the statements of a migration for a dialect
+func (m Migration) sql(statements map[string]string, dialect string) (string, bool) {
+	if sql, ok := statements[dialect]; ok {
+		return sql, true
+	}
+	sql, ok := statements[""]
+	return sql, ok
+}
+
+// Status is a migration and when it was applied, nil if it is pending
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for the migrations built into the binary
+func New(db *gorm.DB) (*Migrator, error) {
+	sub, err := fs.Sub(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return NewFromFS(db, sub)
+}
+
+// NewFromFS returns a Migrator for the migrations in the root of fsys
+func NewFromFS(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads the migrations in the root of fsys, ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: match[2], Up: map[string]string{}, Down: map[string]string{}}
+			byVersion[uint(version)] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %q and %q", version, migration.Name, match[2])
+		}
+		if match[4] == "up" {
+			migration.Up[match[3]] = string(content)
+		} else {
+			migration.Down[match[3]] = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if len(migration.Up) == 0 {
+			return nil, fmt.Errorf("migration %s has no up file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	return m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamp NOT NULL
+	)`).Error
+}
+
+func (m *Migrator) applied() (map[uint]schemaMigration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	var rows []schemaMigration
+	if err := m.db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// Status lists every known migration with the time it was applied
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet. It only reads from
+// the database, so it is safe to call from health checks.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	db := m.db.WithContext(ctx)
+	if !db.Migrator().HasTable(schemaMigration{}.TableName()) {
+		return m.migrations, nil
+	}
+	var versions []uint
+	if err := db.Model(&schemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Check returns ErrPendingMigrations unless every migration has been applied
+func (m *Migrator) Check() error {
+	return m.CheckContext(context.Background())
+}
+
+// CheckContext is Check with a context that bounds the database queries
+func (m *Migrator) CheckContext(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		names := make([]string, len(pending))
+		for i, migration := range pending {
+			names[i] = migration.String()
+		}
+		return fmt.Errorf("%w: %s", ErrPendingMigrations, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// Up applies all pending migrations in order and returns them
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		if err := m.checkEmpty(); err != nil {
+			return nil, err
+		}
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		sql, ok := migration.sql(migration.Up, m.db.Dialector.Name())
+		if !ok {
+			return done, fmt.Errorf("migration %s has no up file for %s", migration, m.db.Dialector.Name())
+		}
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("applying %s: %w", migration, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// checkEmpty refuses to migrate a database whose tables were created some other way,
+// such as by GORM's AutoMigrate; use Force once its schema matches a migration.
+func (m *Migrator) checkEmpty() error {
+	tables, err := m.db.Migrator().GetTables()
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if table != "schema_migrations" && !strings.HasPrefix(table, "sqlite_") {
+			return ErrUnmanagedSchema
+		}
+	}
+	return nil
+}
+
+// Down rolls back the latest steps applied migrations and returns them
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		sql, ok := migration.sql(migration.Down, m.db.Dialector.Name())
+		if !ok {
+			return done, fmt.Errorf("migration %s cannot be rolled back", migration)
+		}
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("rolling back %s: %w", migration, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Force records every migration up to and including version as applied without
+// running it, for adopting a database whose schema already matches
+func (m *Migrator) Force(version uint) error {
+	if _, err := m.applied(); err != nil {
+		return err
+	}
+	found := false
+	for _, migration := range m.migrations {
+		found = found || migration.Version == version
+	}
+	if !found {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("version > ?", version).Delete(&schemaMigration{}).Error; err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			row := schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+			if err := tx.Where(schemaMigration{Version: migration.Version}).FirstOrCreate(&row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Create writes empty up and down files for a new migration to dir, numbered after
+// the latest one there, and returns their paths
+func Create(dir, name string) ([]string, error) {
+	if !regexp.MustCompile(`^\w+$`).MatchString(name) {
+		return nil, fmt.Errorf("migration name %q may only contain letters, digits and underscores", name)
+	}
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	version := uint(1)
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %04d_%s (%s)\n", version, name, direction)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,189 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/db/dbtest"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+)
+
+// allModels must list every model; the migrations have to create what they need
+var allModels = []any{
+	&models.User{},
+	&models.Address{},
+	&models.Restaurant{},
+	&models.MenuItem{},
+	&models.Order{},
+	&models.OrderItem{},
+	&models.Category{},
+	&models.Cuisine{},
+	&models.Cart{},
+	&models.CartItem{},
+	&models.WorkingHour{},
+	&models.OrderStatusHistory{},
+	&models.RestaurantMember{},
+	&models.RestaurantInvitation{},
+	&models.RefreshToken{},
+	&models.RevokedToken{},
+	&models.PasswordResetToken{},
+	&models.LoginFailure{},
+	&models.TOTPSecret{},
+	&models.RecoveryCode{},
+	&models.UserIdentity{},
+	&models.OIDCAuthRequest{},
+	&models.Payment{},
+	&models.PaymentEvent{},
+	&models.Refund{},
+	&models.RefundItem{},
+	&models.IdempotencyKey{},
+	&models.Promotion{},
+	&models.OrderDiscount{},
+	&models.ModifierGroup{},
+	&models.ModifierOption{},
+	&models.CartItemOption{},
+	&models.OrderItemOption{},
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	db := dbtest.Open(t)
+	migrator, err := New(db)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	for _, model := range allModels {
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(model))
+		table := stmt.Schema.Table
+		if !assert.True(t, db.Migrator().HasTable(table), "missing table %s", table) {
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" {
+				assert.True(t, db.Migrator().HasColumn(model, field.DBName), "missing column %s.%s", table, field.DBName)
+			}
+		}
+		for _, index := range stmt.Schema.ParseIndexes() {
+			assert.True(t, db.Migrator().HasIndex(model, index.Name), "missing index %s on %s", index.Name, table)
+		}
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	db := dbtest.Open(t)
+	migrator, err := New(db)
+	require.NoError(t, err)
+	require.ErrorIs(t, migrator.Check(), ErrPendingMigrations)
+	assert.False(t, db.Migrator().HasTable("schema_migrations"), "checking does not write to the database")
+
+	done, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Len(t, done, len(migrator.migrations))
+	require.NoError(t, migrator.Check())
+
+	// nothing left to do
+	done, err = migrator.Up()
+	require.NoError(t, err)
+	assert.Empty(t, done)
+
+	done, err = migrator.Down(len(migrator.migrations))
+	require.NoError(t, err)
+	assert.Len(t, done, len(migrator.migrations))
+	assert.False(t, db.Migrator().HasTable("users"))
+	assert.ErrorIs(t, migrator.Check(), ErrPendingMigrations)
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt, status.String())
+	}
+}
+
+func TestDialectsAndOrdering(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_note.up.sql":              {Data: []byte("ALTER TABLE things ADD COLUMN note text;")},
+		"0002_add_note.down.sql":            {Data: []byte("ALTER TABLE things DROP COLUMN note;")},
+		"0001_things.sqlite.up.sql":         {Data: []byte("CREATE TABLE things (id integer PRIMARY KEY AUTOINCREMENT);")},
+		"0001_things.postgres.up.sql":       {Data: []byte("CREATE TABLE things (id bigserial PRIMARY KEY);")},
+		"0001_things.down.sql":              {Data: []byte("DROP TABLE things;")},
+		"README.md":                         {Data: []byte("not a migration")},
+		"0003_irreversible.up.sql":          {Data: []byte("UPDATE things SET note = 'backfilled';")},
+		"0004_multiple_statements.up.sql":   {Data: []byte("CREATE TABLE a (id integer);\nCREATE TABLE b (id integer);")},
+		"0004_multiple_statements.down.sql": {Data: []byte("DROP TABLE b;\nDROP TABLE a;")},
+	}
+	db := dbtest.Open(t)
+	migrator, err := NewFromFS(db, fsys)
+	require.NoError(t, err)
+	require.Len(t, migrator.migrations, 4)
+	assert.Equal(t, "0001_things", migrator.migrations[0].String())
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	assert.True(t, db.Migrator().HasColumn("things", "note"))
+	assert.True(t, db.Migrator().HasTable("b"))
+
+	done, err := migrator.Down(1)
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.False(t, db.Migrator().HasTable("a"))
+
+	// 0003 has no down file, so rolling back stops there
+	_, err = migrator.Down(1)
+	assert.Error(t, err)
+	assert.True(t, db.Migrator().HasColumn("things", "note"))
+}
+
+func TestFailedMigrationIsNotRecorded(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_broken.up.sql": {Data: []byte("CREATE TABLE things (id integer);\nTHIS IS NOT SQL;")},
+	}
+	db := dbtest.Open(t)
+	migrator, err := NewFromFS(db, fsys)
+	require.NoError(t, err)
+
+	_, err = migrator.Up()
+	assert.Error(t, err)
+	assert.ErrorIs(t, migrator.Check(), ErrPendingMigrations)
+}
+
+func TestUnmanagedSchema(t *testing.T) {
+	db := dbtest.Open(t)
+	require.NoError(t, db.AutoMigrate(allModels...))
+	migrator, err := New(db)
+	require.NoError(t, err)
+
+	_, err = migrator.Up()
+	assert.ErrorIs(t, err, ErrUnmanagedSchema)
+
+	// adopting the schema marks the initial migration as applied
+	require.NoError(t, migrator.Force(1))
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	assert.NotNil(t, statuses[0].AppliedAt)
+
+	assert.Error(t, migrator.Force(9999))
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0007_existing.up.sql"), []byte(""), 0o644))
+
+	paths, err := Create(dir, "add_column")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "0008_add_column.up.sql"),
+		filepath.Join(dir, "0008_add_column.down.sql"),
+	}, paths)
+
+	_, err = Create(dir, "bad name")
+	assert.Error(t, err)
+}
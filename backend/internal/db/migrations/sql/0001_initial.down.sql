@@ -0,0 +1,23 @@
+DROP TABLE IF EXISTS oidc_auth_requests;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_secrets;
+DROP TABLE IF EXISTS login_failures;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS restaurant_invitations;
+DROP TABLE IF EXISTS restaurant_members;
+DROP TABLE IF EXISTS order_status_histories;
+DROP TABLE IF EXISTS working_hours;
+DROP TABLE IF EXISTS cart_items;
+DROP TABLE IF EXISTS carts;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS menu_items;
+DROP TABLE IF EXISTS restaurant_cuisines;
+DROP TABLE IF EXISTS cuisines;
+DROP TABLE IF EXISTS restaurants;
+DROP TABLE IF EXISTS addresses;
+DROP TABLE IF EXISTS users;
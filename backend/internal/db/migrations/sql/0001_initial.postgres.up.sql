@@ -0,0 +1,318 @@
+CREATE TABLE users (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    email text NOT NULL,
+    name text NOT NULL,
+    password text NOT NULL,
+    phone text,
+    role text NOT NULL DEFAULT 'customer',
+    image text,
+    is_active boolean DEFAULT true,
+    is_email_verified boolean DEFAULT false,
+    last_login_at timestamptz,
+    address text,
+    email_verification_sent_at timestamptz,
+    token_version bigint NOT NULL DEFAULT 0,
+    two_factor_enabled boolean DEFAULT false,
+    CONSTRAINT uni_users_email UNIQUE (email),
+    CONSTRAINT uni_users_phone UNIQUE (phone)
+);
+
+CREATE TABLE addresses (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    label text NOT NULL,
+    street text NOT NULL,
+    city text NOT NULL,
+    state text NOT NULL,
+    postal_code text NOT NULL,
+    is_default boolean DEFAULT false,
+    CONSTRAINT fk_users_delivery_addresses FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE restaurants (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text,
+    description text,
+    address text,
+    phone text,
+    email text,
+    rating numeric DEFAULT 0,
+    image text,
+    is_active boolean DEFAULT true,
+    is_open boolean DEFAULT true,
+    user_id bigint DEFAULT null,
+    cancellation_window_minutes bigint DEFAULT 0,
+    CONSTRAINT fk_restaurants_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE cuisines (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text,
+    description text,
+    image text,
+    is_active boolean DEFAULT true,
+    is_popular boolean DEFAULT false
+);
+
+CREATE TABLE restaurant_cuisines (
+    cuisine_id bigint,
+    restaurant_id bigint,
+    PRIMARY KEY (cuisine_id,restaurant_id),
+    CONSTRAINT fk_restaurant_cuisines_cuisine FOREIGN KEY (cuisine_id) REFERENCES cuisines(id),
+    CONSTRAINT fk_restaurant_cuisines_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id)
+);
+
+CREATE TABLE menu_items (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text,
+    description text,
+    price numeric,
+    image text,
+    category text,
+    is_available boolean DEFAULT true,
+    restaurant_id bigint,
+    cuisine_id bigint DEFAULT null,
+    CONSTRAINT fk_restaurants_menu_items FOREIGN KEY (restaurant_id) REFERENCES restaurants(id),
+    CONSTRAINT fk_menu_items_cuisine FOREIGN KEY (cuisine_id) REFERENCES cuisines(id)
+);
+
+CREATE TABLE orders (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint,
+    restaurant_id bigint,
+    subtotal numeric DEFAULT 0,
+    tax_amount numeric DEFAULT 0,
+    delivery_fee numeric DEFAULT 0,
+    total_amount numeric,
+    status text DEFAULT 'pending',
+    delivery_address text,
+    payment_status text DEFAULT 'pending',
+    payment_method text,
+    CONSTRAINT fk_restaurants_orders FOREIGN KEY (restaurant_id) REFERENCES restaurants(id),
+    CONSTRAINT fk_users_orders FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE order_items (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    order_id bigint NOT NULL,
+    menu_item_id bigint NOT NULL,
+    quantity bigint,
+    price numeric,
+    CONSTRAINT fk_order_items_menu_item FOREIGN KEY (menu_item_id) REFERENCES menu_items(id),
+    CONSTRAINT fk_orders_items FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+
+CREATE TABLE categories (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text,
+    description text,
+    is_active boolean DEFAULT true
+);
+
+CREATE TABLE carts (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    restaurant_id bigint DEFAULT null,
+    CONSTRAINT fk_carts_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE cart_items (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    cart_id bigint NOT NULL,
+    menu_item_id bigint NOT NULL,
+    quantity bigint NOT NULL DEFAULT 1,
+    CONSTRAINT fk_cart_items_menu_item FOREIGN KEY (menu_item_id) REFERENCES menu_items(id),
+    CONSTRAINT fk_carts_items FOREIGN KEY (cart_id) REFERENCES carts(id)
+);
+
+CREATE TABLE working_hours (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    restaurant_id bigint NOT NULL,
+    day_of_week bigint NOT NULL,
+    open_time text NOT NULL,
+    close_time text NOT NULL,
+    is_closed boolean DEFAULT false,
+    CONSTRAINT fk_restaurants_working_hours FOREIGN KEY (restaurant_id) REFERENCES restaurants(id)
+);
+
+CREATE TABLE order_status_histories (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    order_id bigint NOT NULL,
+    from_status text,
+    status text NOT NULL,
+    description text,
+    changed_by_id bigint DEFAULT null,
+    CONSTRAINT fk_order_status_histories_changed_by FOREIGN KEY (changed_by_id) REFERENCES users(id),
+    CONSTRAINT fk_order_status_histories_order FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+
+CREATE TABLE restaurant_members (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    restaurant_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    role text NOT NULL DEFAULT 'staff',
+    CONSTRAINT fk_restaurant_members_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id),
+    CONSTRAINT fk_users_memberships FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX idx_restaurant_member ON restaurant_members(restaurant_id,user_id);
+
+CREATE TABLE restaurant_invitations (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    restaurant_id bigint NOT NULL,
+    email text NOT NULL,
+    role text NOT NULL,
+    token_hash text NOT NULL,
+    invited_by_id bigint NOT NULL,
+    expires_at timestamptz,
+    accepted_at timestamptz,
+    CONSTRAINT fk_restaurant_invitations_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id)
+);
+CREATE UNIQUE INDEX idx_restaurant_invitations_token_hash ON restaurant_invitations(token_hash);
+CREATE INDEX idx_restaurant_invitations_restaurant_id ON restaurant_invitations(restaurant_id);
+
+CREATE TABLE refresh_tokens (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    jti text NOT NULL,
+    family_id text NOT NULL,
+    expires_at timestamptz,
+    revoked_at timestamptz,
+    replaced_by text
+);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE UNIQUE INDEX idx_refresh_tokens_jti ON refresh_tokens(jti);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+
+CREATE TABLE revoked_tokens (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    jti text NOT NULL,
+    expires_at timestamptz
+);
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+CREATE UNIQUE INDEX idx_revoked_tokens_jti ON revoked_tokens(jti);
+
+CREATE TABLE password_reset_tokens (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamptz,
+    used_at timestamptz
+);
+CREATE UNIQUE INDEX idx_password_reset_tokens_token_hash ON password_reset_tokens(token_hash);
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+
+CREATE TABLE login_failures (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    email text NOT NULL,
+    user_id bigint,
+    ip text,
+    user_agent text,
+    reason text
+);
+CREATE INDEX idx_login_failures_user_id ON login_failures(user_id);
+CREATE INDEX idx_login_failures_email ON login_failures(email);
+
+CREATE TABLE totp_secrets (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    secret text NOT NULL,
+    confirmed_at timestamptz,
+    last_used_step bigint
+);
+CREATE UNIQUE INDEX idx_totp_secrets_user_id ON totp_secrets(user_id);
+
+CREATE TABLE recovery_codes (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    code_hash text NOT NULL,
+    used_at timestamptz
+);
+CREATE UNIQUE INDEX idx_recovery_codes_code_hash ON recovery_codes(code_hash);
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+CREATE TABLE user_identities (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    provider text NOT NULL,
+    subject text NOT NULL,
+    email text
+);
+CREATE UNIQUE INDEX idx_identity_provider_subject ON user_identities(provider,subject);
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+CREATE TABLE oidc_auth_requests (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    state text NOT NULL,
+    provider text NOT NULL,
+    nonce text NOT NULL,
+    code_verifier text NOT NULL,
+    expires_at timestamptz
+);
+CREATE INDEX idx_oidc_auth_requests_expires_at ON oidc_auth_requests(expires_at);
+CREATE UNIQUE INDEX idx_oidc_auth_requests_state ON oidc_auth_requests(state);
@@ -0,0 +1,318 @@
+CREATE TABLE users (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    email text NOT NULL,
+    name text NOT NULL,
+    password text NOT NULL,
+    phone text,
+    role text NOT NULL DEFAULT 'customer',
+    image text,
+    is_active numeric DEFAULT true,
+    is_email_verified numeric DEFAULT false,
+    last_login_at datetime,
+    address text,
+    email_verification_sent_at datetime,
+    token_version integer NOT NULL DEFAULT 0,
+    two_factor_enabled numeric DEFAULT false,
+    CONSTRAINT uni_users_email UNIQUE (email),
+    CONSTRAINT uni_users_phone UNIQUE (phone)
+);
+
+CREATE TABLE addresses (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id integer NOT NULL,
+    label text NOT NULL,
+    street text NOT NULL,
+    city text NOT NULL,
+    state text NOT NULL,
+    postal_code text NOT NULL,
+    is_default numeric DEFAULT false,
+    CONSTRAINT fk_users_delivery_addresses FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE restaurants (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    name text,
+    description text,
+    address text,
+    phone text,
+    email text,
+    rating real DEFAULT 0,
+    image text,
+    is_active numeric DEFAULT true,
+    is_open numeric DEFAULT true,
+    user_id integer DEFAULT null,
+    cancellation_window_minutes integer DEFAULT 0,
+    CONSTRAINT fk_restaurants_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE cuisines (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    name text,
+    description text,
+    image text,
+    is_active numeric DEFAULT true,
+    is_popular numeric DEFAULT false
+);
+
+CREATE TABLE restaurant_cuisines (
+    cuisine_id integer,
+    restaurant_id integer,
+    PRIMARY KEY (cuisine_id,restaurant_id),
+    CONSTRAINT fk_restaurant_cuisines_cuisine FOREIGN KEY (cuisine_id) REFERENCES cuisines(id),
+    CONSTRAINT fk_restaurant_cuisines_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id)
+);
+
+CREATE TABLE menu_items (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    name text,
+    description text,
+    price real,
+    image text,
+    category text,
+    is_available numeric DEFAULT true,
+    restaurant_id integer,
+    cuisine_id integer DEFAULT null,
+    CONSTRAINT fk_restaurants_menu_items FOREIGN KEY (restaurant_id) REFERENCES restaurants(id),
+    CONSTRAINT fk_menu_items_cuisine FOREIGN KEY (cuisine_id) REFERENCES cuisines(id)
+);
+
+CREATE TABLE orders (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id integer,
+    restaurant_id integer,
+    subtotal real DEFAULT 0,
+    tax_amount real DEFAULT 0,
+    delivery_fee real DEFAULT 0,
+    total_amount real,
+    status text DEFAULT 'pending',
+    delivery_address text,
+    payment_status text DEFAULT 'pending',
+    payment_method text,
+    CONSTRAINT fk_restaurants_orders FOREIGN KEY (restaurant_id) REFERENCES restaurants(id),
+    CONSTRAINT fk_users_orders FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE order_items (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    order_id integer NOT NULL,
+    menu_item_id integer NOT NULL,
+    quantity integer,
+    price real,
+    CONSTRAINT fk_order_items_menu_item FOREIGN KEY (menu_item_id) REFERENCES menu_items(id),
+    CONSTRAINT fk_orders_items FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+
+CREATE TABLE categories (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    name text,
+    description text,
+    is_active numeric DEFAULT true
+);
+
+CREATE TABLE carts (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id integer NOT NULL,
+    restaurant_id integer DEFAULT null,
+    CONSTRAINT fk_carts_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE cart_items (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    cart_id integer NOT NULL,
+    menu_item_id integer NOT NULL,
+    quantity integer NOT NULL DEFAULT 1,
+    CONSTRAINT fk_cart_items_menu_item FOREIGN KEY (menu_item_id) REFERENCES menu_items(id),
+    CONSTRAINT fk_carts_items FOREIGN KEY (cart_id) REFERENCES carts(id)
+);
+
+CREATE TABLE working_hours (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    restaurant_id integer NOT NULL,
+    day_of_week integer NOT NULL,
+    open_time text NOT NULL,
+    close_time text NOT NULL,
+    is_closed numeric DEFAULT false,
+    CONSTRAINT fk_restaurants_working_hours FOREIGN KEY (restaurant_id) REFERENCES restaurants(id)
+);
+
+CREATE TABLE order_status_histories (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    order_id integer NOT NULL,
+    from_status text,
+    status text NOT NULL,
+    description text,
+    changed_by_id integer DEFAULT null,
+    CONSTRAINT fk_order_status_histories_changed_by FOREIGN KEY (changed_by_id) REFERENCES users(id),
+    CONSTRAINT fk_order_status_histories_order FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+
+CREATE TABLE restaurant_members (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    restaurant_id integer NOT NULL,
+    user_id integer NOT NULL,
+    role text NOT NULL DEFAULT 'staff',
+    CONSTRAINT fk_restaurant_members_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id),
+    CONSTRAINT fk_users_memberships FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX idx_restaurant_member ON restaurant_members(restaurant_id,user_id);
+
+CREATE TABLE restaurant_invitations (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    restaurant_id integer NOT NULL,
+    email text NOT NULL,
+    role text NOT NULL,
+    token_hash text NOT NULL,
+    invited_by_id integer NOT NULL,
+    expires_at datetime,
+    accepted_at datetime,
+    CONSTRAINT fk_restaurant_invitations_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id)
+);
+CREATE UNIQUE INDEX idx_restaurant_invitations_token_hash ON restaurant_invitations(token_hash);
+CREATE INDEX idx_restaurant_invitations_restaurant_id ON restaurant_invitations(restaurant_id);
+
+CREATE TABLE refresh_tokens (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id integer NOT NULL,
+    jti text NOT NULL,
+    family_id text NOT NULL,
+    expires_at datetime,
+    revoked_at datetime,
+    replaced_by text
+);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE UNIQUE INDEX idx_refresh_tokens_jti ON refresh_tokens(jti);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+
+CREATE TABLE revoked_tokens (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    jti text NOT NULL,
+    expires_at datetime
+);
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+CREATE UNIQUE INDEX idx_revoked_tokens_jti ON revoked_tokens(jti);
+
+CREATE TABLE password_reset_tokens (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id integer NOT NULL,
+    token_hash text NOT NULL,
+    expires_at datetime,
+    used_at datetime
+);
+CREATE UNIQUE INDEX idx_password_reset_tokens_token_hash ON password_reset_tokens(token_hash);
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+
+CREATE TABLE login_failures (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    email text NOT NULL,
+    user_id integer,
+    ip text,
+    user_agent text,
+    reason text
+);
+CREATE INDEX idx_login_failures_user_id ON login_failures(user_id);
+CREATE INDEX idx_login_failures_email ON login_failures(email);
+
+CREATE TABLE totp_secrets (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id integer NOT NULL,
+    secret text NOT NULL,
+    confirmed_at datetime,
+    last_used_step integer
+);
+CREATE UNIQUE INDEX idx_totp_secrets_user_id ON totp_secrets(user_id);
+
+CREATE TABLE recovery_codes (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id integer NOT NULL,
+    code_hash text NOT NULL,
+    used_at datetime
+);
+CREATE UNIQUE INDEX idx_recovery_codes_code_hash ON recovery_codes(code_hash);
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+CREATE TABLE user_identities (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id integer NOT NULL,
+    provider text NOT NULL,
+    subject text NOT NULL,
+    email text
+);
+CREATE UNIQUE INDEX idx_identity_provider_subject ON user_identities(provider,subject);
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+CREATE TABLE oidc_auth_requests (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    state text NOT NULL,
+    provider text NOT NULL,
+    nonce text NOT NULL,
+    code_verifier text NOT NULL,
+    expires_at datetime
+);
+CREATE INDEX idx_oidc_auth_requests_expires_at ON oidc_auth_requests(expires_at);
+CREATE UNIQUE INDEX idx_oidc_auth_requests_state ON oidc_auth_requests(state);
@@ -0,0 +1,27 @@
+CREATE TABLE payments (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    order_id integer NOT NULL,
+    provider text NOT NULL,
+    provider_ref text NOT NULL,
+    amount real,
+    status text NOT NULL DEFAULT 'pending',
+    CONSTRAINT fk_payments_order FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+CREATE INDEX idx_payments_order_id ON payments(order_id);
+CREATE UNIQUE INDEX idx_payment_provider_ref ON payments(provider,provider_ref);
+
+CREATE TABLE payment_events (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    provider text NOT NULL,
+    event_id text NOT NULL,
+    payment_id integer,
+    status text
+);
+CREATE UNIQUE INDEX idx_payment_event ON payment_events(provider,event_id);
+CREATE INDEX idx_payment_events_payment_id ON payment_events(payment_id);
@@ -0,0 +1,32 @@
+CREATE TABLE refunds (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    order_id integer NOT NULL,
+    payment_id integer NOT NULL,
+    amount real,
+    reason text NOT NULL,
+    provider_ref text,
+    created_by_id integer,
+    CONSTRAINT fk_refunds_order FOREIGN KEY (order_id) REFERENCES orders(id),
+    CONSTRAINT fk_refunds_payment FOREIGN KEY (payment_id) REFERENCES payments(id),
+    CONSTRAINT fk_refunds_created_by FOREIGN KEY (created_by_id) REFERENCES users(id)
+);
+CREATE INDEX idx_refunds_order_id ON refunds(order_id);
+CREATE INDEX idx_refunds_payment_id ON refunds(payment_id);
+
+CREATE TABLE refund_items (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    refund_id integer NOT NULL,
+    order_item_id integer NOT NULL,
+    quantity integer,
+    amount real,
+    CONSTRAINT fk_refunds_items FOREIGN KEY (refund_id) REFERENCES refunds(id),
+    CONSTRAINT fk_refund_items_order_item FOREIGN KEY (order_item_id) REFERENCES order_items(id)
+);
+CREATE INDEX idx_refund_items_refund_id ON refund_items(refund_id);
+CREATE INDEX idx_refund_items_order_item_id ON refund_items(order_item_id);
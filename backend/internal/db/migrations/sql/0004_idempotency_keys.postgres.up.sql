@@ -0,0 +1,15 @@
+CREATE TABLE idempotency_keys (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    key text NOT NULL,
+    request_hash text NOT NULL,
+    status_code bigint,
+    content_type text,
+    response_body bytea,
+    expires_at timestamptz
+);
+CREATE UNIQUE INDEX idx_idempotency_user_key ON idempotency_keys(user_id,key);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
@@ -0,0 +1,15 @@
+CREATE TABLE idempotency_keys (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id integer NOT NULL,
+    key text NOT NULL,
+    request_hash text NOT NULL,
+    status_code integer,
+    content_type text,
+    response_body blob,
+    expires_at datetime
+);
+CREATE UNIQUE INDEX idx_idempotency_user_key ON idempotency_keys(user_id,key);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
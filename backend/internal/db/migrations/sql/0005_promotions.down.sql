@@ -0,0 +1,4 @@
+ALTER TABLE carts DROP COLUMN promotion_id;
+ALTER TABLE orders DROP COLUMN discount_amount;
+DROP TABLE IF EXISTS order_discounts;
+DROP TABLE IF EXISTS promotions;
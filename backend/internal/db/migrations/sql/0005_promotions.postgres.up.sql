@@ -0,0 +1,43 @@
+CREATE TABLE promotions (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    code text NOT NULL,
+    description text,
+    type text NOT NULL,
+    value numeric,
+    min_subtotal numeric DEFAULT 0,
+    max_uses bigint DEFAULT 0,
+    max_uses_per_user bigint DEFAULT 0,
+    starts_at timestamptz,
+    ends_at timestamptz,
+    is_active boolean DEFAULT true,
+    restaurant_id bigint DEFAULT null,
+    cuisine_id bigint DEFAULT null,
+    created_by_id bigint,
+    CONSTRAINT fk_promotions_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id),
+    CONSTRAINT fk_promotions_cuisine FOREIGN KEY (cuisine_id) REFERENCES cuisines(id)
+);
+CREATE INDEX idx_promotions_code ON promotions(code);
+CREATE INDEX idx_promotions_restaurant_id ON promotions(restaurant_id);
+
+CREATE TABLE order_discounts (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    order_id bigint NOT NULL,
+    promotion_id bigint NOT NULL,
+    code text,
+    description text,
+    target text,
+    amount numeric,
+    CONSTRAINT fk_orders_discounts FOREIGN KEY (order_id) REFERENCES orders(id),
+    CONSTRAINT fk_order_discounts_promotion FOREIGN KEY (promotion_id) REFERENCES promotions(id)
+);
+CREATE INDEX idx_order_discounts_order_id ON order_discounts(order_id);
+CREATE INDEX idx_order_discounts_promotion_id ON order_discounts(promotion_id);
+
+ALTER TABLE orders ADD COLUMN discount_amount numeric DEFAULT 0;
+ALTER TABLE carts ADD COLUMN promotion_id bigint DEFAULT null REFERENCES promotions(id);
@@ -0,0 +1,43 @@
+CREATE TABLE promotions (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    code text NOT NULL,
+    description text,
+    type text NOT NULL,
+    value real,
+    min_subtotal real DEFAULT 0,
+    max_uses integer DEFAULT 0,
+    max_uses_per_user integer DEFAULT 0,
+    starts_at datetime,
+    ends_at datetime,
+    is_active numeric DEFAULT true,
+    restaurant_id integer DEFAULT null,
+    cuisine_id integer DEFAULT null,
+    created_by_id integer,
+    CONSTRAINT fk_promotions_restaurant FOREIGN KEY (restaurant_id) REFERENCES restaurants(id),
+    CONSTRAINT fk_promotions_cuisine FOREIGN KEY (cuisine_id) REFERENCES cuisines(id)
+);
+CREATE INDEX idx_promotions_code ON promotions(code);
+CREATE INDEX idx_promotions_restaurant_id ON promotions(restaurant_id);
+
+CREATE TABLE order_discounts (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    order_id integer NOT NULL,
+    promotion_id integer NOT NULL,
+    code text,
+    description text,
+    target text,
+    amount real,
+    CONSTRAINT fk_orders_discounts FOREIGN KEY (order_id) REFERENCES orders(id),
+    CONSTRAINT fk_order_discounts_promotion FOREIGN KEY (promotion_id) REFERENCES promotions(id)
+);
+CREATE INDEX idx_order_discounts_order_id ON order_discounts(order_id);
+CREATE INDEX idx_order_discounts_promotion_id ON order_discounts(promotion_id);
+
+ALTER TABLE orders ADD COLUMN discount_amount real DEFAULT 0;
+ALTER TABLE carts ADD COLUMN promotion_id integer DEFAULT null REFERENCES promotions(id);
@@ -0,0 +1,7 @@
+ALTER TABLE order_items DROP COLUMN notes;
+ALTER TABLE cart_items DROP COLUMN options_key;
+ALTER TABLE cart_items DROP COLUMN notes;
+DROP TABLE IF EXISTS order_item_options;
+DROP TABLE IF EXISTS cart_item_options;
+DROP TABLE IF EXISTS modifier_options;
+DROP TABLE IF EXISTS modifier_groups;
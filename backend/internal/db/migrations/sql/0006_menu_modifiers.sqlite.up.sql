@@ -0,0 +1,58 @@
+CREATE TABLE modifier_groups (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    menu_item_id integer NOT NULL,
+    name text NOT NULL,
+    required numeric DEFAULT false,
+    min_selections integer DEFAULT 0,
+    max_selections integer DEFAULT 0,
+    position integer DEFAULT 0,
+    CONSTRAINT fk_menu_items_modifier_groups FOREIGN KEY (menu_item_id) REFERENCES menu_items(id)
+);
+CREATE INDEX idx_modifier_groups_menu_item_id ON modifier_groups(menu_item_id);
+
+CREATE TABLE modifier_options (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    group_id integer NOT NULL,
+    name text NOT NULL,
+    price_delta real DEFAULT 0,
+    is_available numeric DEFAULT true,
+    position integer DEFAULT 0,
+    CONSTRAINT fk_modifier_groups_options FOREIGN KEY (group_id) REFERENCES modifier_groups(id)
+);
+CREATE INDEX idx_modifier_options_group_id ON modifier_options(group_id);
+
+CREATE TABLE cart_item_options (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    cart_item_id integer NOT NULL,
+    modifier_option_id integer NOT NULL,
+    CONSTRAINT fk_cart_items_options FOREIGN KEY (cart_item_id) REFERENCES cart_items(id),
+    CONSTRAINT fk_cart_item_options_modifier_option FOREIGN KEY (modifier_option_id) REFERENCES modifier_options(id)
+);
+CREATE INDEX idx_cart_item_options_cart_item_id ON cart_item_options(cart_item_id);
+
+CREATE TABLE order_item_options (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    order_item_id integer NOT NULL,
+    modifier_option_id integer,
+    group_name text,
+    name text,
+    price_delta real,
+    CONSTRAINT fk_order_items_options FOREIGN KEY (order_item_id) REFERENCES order_items(id)
+);
+CREATE INDEX idx_order_item_options_order_item_id ON order_item_options(order_item_id);
+
+ALTER TABLE cart_items ADD COLUMN notes text DEFAULT '';
+ALTER TABLE cart_items ADD COLUMN options_key text DEFAULT '';
+ALTER TABLE order_items ADD COLUMN notes text;
@@ -0,0 +1,2 @@
+DROP INDEX idx_promotions_code;
+CREATE INDEX idx_promotions_code ON promotions(code);
@@ -0,0 +1,2 @@
+DROP INDEX idx_promotions_code;
+CREATE UNIQUE INDEX idx_promotions_code ON promotions(code) WHERE deleted_at IS NULL;
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
 	"github.com/manjurulhoque/foodie/backend/internal/services"
 	"github.com/manjurulhoque/foodie/backend/pkg/utils"
+	"gorm.io/gorm"
 )
 
 type AddressHandler struct {
@@ -75,8 +78,8 @@ func (h *AddressHandler) UpdateAddress(c *gin.Context) {
 	// Add user ID to updates
 	address["user_id"] = utils.GetUserID(c)
 
-	if err := h.service.UpdateAddress(uint(id), address); err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+	if err := h.service.UpdateAddress(utils.GetUser(c), uint(id), address); err != nil {
+		c.JSON(resourceErrorStatus(err), utils.GenericResponse[any]{
 			Success: false,
 			Message: "Failed to update address",
 			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
@@ -92,8 +95,8 @@ func (h *AddressHandler) UpdateAddress(c *gin.Context) {
 
 func (h *AddressHandler) DeleteAddress(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err := h.service.DeleteAddress(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+	if err := h.service.DeleteAddress(utils.GetUser(c), uint(id)); err != nil {
+		c.JSON(resourceErrorStatus(err), utils.GenericResponse[any]{
 			Success: false,
 			Message: "Failed to delete address",
 			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
@@ -125,3 +128,15 @@ func (h *AddressHandler) GetUserAddresses(c *gin.Context) {
 		Data:    addresses,
 	})
 }
+
+// resourceErrorStatus maps errors from loading and authorizing a single resource to HTTP status codes
+func resourceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, policy.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
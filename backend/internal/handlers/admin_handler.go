@@ -2,17 +2,20 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/manjurulhoque/foodie/backend/internal/db"
 	"github.com/manjurulhoque/foodie/backend/internal/models"
 	"github.com/manjurulhoque/foodie/backend/pkg/utils"
+	"gorm.io/gorm"
 )
 
 type AdminOverview struct {
 	TotalUsers        int64   `json:"total_users"`
 	TotalOrders       int64   `json:"total_orders"`
 	TotalRevenue      float64 `json:"total_revenue"`
+	TotalRefunds      float64 `json:"total_refunds"`
 	ActiveRestaurants int64   `json:"active_restaurants"`
 }
 
@@ -56,20 +59,34 @@ type AdminReport struct {
 	RestaurantStats []RestaurantStat `json:"restaurant_stats"`
 }
 
-func GetAdminOverview(c *gin.Context) {
+type AdminHandler struct {
+	db *gorm.DB
+}
+
+func NewAdminHandler(db *gorm.DB) *AdminHandler {
+	return &AdminHandler{db: db}
+}
+
+func (h *AdminHandler) GetAdminOverview(c *gin.Context) {
 	var overview AdminOverview
 
 	// Get total users
-	db.DB.Model(&models.User{}).Count(&overview.TotalUsers)
+	h.db.Model(&models.User{}).Count(&overview.TotalUsers)
 
 	// Get total orders
-	db.DB.Model(&models.Order{}).Count(&overview.TotalOrders)
+	h.db.Model(&models.Order{}).Count(&overview.TotalOrders)
+
+	// Get total revenue from orders whose money was taken
+	h.db.Model(&models.Order{}).
+		Where("payment_status IN ?", []string{models.PaymentStatusPaid, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded}).
+		Select("COALESCE(SUM(total_amount), 0)").Scan(&overview.TotalRevenue)
 
-	// Get total revenue
-	db.DB.Model(&models.Order{}).Select("COALESCE(SUM(total_amount), 0)").Scan(&overview.TotalRevenue)
+	// Refunded money is not revenue
+	h.db.Model(&models.Refund{}).Select("COALESCE(SUM(amount), 0)").Scan(&overview.TotalRefunds)
+	overview.TotalRevenue -= overview.TotalRefunds
 
 	// Get active restaurants
-	db.DB.Model(&models.Restaurant{}).Where("is_active = ?", true).Count(&overview.ActiveRestaurants)
+	h.db.Model(&models.Restaurant{}).Where("is_active = ?", true).Count(&overview.ActiveRestaurants)
 
 	c.JSON(http.StatusOK, utils.GenericResponse[AdminOverview]{
 		Success: true,
@@ -78,7 +95,7 @@ func GetAdminOverview(c *gin.Context) {
 	})
 }
 
-func GetAdminAnalytics(c *gin.Context) {
+func (h *AdminHandler) GetAdminAnalytics(c *gin.Context) {
 	var analytics AdminAnalytics
 
 	// Initialize empty slices
@@ -87,16 +104,17 @@ func GetAdminAnalytics(c *gin.Context) {
 	analytics.RevenueByMonth = []MonthlyRevenue{}
 
 	// Get daily order stats
-	db.DB.Raw(`
-		SELECT date(created_at) as date, COUNT(*) as count
+	day := db.DayExpr(h.db, "created_at")
+	h.db.Raw(`
+		SELECT `+day+` as date, COUNT(*) as count
 		FROM orders
-		WHERE created_at >= datetime('now', '-30 days')
-		GROUP BY date(created_at)
+		WHERE created_at >= ?
+		GROUP BY `+day+`
 		ORDER BY date DESC
-	`).Scan(&analytics.DailyOrders)
+	`, time.Now().AddDate(0, 0, -30)).Scan(&analytics.DailyOrders)
 
 	// Get popular items
-	db.DB.Raw(`
+	h.db.Raw(`
 		SELECT menu_items.name, COUNT(order_items.id) as order_count
 		FROM order_items
 		JOIN menu_items ON order_items.menu_item_id = menu_items.id
@@ -106,13 +124,14 @@ func GetAdminAnalytics(c *gin.Context) {
 	`).Scan(&analytics.PopularItems)
 
 	// Get monthly revenue
-	db.DB.Raw(`
-		SELECT strftime('%Y-%m', created_at) as month, COALESCE(SUM(total_amount), 0) as revenue
+	month := db.MonthExpr(h.db, "created_at")
+	h.db.Raw(`
+		SELECT `+month+` as month, COALESCE(SUM(total_amount), 0) as revenue
 		FROM orders
-		WHERE created_at >= datetime('now', '-12 months')
-		GROUP BY strftime('%Y-%m', created_at)
+		WHERE created_at >= ?
+		GROUP BY `+month+`
 		ORDER BY month DESC
-	`).Scan(&analytics.RevenueByMonth)
+	`, time.Now().AddDate(0, -12, 0)).Scan(&analytics.RevenueByMonth)
 
 	c.JSON(http.StatusOK, utils.GenericResponse[AdminAnalytics]{
 		Success: true,
@@ -121,7 +140,7 @@ func GetAdminAnalytics(c *gin.Context) {
 	})
 }
 
-func GetAdminReports(c *gin.Context) {
+func (h *AdminHandler) GetAdminReports(c *gin.Context) {
 	var report AdminReport
 
 	// Initialize empty slices
@@ -130,13 +149,13 @@ func GetAdminReports(c *gin.Context) {
 	report.RestaurantStats = []RestaurantStat{}
 
 	// Get recent orders
-	db.DB.Preload("User").Preload("Restaurant").
+	h.db.Preload("User").Preload("Restaurant").
 		Order("created_at DESC").
 		Limit(10).
 		Find(&report.RecentOrders)
 
 	// Get user activity
-	db.DB.Raw(`
+	h.db.Raw(`
 		SELECT users.name, COUNT(orders.id) as order_count, COALESCE(SUM(orders.total_amount), 0) as total_spent
 		FROM users
 		LEFT JOIN orders ON users.id = orders.user_id
@@ -146,7 +165,7 @@ func GetAdminReports(c *gin.Context) {
 	`).Scan(&report.UserActivity)
 
 	// Get restaurant stats
-	db.DB.Raw(`
+	h.db.Raw(`
 		SELECT restaurants.name, COUNT(orders.id) as order_count, 
 			   COALESCE(AVG(orders.total_amount), 0) as avg_rating, 
 			   COALESCE(SUM(orders.total_amount), 0) as total_revenue
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/services"
+	"github.com/manjurulhoque/foodie/backend/pkg/utils"
+)
+
+// AdminUserHandler serves the admin endpoints for managing user accounts
+type AdminUserHandler struct {
+	userService    services.UserService
+	orderService   services.OrderService
+	addressService services.AddressService
+	loginGuard     *services.LoginGuard
+}
+
+func NewAdminUserHandler(
+	userService services.UserService,
+	orderService services.OrderService,
+	addressService services.AddressService,
+	loginGuard *services.LoginGuard,
+) *AdminUserHandler {
+	return &AdminUserHandler{
+		userService:    userService,
+		orderService:   orderService,
+		addressService: addressService,
+		loginGuard:     loginGuard,
+	}
+}
+
+// getUser loads the user named by the :id parameter, writing an error response if it fails
+func (h *AdminUserHandler) getUser(c *gin.Context) (*models.PublicUser, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid user ID",
+		})
+		return nil, false
+	}
+
+	user, err := h.userService.GetUserById(uint(userID))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to get user",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user account
+// @Description Clear the failed login attempts and lockout of an account
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /admin/users/{id}/unlock [post]
+func (h *AdminUserHandler) UnlockUser(c *gin.Context) {
+	user, ok := h.getUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.loginGuard.Unlock(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to unlock user",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "User unlocked successfully",
+	})
+}
+
+// GetLoginFailures godoc
+// @Summary Get a user's failed logins
+// @Description Get the most recent failed login attempts for a user's email
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "Number of records" default(50)
+// @Success 200 {object} utils.GenericResponse[[]models.LoginFailure]
+// @Router /admin/users/{id}/login-failures [get]
+func (h *AdminUserHandler) GetLoginFailures(c *gin.Context) {
+	user, ok := h.getUser(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	failures, err := h.loginGuard.RecentFailures(user.Email, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to get login failures",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[[]models.LoginFailure]{
+		Success: true,
+		Message: "Login failures retrieved successfully",
+		Data:    failures,
+	})
+}
+
+// isSelf reports, and answers with 400, when an admin tries to lock themselves out
+func isSelf(c *gin.Context, user *models.PublicUser, action string) bool {
+	if user.Id != utils.GetUserID(c) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+		Success: false,
+		Message: "You cannot " + action + " your own account",
+	})
+	return true
+}
+
+// DeactivateUser godoc
+// @Summary Deactivate a user
+// @Description Deactivate a user account. The user is logged out everywhere and can no longer log in.
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /admin/users/{id}/deactivate [post]
+func (h *AdminUserHandler) DeactivateUser(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+// ReactivateUser godoc
+// @Summary Reactivate a user
+// @Description Reactivate a deactivated user account
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /admin/users/{id}/reactivate [post]
+func (h *AdminUserHandler) ReactivateUser(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+func (h *AdminUserHandler) setActive(c *gin.Context, active bool) {
+	user, ok := h.getUser(c)
+	if !ok {
+		return
+	}
+	if !active && isSelf(c, user, "deactivate") {
+		return
+	}
+
+	if err := h.userService.SetUserActive(user.Id, active); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to update user",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	message := "User reactivated successfully"
+	if !active {
+		message = "User deactivated successfully"
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: message,
+	})
+}
+
+// ChangeUserRole godoc
+// @Summary Change a user's role
+// @Description Change the role of a user. The user is logged out so new tokens carry the new role.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /admin/users/{id}/role [put]
+func (h *AdminUserHandler) ChangeUserRole(c *gin.Context) {
+	var input struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	user, ok := h.getUser(c)
+	if !ok {
+		return
+	}
+	if input.Role != models.RoleAdmin && isSelf(c, user, "remove the admin role from") {
+		return
+	}
+
+	if err := h.userService.ChangeRole(user.Id, input.Role); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidRole) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to change role",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "role"}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Role changed successfully",
+	})
+}
+
+// LogoutUser godoc
+// @Summary Log a user out everywhere
+// @Description Revoke every session of a user
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /admin/users/{id}/logout [post]
+func (h *AdminUserHandler) LogoutUser(c *gin.Context) {
+	user, ok := h.getUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.RevokeSessions(user.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to log out user",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "User logged out successfully",
+	})
+}
+
+// GetUserOrders godoc
+// @Summary Get a user's orders
+// @Description Get every order placed by a user
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.GenericResponse[[]models.Order]
+// @Router /admin/users/{id}/orders [get]
+func (h *AdminUserHandler) GetUserOrders(c *gin.Context) {
+	user, ok := h.getUser(c)
+	if !ok {
+		return
+	}
+
+	orders, err := h.orderService.GetUserOrders(user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to fetch orders",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[[]models.Order]{
+		Success: true,
+		Message: "Orders fetched successfully",
+		Data:    orders,
+	})
+}
+
+// GetUserAddresses godoc
+// @Summary Get a user's addresses
+// @Description Get the delivery addresses of a user
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.GenericResponse[[]models.Address]
+// @Router /admin/users/{id}/addresses [get]
+func (h *AdminUserHandler) GetUserAddresses(c *gin.Context) {
+	user, ok := h.getUser(c)
+	if !ok {
+		return
+	}
+
+	addresses, err := h.addressService.GetUserAddresses(user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to fetch addresses",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[[]models.Address]{
+		Success: true,
+		Message: "Addresses fetched successfully",
+		Data:    addresses,
+	})
+}
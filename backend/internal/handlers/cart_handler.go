@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -49,7 +50,7 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 
 // AddToCart godoc
 // @Summary Add item to cart
-// @Description Add a menu item to user's cart
+// @Description Add a menu item with its options and notes to user's cart. Items from a different restaurant are rejected unless replace is set.
 // @Tags cart
 // @Accept json
 // @Produce json
@@ -57,8 +58,8 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 // @Router /cart/items [post]
 func (h *CartHandler) AddToCart(c *gin.Context) {
 	var input struct {
-		MenuItemID uint `json:"menu_item_id" binding:"required"`
-		Quantity   int  `json:"quantity" binding:"required,min=1"`
+		services.CartItemInput
+		Replace bool `json:"replace"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -71,7 +72,23 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 	}
 
 	userID := utils.GetUserID(c)
-	err := h.service.AddToCart(userID, input.MenuItemID, input.Quantity)
+	err := h.service.AddToCart(userID, input.CartItemInput, input.Replace)
+	if errors.Is(err, services.ErrInvalidOptions) {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid options",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "invalid_options"}},
+		})
+		return
+	}
+	if errors.Is(err, services.ErrCartRestaurantMismatch) {
+		c.JSON(http.StatusConflict, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Your cart contains items from another restaurant",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "restaurant_mismatch"}},
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
 			Success: false,
@@ -121,9 +138,9 @@ func (h *CartHandler) UpdateCartItem(c *gin.Context) {
 		return
 	}
 
-	err = h.service.UpdateCartItemQuantity(uint(itemID), input.Quantity)
+	err = h.service.UpdateCartItemQuantity(utils.GetUser(c), uint(itemID), input.Quantity)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+		c.JSON(resourceErrorStatus(err), utils.GenericResponse[any]{
 			Success: false,
 			Message: "Failed to update cart item",
 			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
@@ -159,9 +176,9 @@ func (h *CartHandler) RemoveFromCart(c *gin.Context) {
 		return
 	}
 
-	err = h.service.RemoveFromCart(uint(itemID))
+	err = h.service.RemoveFromCart(utils.GetUser(c), uint(itemID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+		c.JSON(resourceErrorStatus(err), utils.GenericResponse[any]{
 			Success: false,
 			Message: "Failed to remove item from cart",
 			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
@@ -213,3 +230,63 @@ func (h *CartHandler) ClearCart(c *gin.Context) {
 		Message: "Cart cleared successfully",
 	})
 }
+
+// ApplyPromotion godoc
+// @Summary Apply a promo code to the cart
+// @Description Apply a promo code to the user's cart and return the discounted price breakdown
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Success 200 {object} services.PriceQuote
+// @Router /cart/promo [post]
+func (h *CartHandler) ApplyPromotion(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	quote, err := h.service.ApplyPromotion(utils.GetUserID(c), input.Code)
+	if err != nil {
+		respondPromotionError(c, "Failed to apply promo code", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[*services.PriceQuote]{
+		Success: true,
+		Message: "Promo code applied successfully",
+		Data:    quote,
+	})
+}
+
+// RemovePromotion godoc
+// @Summary Remove the promo code from the cart
+// @Tags cart
+// @Produce json
+// @Success 200 {object} models.Cart
+// @Router /cart/promo [delete]
+func (h *CartHandler) RemovePromotion(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	if err := h.service.RemovePromotion(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to remove promo code",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	cart, _ := h.service.GetUserCart(userID)
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Promo code removed successfully",
+		Data:    cart,
+	})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/manjurulhoque/foodie/backend/internal/db/migrations"
+	"github.com/manjurulhoque/foodie/backend/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// readinessTimeout bounds the database checks so a hung database fails the probe
+// instead of stalling it
+const readinessTimeout = 2 * time.Second
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	db        *gorm.DB
+	migrator  *migrations.Migrator
+	uploadDir string
+}
+
+func NewHealthHandler(db *gorm.DB, migrator *migrations.Migrator, uploadDir string) *HealthHandler {
+	return &HealthHandler{db: db, migrator: migrator, uploadDir: uploadDir}
+}
+
+// Healthz is the liveness probe; it succeeds while the process can serve requests
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "ok",
+	})
+}
+
+// Readyz is the readiness probe. It checks that the database answers, its migrations
+// are applied and the upload directory is writable, and answers 503 otherwise.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]string{}
+	var errs []utils.ErrorDetail
+	// The probe is unauthenticated, so the details of a failure are only logged
+	record := func(name string, err error, reason string) {
+		if err != nil {
+			slog.Error("Readiness check failed", "check", name, "error", err)
+			checks[name] = "failed"
+			errs = append(errs, utils.ErrorDetail{Code: name, Message: reason})
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	record("database", h.pingDB(ctx), "database is unreachable")
+	record("migrations", h.migrator.CheckContext(ctx), "database migrations are not up to date")
+	record("uploads", h.checkUploadDir(), "upload directory is not writable")
+
+	if len(errs) > 0 {
+		c.JSON(http.StatusServiceUnavailable, utils.GenericResponse[map[string]string]{
+			Success: false,
+			Message: "Not ready",
+			Data:    checks,
+			Errors:  errs,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[map[string]string]{
+		Success: true,
+		Message: "Ready",
+		Data:    checks,
+	})
+}
+
+func (h *HealthHandler) pingDB(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkUploadDir creates and removes a file, which also catches read-only mounts
+func (h *HealthHandler) checkUploadDir() error {
+	f, err := os.CreateTemp(h.uploadDir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		os.Remove(name)
+		return err
+	}
+	return os.Remove(name)
+}
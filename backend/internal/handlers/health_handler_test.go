@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/db/dbtest"
+	"github.com/manjurulhoque/foodie/backend/internal/db/migrations"
+	"github.com/manjurulhoque/foodie/backend/pkg/utils"
+)
+
+func probe(t *testing.T, handler *HealthHandler, path string) (int, utils.GenericResponse[map[string]string]) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/healthz", handler.Healthz)
+	router.GET("/readyz", handler.Readyz)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var response utils.GenericResponse[map[string]string]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return w.Code, response
+}
+
+func TestHealthProbes(t *testing.T) {
+	database := dbtest.Open(t)
+	migrator, err := migrations.New(database)
+	require.NoError(t, err)
+	uploadDir := t.TempDir()
+	handler := NewHealthHandler(database, migrator, uploadDir)
+
+	code, _ := probe(t, handler, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	t.Run("pending migrations", func(t *testing.T) {
+		code, response := probe(t, handler, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "failed", response.Data["migrations"])
+		assert.Equal(t, "ok", response.Data["database"])
+		require.Len(t, response.Errors, 1)
+		assert.Equal(t, "migrations", response.Errors[0].Code)
+		assert.Equal(t, "database migrations are not up to date", response.Errors[0].Message, "the pending versions are not exposed")
+	})
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	t.Run("ready", func(t *testing.T) {
+		code, response := probe(t, handler, "/readyz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, map[string]string{"database": "ok", "migrations": "ok", "uploads": "ok"}, response.Data)
+		entries, err := filepath.Glob(filepath.Join(uploadDir, "*"))
+		require.NoError(t, err)
+		assert.Empty(t, entries, "the probe file is removed")
+	})
+
+	t.Run("missing upload directory", func(t *testing.T) {
+		handler := NewHealthHandler(database, migrator, filepath.Join(uploadDir, "missing"))
+		code, response := probe(t, handler, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "failed", response.Data["uploads"])
+	})
+
+	t.Run("closed database", func(t *testing.T) {
+		sqlDB, err := database.DB()
+		require.NoError(t, err)
+		require.NoError(t, sqlDB.Close())
+
+		code, _ := probe(t, handler, "/healthz")
+		assert.Equal(t, http.StatusOK, code, "liveness does not depend on the database")
+		code, response := probe(t, handler, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "failed", response.Data["database"])
+	})
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
+	"github.com/manjurulhoque/foodie/backend/internal/services"
+	"github.com/manjurulhoque/foodie/backend/pkg/utils"
+)
+
+type MembershipHandler struct {
+	service services.MembershipService
+}
+
+func NewMembershipHandler(service services.MembershipService) *MembershipHandler {
+	return &MembershipHandler{service: service}
+}
+
+// InviteMember godoc
+// @Summary Invite a user to a restaurant
+// @Description Invite someone by email to join the restaurant as manager or staff. Managers can only invite staff. The returned token is only shown once.
+// @Tags restaurants
+// @Accept json
+// @Produce json
+// @Param id path int true "Restaurant ID"
+// @Success 201 {object} utils.GenericResponse[any]
+// @Router /restaurants/{id}/invitations [post]
+func (h *MembershipHandler) InviteMember(c *gin.Context) {
+	restaurantID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+		Role  string `json:"role" binding:"required,oneof=manager staff"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	invitation, token, err := h.service.InviteMember(uint(restaurantID), utils.GetUser(c), input.Email, input.Role)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidMemberRole):
+			status = http.StatusBadRequest
+		case errors.Is(err, services.ErrManagerStaffOnly), errors.Is(err, policy.ErrForbidden):
+			status = http.StatusForbidden
+		case errors.Is(err, services.ErrAlreadyMember):
+			status = http.StatusConflict
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to invite member",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Invitation created successfully",
+		Data: map[string]interface{}{
+			"invitation": invitation,
+			"token":      token,
+		},
+	})
+}
+
+// GetInvitations godoc
+// @Summary Get pending invitations of a restaurant
+// @Tags restaurants
+// @Produce json
+// @Param id path int true "Restaurant ID"
+// @Success 200 {object} utils.GenericResponse[[]models.RestaurantInvitation]
+// @Router /restaurants/{id}/invitations [get]
+func (h *MembershipHandler) GetInvitations(c *gin.Context) {
+	restaurantID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	invitations, err := h.service.GetPendingInvitations(uint(restaurantID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to get invitations",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[[]models.RestaurantInvitation]{
+		Success: true,
+		Message: "Invitations retrieved successfully",
+		Data:    invitations,
+	})
+}
+
+// GetMembers godoc
+// @Summary Get members of a restaurant
+// @Tags restaurants
+// @Produce json
+// @Param id path int true "Restaurant ID"
+// @Success 200 {object} utils.GenericResponse[[]models.RestaurantMember]
+// @Router /restaurants/{id}/members [get]
+func (h *MembershipHandler) GetMembers(c *gin.Context) {
+	restaurantID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	members, err := h.service.GetMembers(uint(restaurantID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to get members",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[[]models.RestaurantMember]{
+		Success: true,
+		Message: "Members retrieved successfully",
+		Data:    members,
+	})
+}
+
+// RemoveMember godoc
+// @Summary Remove a member from a restaurant
+// @Description Managers can only remove staff
+// @Tags restaurants
+// @Produce json
+// @Param id path int true "Restaurant ID"
+// @Param memberId path int true "Member ID"
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /restaurants/{id}/members/{memberId} [delete]
+func (h *MembershipHandler) RemoveMember(c *gin.Context) {
+	restaurantID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	memberID, err := strconv.ParseUint(c.Param("memberId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid member ID",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	if err := h.service.RemoveMember(uint(restaurantID), utils.GetUser(c), uint(memberID)); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrManagerStaffOnly), errors.Is(err, policy.ErrForbidden):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to remove member",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Member removed successfully",
+	})
+}
+
+// AcceptInvitation godoc
+// @Summary Accept a restaurant invitation
+// @Description Join a restaurant using an invitation token sent to the authenticated user's email
+// @Tags restaurants
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[models.RestaurantMember]
+// @Router /invitations/accept [post]
+func (h *MembershipHandler) AcceptInvitation(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	member, err := h.service.AcceptInvitation(utils.GetUser(c), input.Token)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvitationNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrInvitationExpired):
+			status = http.StatusGone
+		case errors.Is(err, services.ErrInvitationEmailMismatch):
+			status = http.StatusForbidden
+		case errors.Is(err, services.ErrManagerStaffOnly), errors.Is(err, policy.ErrForbidden):
+			status = http.StatusForbidden
+		case errors.Is(err, services.ErrAlreadyMember):
+			status = http.StatusConflict
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to accept invitation",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[models.RestaurantMember]{
+		Success: true,
+		Message: "Invitation accepted successfully",
+		Data:    *member,
+	})
+}
+
+// GetMyMemberships godoc
+// @Summary Get restaurants the authenticated user works at
+// @Tags restaurants
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[[]models.RestaurantMember]
+// @Router /staff/restaurants [get]
+func (h *MembershipHandler) GetMyMemberships(c *gin.Context) {
+	members, err := h.service.GetUserMemberships(utils.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to get memberships",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[[]models.RestaurantMember]{
+		Success: true,
+		Message: "Memberships retrieved successfully",
+		Data:    members,
+	})
+}
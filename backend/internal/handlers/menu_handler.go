@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"mime/multipart"
@@ -19,12 +20,13 @@ import (
 )
 
 type MenuHandler struct {
-	service services.MenuService
-	db      *gorm.DB
+	service   services.MenuService
+	db        *gorm.DB
+	uploadDir string
 }
 
-func NewMenuHandler(service services.MenuService, db *gorm.DB) *MenuHandler {
-	return &MenuHandler{service: service, db: db}
+func NewMenuHandler(service services.MenuService, db *gorm.DB, uploadDir string) *MenuHandler {
+	return &MenuHandler{service: service, db: db, uploadDir: uploadDir}
 }
 
 // GetMenuItem menu handler
@@ -36,7 +38,7 @@ func NewMenuHandler(service services.MenuService, db *gorm.DB) *MenuHandler {
 // @Success 200 {object} any
 // @Router /menu/:id [get]
 func (h *MenuHandler) GetMenuItem(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
 			Success: false,
@@ -207,7 +209,7 @@ func (h *MenuHandler) CreateMenuItem(c *gin.Context) {
 	// Handle file upload
 	if menuItem.Image != nil {
 		// Define the path where files should be saved
-		uploadsPath := "./web/uploads"
+		uploadsPath := h.uploadDir
 
 		// Check if the uploads directory exists; create it if it doesn't
 		if _, err := os.Stat(uploadsPath); os.IsNotExist(err) {
@@ -228,7 +230,7 @@ func (h *MenuHandler) CreateMenuItem(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "status": false})
 			return
 		}
-		menuItemMap["image"] = filePath
+		menuItemMap["image"] = uploadURL(newFileName)
 	}
 
 	if err := h.service.CreateMenuItem(menuItemMap, uint(restaurantID)); err != nil {
@@ -274,6 +276,18 @@ func (h *MenuHandler) UpdateMenuItem(c *gin.Context) {
 		return
 	}
 
+	// The route only authorizes the restaurant, so the item has to belong to it
+	restaurantID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	existing, err := h.service.GetMenuItem(menuItemInput.ID)
+	if err != nil || existing.RestaurantID != uint(restaurantID) {
+		c.JSON(http.StatusNotFound, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Menu item not found",
+			Errors:  []utils.ErrorDetail{{Message: "Menu item not found"}},
+		})
+		return
+	}
+
 	menuItemMap := map[string]interface{}{
 		"name":          menuItemInput.Name,
 		"description":   menuItemInput.Description,
@@ -285,7 +299,7 @@ func (h *MenuHandler) UpdateMenuItem(c *gin.Context) {
 	// Handle file upload
 	if menuItemInput.Image != nil {
 		// Define the path where files should be saved
-		uploadsPath := "./web/uploads"
+		uploadsPath := h.uploadDir
 
 		// Check if the uploads directory exists; create it if it doesn't
 		if _, err := os.Stat(uploadsPath); os.IsNotExist(err) {
@@ -307,7 +321,7 @@ func (h *MenuHandler) UpdateMenuItem(c *gin.Context) {
 			return
 		}
 
-		menuItemMap["image"] = filePath
+		menuItemMap["image"] = uploadURL(newFileName)
 	}
 
 	menuItem, err := h.service.UpdateMenuItem(menuItemInput.ID, menuItemMap)
@@ -325,3 +339,61 @@ func (h *MenuHandler) UpdateMenuItem(c *gin.Context) {
 		Data:    menuItem,
 	})
 }
+
+// SetModifierGroups menu handler
+// @Summary Set the modifier groups of a menu item
+// @Description Replace the modifier groups and options of a menu item, such as sizes and add-ons. Groups and options sent with their id are updated, those left out are removed.
+// @Tags menu
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.MenuItem
+// @Router /restaurants/{id}/menu/{itemId}/modifiers [put]
+func (h *MenuHandler) SetModifierGroups(c *gin.Context) {
+	var input struct {
+		Groups []services.ModifierGroupInput `json:"groups" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	// The route only authorizes the restaurant, so the item has to belong to it
+	restaurantID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	menuItemID, _ := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	existing, err := h.service.GetMenuItem(uint(menuItemID))
+	if err != nil || existing.RestaurantID != uint(restaurantID) {
+		c.JSON(http.StatusNotFound, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Menu item not found",
+			Errors:  []utils.ErrorDetail{{Message: "Menu item not found"}},
+		})
+		return
+	}
+
+	menuItem, err := h.service.SetModifierGroups(existing.ID, input.Groups)
+	if errors.Is(err, services.ErrInvalidModifiers) {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid modifier groups",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "invalid_modifiers"}},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to update modifier groups",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Modifier groups updated",
+		Data:    menuItem,
+	})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/manjurulhoque/foodie/backend/internal/oidc"
+	"github.com/manjurulhoque/foodie/backend/internal/services"
+	"github.com/manjurulhoque/foodie/backend/pkg/utils"
+)
+
+type OIDCHandler struct {
+	service services.OIDCService
+}
+
+func NewOIDCHandler(service services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{service: service}
+}
+
+// GetProviders godoc
+// @Summary List login providers
+// @Description Names of the OpenID providers users can log in with
+// @Tags auth
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[[]string]
+// @Router /auth/oidc/providers [get]
+func (h *OIDCHandler) GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, utils.GenericResponse[[]string]{
+		Success: true,
+		Data:    h.service.Providers(),
+	})
+}
+
+// BeginLogin godoc
+// @Summary Start a login with an OpenID provider
+// @Description Returns the provider URL to send the user to and the state to compare with the one in the redirect back
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} utils.GenericResponse[services.OIDCLogin]
+// @Router /auth/oidc/{provider}/login [get]
+func (h *OIDCHandler) BeginLogin(c *gin.Context) {
+	login, err := h.service.BeginLogin(c.Request.Context(), c.Param("provider"))
+	if errors.Is(err, services.ErrUnknownProvider) {
+		c.JSON(http.StatusNotFound, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Unknown login provider",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Error starting OpenID login", "provider", c.Param("provider"), "error", err)
+		c.JSON(http.StatusBadGateway, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Login provider is unavailable",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[*services.OIDCLogin]{
+		Success: true,
+		Data:    login,
+	})
+}
+
+// Callback godoc
+// @Summary Finish a login with an OpenID provider
+// @Description Exchange the code and state from the provider's redirect for an access and refresh token, or a two-factor challenge
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /auth/oidc/{provider}/callback [post]
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	var input struct {
+		Code  string `json:"code" binding:"required"`
+		State string `json:"state" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	accessToken, refreshToken, err := h.service.CompleteLogin(c.Request.Context(), c.Param("provider"), input.State, input.Code)
+	var twoFactorErr *services.TwoFactorRequiredError
+	if errors.As(err, &twoFactorErr) {
+		c.JSON(http.StatusOK, utils.GenericResponse[any]{
+			Success: true,
+			Message: "Two-factor authentication code required",
+			Data: gin.H{
+				"two_factor_required": true,
+				"challenge":           twoFactorErr.ChallengeToken,
+			},
+		})
+		return
+	}
+	if err != nil {
+		status, code := http.StatusInternalServerError, ""
+		switch {
+		case errors.Is(err, services.ErrUnknownProvider):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrInvalidOIDCState):
+			status, code = http.StatusBadRequest, "state"
+		case errors.Is(err, services.ErrOIDCEmailNotVerified):
+			status, code = http.StatusConflict, "email_not_verified"
+		case errors.Is(err, services.ErrOIDCEmailMissing):
+			status, code = http.StatusBadRequest, "email_missing"
+		case errors.Is(err, services.ErrAccountDisabled):
+			status, code = http.StatusForbidden, "account_disabled"
+		case errors.Is(err, oidc.ErrInvalidIDToken):
+			status = http.StatusUnauthorized
+		default:
+			slog.Error("Error completing OpenID login", "provider", c.Param("provider"), "error", err)
+			status = http.StatusBadGateway
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Login failed",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: code}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Login successful",
+		Data: gin.H{
+			"access":  accessToken,
+			"refresh": refreshToken,
+		},
+	})
+}
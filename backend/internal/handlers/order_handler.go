@@ -1,9 +1,11 @@
 package handlers
 
 import (
-	"log/slog"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/manjurulhoque/foodie/backend/internal/models"
@@ -14,13 +16,13 @@ import (
 )
 
 type OrderHandler struct {
-	service     services.OrderService
-	cartService services.CartService
-	db          *gorm.DB
+	service        services.OrderService
+	paymentService services.PaymentService
+	db             *gorm.DB
 }
 
-func NewOrderHandler(service services.OrderService, cartService services.CartService, db *gorm.DB) *OrderHandler {
-	return &OrderHandler{service: service, cartService: cartService, db: db}
+func NewOrderHandler(service services.OrderService, paymentService services.PaymentService, db *gorm.DB) *OrderHandler {
+	return &OrderHandler{service: service, paymentService: paymentService, db: db}
 }
 
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
@@ -28,7 +30,6 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		DeliveryAddress string  `json:"delivery_address" binding:"required"`
 		PaymentMethod   string  `json:"payment_method" binding:"required"`
 		TotalPrice      float64 `json:"total_price" binding:"required"`
-		RestaurantID    uint    `json:"restaurant_id" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&orderInput); err != nil {
 		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
@@ -39,56 +40,76 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	userID := utils.GetUserID(c)
-
-	// Get user's cart
-	cart, err := h.cartService.GetUserCart(userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+	if !h.paymentService.Supports(orderInput.PaymentMethod) {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
 			Success: false,
-			Message: "Failed to get cart",
-			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+			Message: "Unsupported payment method",
+			Errors: []utils.ErrorDetail{{
+				Message: fmt.Sprintf("payment method must be one of %s", strings.Join(h.paymentService.Methods(), ", ")),
+				Code:    "unsupported_payment_method",
+			}},
 		})
 		return
 	}
 
-	// Create order items from cart items
-	var orderItems []models.OrderItem
-	for _, cartItem := range cart.Items {
-		orderItems = append(orderItems, models.OrderItem{
-			MenuItemID: cartItem.MenuItemID,
-			Quantity:   cartItem.Quantity,
-			Price:      cartItem.MenuItem.Price,
-		})
-	}
+	userID := utils.GetUserID(c)
 
-	// Create order
-	order := &models.Order{
+	// Price, validate and place the order from the user's cart in one transaction
+	order, quote, err := h.service.Checkout(services.CheckoutInput{
 		UserID:          userID,
-		RestaurantID:    orderInput.RestaurantID,
 		DeliveryAddress: orderInput.DeliveryAddress,
 		PaymentMethod:   orderInput.PaymentMethod,
-		TotalAmount:     orderInput.TotalPrice,
-		Status:          "pending",
-		PaymentStatus:   "pending",
-		Items:           orderItems,
-	}
-
-	if err := h.service.CreateOrder(order); err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
-			Success: false,
-			Message: "Failed to create order",
-			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
-		})
+		ClientTotal:     orderInput.TotalPrice,
+	})
+	if err != nil {
+		var checkoutErr *services.CheckoutError
+		switch {
+		case errors.As(err, &checkoutErr):
+			errs := make([]utils.ErrorDetail, 0, len(checkoutErr.Issues))
+			for _, issue := range checkoutErr.Issues {
+				errs = append(errs, utils.ErrorDetail{
+					Message: fmt.Sprintf("%s is %s", issue.Name, issue.Reason),
+					Code:    checkoutErr.Code,
+				})
+			}
+			if len(errs) == 0 {
+				errs = append(errs, utils.ErrorDetail{Message: checkoutErr.Message, Code: checkoutErr.Code})
+			}
+			c.JSON(http.StatusConflict, utils.GenericResponse[*services.CheckoutError]{
+				Success: false,
+				Message: checkoutErr.Message,
+				Data:    checkoutErr,
+				Errors:  errs,
+			})
+		case errors.Is(err, services.ErrEmptyCart):
+			c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+				Success: false,
+				Message: "Cart is empty",
+				Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+			})
+		case errors.Is(err, services.ErrMixedCart):
+			c.JSON(http.StatusConflict, utils.GenericResponse[any]{
+				Success: false,
+				Message: "Cart contains items from more than one restaurant",
+				Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "restaurant_mismatch"}},
+			})
+		case errors.Is(err, services.ErrPriceMismatch):
+			c.JSON(http.StatusUnprocessableEntity, utils.GenericResponse[*services.PriceQuote]{
+				Success: false,
+				Message: "Order total does not match current prices",
+				Data:    quote,
+				Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "price_mismatch"}},
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+				Success: false,
+				Message: "Failed to create order",
+				Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+			})
+		}
 		return
 	}
 
-	// Clear the cart after successful order creation
-	if err := h.cartService.ClearCart(cart.ID); err != nil {
-		// Log the error but don't fail the request
-		slog.Error("Failed to clear cart after order creation", "error", err.Error())
-	}
-
 	c.JSON(http.StatusCreated, utils.GenericResponse[models.Order]{
 		Success: true,
 		Message: "Order created successfully",
@@ -116,6 +137,59 @@ func (h *OrderHandler) GetUserOrders(c *gin.Context) {
 	})
 }
 
+// CancelOrder godoc
+// @Summary Cancel an order
+// @Description Cancel one of your orders while it is pending, or accepted and still inside the restaurant's cancellation window
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} utils.GenericResponse[models.Order]
+// @Router /orders/{id}/cancel [post]
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid order ID",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "A cancellation reason is required",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	order, err := h.service.CancelOrder(uint(orderID), utils.GetUser(c), input.Reason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, utils.GenericResponse[any]{
+				Success: false,
+				Message: "Order not found",
+				Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+			})
+			return
+		}
+		respondTransitionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[models.Order]{
+		Success: true,
+		Message: "Order cancelled successfully",
+		Data:    *order,
+	})
+}
+
 // GetOrderStatusHistory gets the status history for an order
 func (h *OrderHandler) GetOrderStatusHistory(c *gin.Context) {
 	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
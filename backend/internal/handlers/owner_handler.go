@@ -1,14 +1,17 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
 	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
 	"github.com/manjurulhoque/foodie/backend/internal/services"
 	"github.com/manjurulhoque/foodie/backend/pkg/utils"
 )
@@ -16,11 +19,12 @@ import (
 type OwnerHandler struct {
 	restaurantService services.RestaurantService
 	orderService      services.OrderService
+	paymentService    services.PaymentService
 	db                *gorm.DB
 }
 
-func NewOwnerHandler(restaurantService services.RestaurantService, orderService services.OrderService, db *gorm.DB) *OwnerHandler {
-	return &OwnerHandler{restaurantService: restaurantService, orderService: orderService, db: db}
+func NewOwnerHandler(restaurantService services.RestaurantService, orderService services.OrderService, paymentService services.PaymentService, db *gorm.DB) *OwnerHandler {
+	return &OwnerHandler{restaurantService: restaurantService, orderService: orderService, paymentService: paymentService, db: db}
 }
 
 // GetRestaurants godoc
@@ -49,16 +53,68 @@ func (h *OwnerHandler) GetRestaurants(c *gin.Context) {
 	})
 }
 
-// GetOrders godoc
-// @Summary Get orders for a restaurant
-// @Description Get all orders for a restaurant
+// GetAllOrders godoc
+// @Summary Get orders for the caller's restaurants
+// @Description Get orders placed at restaurants the authenticated user owns or works at
 // @Tags orders
 // @Accept json
 // @Produce json
-// @Success 200 {object} utils.GenericResponse[[]models.Order]
+// @Param status query string false "Order status"
+// @Param restaurant_id query int false "Restaurant ID"
+// @Param from query string false "Start date (YYYY-MM-DD)"
+// @Param to query string false "End date, inclusive (YYYY-MM-DD)"
+// @Param page query int false "Page"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} utils.GenericResponse[any]
 // @Router /owner/orders [get]
+// @Router /staff/orders [get]
 func (h *OwnerHandler) GetAllOrders(c *gin.Context) {
-	orders, err := h.orderService.GetAllOrders()
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	restaurantID, _ := strconv.Atoi(c.DefaultQuery("restaurant_id", "0"))
+
+	if page < 1 {
+		page = 1
+	}
+	// we won't allow more than 100 items at a time
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	filter := repositories.OrderFilter{
+		Status:       c.Query("status"),
+		RestaurantID: uint(restaurantID),
+		Page:         page,
+		Limit:        limit,
+	}
+	if from := c.Query("from"); from != "" {
+		date, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+				Success: false,
+				Message: "Invalid from date, expected YYYY-MM-DD",
+				Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+			})
+			return
+		}
+		filter.From = &date
+	}
+	if to := c.Query("to"); to != "" {
+		date, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+				Success: false,
+				Message: "Invalid to date, expected YYYY-MM-DD",
+				Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+			})
+			return
+		}
+		// include the whole "to" day
+		end := date.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+
+	orders, total, err := h.orderService.GetManagedOrders(utils.GetUserID(c), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
 			Success: false,
@@ -67,19 +123,34 @@ func (h *OwnerHandler) GetAllOrders(c *gin.Context) {
 		})
 		return
 	}
-	c.JSON(http.StatusOK, utils.GenericResponse[[]models.Order]{
+
+	totalPages := (int(total) + limit - 1) / limit // Ceiling division
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
 		Success: true,
 		Message: "Orders retrieved successfully",
-		Data:    orders,
+		Data: map[string]interface{}{
+			"data": orders,
+			"meta": map[string]interface{}{
+				"total":      total,
+				"page":       page,
+				"limit":      limit,
+				"totalPages": totalPages,
+			},
+		},
 	})
 }
 
 // UpdateOrderStatus godoc
 // @Summary Update the status of an order
-// @Description Update the status of an order
+// @Description Move an order to its next status. Only transitions allowed by the order lifecycle for the caller's role are accepted. Owners may also set the payment status; orders with a recorded payment only accept "paid", which captures it.
 // @Tags orders
 // @Accept json
 // @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} utils.GenericResponse[models.Order]
+// @Router /owner/orders/{id} [put]
+// @Router /staff/orders/{id} [put]
 func (h *OwnerHandler) UpdateOrderStatus(c *gin.Context) {
 	orderID := c.Param("id")
 	orderIDUint, err := strconv.ParseUint(orderID, 10, 32)
@@ -91,18 +162,20 @@ func (h *OwnerHandler) UpdateOrderStatus(c *gin.Context) {
 		})
 		return
 	}
-	order, err := h.orderService.GetOrder(uint(orderIDUint))
+	// Owners and staff may only touch orders placed at their own restaurants
+	order, err := h.orderService.GetManagedOrder(uint(orderIDUint), utils.GetUserID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+		c.JSON(http.StatusNotFound, utils.GenericResponse[any]{
 			Success: false,
-			Message: "Failed to retrieve order",
+			Message: "Order not found",
 			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
 		})
 		return
 	}
 	var input struct {
-		Status        string `json:"status" binding:"required,oneof=pending preparing ready delivered cancelled"`
-		PaymentStatus string `json:"payment_status" binding:"required,oneof=pending paid failed"`
+		Status        string `json:"status" binding:"required"`
+		PaymentStatus string `json:"payment_status" binding:"omitempty,oneof=pending paid failed"`
+		Reason        string `json:"reason"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
@@ -112,33 +185,42 @@ func (h *OwnerHandler) UpdateOrderStatus(c *gin.Context) {
 		})
 		return
 	}
-	description := fmt.Sprintf("Restaurant owner updated the order status to %s from %s and payment status to %s from %s", input.Status, order.Status, input.PaymentStatus, order.PaymentStatus)
-	order.Status = input.Status
-	order.PaymentStatus = input.PaymentStatus
-	err = h.orderService.UpdateOrder(order)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+
+	paymentChanged := input.PaymentStatus != "" && input.PaymentStatus != order.PaymentStatus
+	if paymentChanged && !policy.Can(utils.GetUser(c), policy.ActionManagePayment, order) {
+		c.JSON(http.StatusForbidden, utils.GenericResponse[any]{
 			Success: false,
-			Message: "Failed to update order status",
-			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+			Message: "You are not allowed to change the payment status",
+			Errors:  []utils.ErrorDetail{{Message: policy.ErrForbidden.Error()}},
 		})
 		return
 	}
 
-	// Create status history entry
-	statusHistory := models.OrderStatusHistory{
-		OrderID:     uint(orderIDUint),
-		Status:      input.Status,
-		Description: description,
+	if input.Status != order.Status {
+		order, err = h.orderService.TransitionOrder(order.ID, services.TransitionRequest{
+			Actor:  utils.GetUser(c),
+			To:     input.Status,
+			Reason: input.Reason,
+		})
+		if err != nil {
+			respondTransitionError(c, err)
+			return
+		}
 	}
 
-	if err := h.db.Create(&statusHistory).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
-			Success: false,
-			Message: "Error saving status history",
-			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
-		})
-		return
+	// Marking an order paid captures its payment, so the money is recorded with the provider
+	if paymentChanged && input.PaymentStatus == models.PaymentStatusPaid {
+		if _, err := h.paymentService.Capture(c.Request.Context(), order); err != nil {
+			respondPaymentError(c, "Failed to capture payment", err)
+			return
+		}
+		order.PaymentStatus = input.PaymentStatus
+	} else if paymentChanged {
+		if err := h.paymentService.SetOrderPaymentStatus(order.ID, input.PaymentStatus); err != nil {
+			respondPaymentError(c, "Failed to update payment status", err)
+			return
+		}
+		order.PaymentStatus = input.PaymentStatus
 	}
 
 	c.JSON(http.StatusOK, utils.GenericResponse[models.Order]{
@@ -147,3 +229,21 @@ func (h *OwnerHandler) UpdateOrderStatus(c *gin.Context) {
 		Data:    *order,
 	})
 }
+
+// respondTransitionError maps order lifecycle errors to HTTP responses
+func respondTransitionError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrTransitionForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrCancellationClosed):
+		status = http.StatusConflict
+	case errors.Is(err, services.ErrReasonRequired):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, utils.GenericResponse[any]{
+		Success: false,
+		Message: "Failed to update order status",
+		Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+	})
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/payments"
+	"github.com/manjurulhoque/foodie/backend/internal/services"
+	"github.com/manjurulhoque/foodie/backend/pkg/utils"
+)
+
+// maxWebhookSize bounds webhook bodies; provider events are a few kilobytes
+const maxWebhookSize = 1 << 20
+
+type PaymentHandler struct {
+	service      services.PaymentService
+	orderService services.OrderService
+}
+
+func NewPaymentHandler(service services.PaymentService, orderService services.OrderService) *PaymentHandler {
+	return &PaymentHandler{service: service, orderService: orderService}
+}
+
+// GetMethods godoc
+// @Summary List payment methods
+// @Description Payment methods that can be chosen at checkout
+// @Tags payments
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[[]string]
+// @Router /payments/methods [get]
+func (h *PaymentHandler) GetMethods(c *gin.Context) {
+	c.JSON(http.StatusOK, utils.GenericResponse[[]string]{
+		Success: true,
+		Data:    h.service.Methods(),
+	})
+}
+
+// StartPayment godoc
+// @Summary Pay for an order
+// @Description Starts a payment for one of your orders with its payment method. An unfinished payment is returned instead of starting another; only a new payment includes the client secret.
+// @Tags payments
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} utils.GenericResponse[models.Payment]
+// @Router /orders/{id}/payments [post]
+func (h *PaymentHandler) StartPayment(c *gin.Context) {
+	order, ok := h.loadOrder(c)
+	if !ok {
+		return
+	}
+	payment, err := h.service.StartPayment(c.Request.Context(), order)
+	if err != nil {
+		respondPaymentError(c, "Failed to start payment", err)
+		return
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[models.Payment]{
+		Success: true,
+		Message: "Payment started",
+		Data:    *payment,
+	})
+}
+
+// GetOrderPayments godoc
+// @Summary List an order's payments
+// @Tags payments
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} utils.GenericResponse[[]models.Payment]
+// @Router /orders/{id}/payments [get]
+func (h *PaymentHandler) GetOrderPayments(c *gin.Context) {
+	order, ok := h.loadOrder(c)
+	if !ok {
+		return
+	}
+	orderPayments, err := h.service.GetOrderPayments(order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to fetch payments",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[[]models.Payment]{
+		Success: true,
+		Message: "Payments fetched successfully",
+		Data:    orderPayments,
+	})
+}
+
+// CapturePayment godoc
+// @Summary Capture an order's payment
+// @Description Takes the money of an order's open payment, e.g. once cash was collected on delivery
+// @Tags payments
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} utils.GenericResponse[models.Payment]
+// @Router /orders/{id}/payments/capture [post]
+func (h *PaymentHandler) CapturePayment(c *gin.Context) {
+	order, ok := h.loadOrder(c)
+	if !ok {
+		return
+	}
+	payment, err := h.service.Capture(c.Request.Context(), order)
+	if err != nil {
+		respondPaymentError(c, "Failed to capture payment", err)
+		return
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[models.Payment]{
+		Success: true,
+		Message: "Payment captured",
+		Data:    *payment,
+	})
+}
+
+// RefundOrder godoc
+// @Summary Refund an order
+// @Description Returns money from an order's captured payment. Without items everything still refundable is returned; with items only those quantities, including their share of tax.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Param refund body object true "Refund reason and optional items"
+// @Success 201 {object} utils.GenericResponse[models.Refund]
+// @Router /owner/orders/{id}/refunds [post]
+// @Router /admin/orders/{id}/refunds [post]
+func (h *PaymentHandler) RefundOrder(c *gin.Context) {
+	order, ok := h.loadOrder(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Reason string                       `json:"reason" binding:"required,max=500"`
+		Items  []services.RefundItemRequest `json:"items" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "A refund reason is required",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	refund, err := h.service.Refund(c.Request.Context(), order, services.RefundRequest{
+		Actor:  utils.GetUser(c),
+		Reason: input.Reason,
+		Items:  input.Items,
+	})
+	if err != nil {
+		respondPaymentError(c, "Failed to refund order", err)
+		return
+	}
+	c.JSON(http.StatusCreated, utils.GenericResponse[models.Refund]{
+		Success: true,
+		Message: "Order refunded",
+		Data:    *refund,
+	})
+}
+
+// GetOrderRefunds godoc
+// @Summary List an order's refunds
+// @Tags payments
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} utils.GenericResponse[[]models.Refund]
+// @Router /orders/{id}/refunds [get]
+func (h *PaymentHandler) GetOrderRefunds(c *gin.Context) {
+	order, ok := h.loadOrder(c)
+	if !ok {
+		return
+	}
+	refunds, err := h.service.GetOrderRefunds(order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to fetch refunds",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[[]models.Refund]{
+		Success: true,
+		Message: "Refunds fetched successfully",
+		Data:    refunds,
+	})
+}
+
+// Webhook godoc
+// @Summary Receive a payment provider webhook
+// @Description Applies a signed payment event. Repeated events are acknowledged without effect.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /payments/webhooks/{provider} [post]
+func (h *PaymentHandler) Webhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request body",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	if err := h.service.HandleWebhook(c.Request.Context(), c.Param("provider"), payload, c.Request.Header); err != nil {
+		respondPaymentError(c, "Failed to process webhook", err)
+		return
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Webhook processed",
+	})
+}
+
+// loadOrder fetches the order of the "id" route param; access is checked by the
+// policy middleware on the route
+func (h *PaymentHandler) loadOrder(c *gin.Context) (*models.Order, bool) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid order ID",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return nil, false
+	}
+	order, err := h.orderService.GetOrder(uint(orderID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Order not found",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return nil, false
+	}
+	return order, true
+}
+
+// respondPaymentError maps payment errors to HTTP responses
+func respondPaymentError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	code := ""
+	switch {
+	case errors.Is(err, services.ErrUnsupportedPaymentMethod), errors.Is(err, services.ErrUnknownPayment):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidWebhook):
+		status = http.StatusBadRequest
+		code = "invalid_webhook"
+	case errors.Is(err, services.ErrOrderAlreadyPaid):
+		status = http.StatusConflict
+		code = "already_paid"
+	case errors.Is(err, services.ErrPaymentRecorded):
+		status = http.StatusConflict
+		code = "payment_recorded"
+	case errors.Is(err, services.ErrOrderNotPayable), errors.Is(err, payments.ErrNotCapturable):
+		status = http.StatusConflict
+		code = "not_payable"
+	case errors.Is(err, services.ErrRefundReasonRequired), errors.Is(err, services.ErrInvalidRefundItem):
+		status = http.StatusBadRequest
+		code = "invalid_refund"
+	case errors.Is(err, services.ErrNoCapturedPayment), errors.Is(err, services.ErrNothingToRefund),
+		errors.Is(err, payments.ErrRefundExceedsAmount):
+		status = http.StatusConflict
+		code = "not_refundable"
+	default:
+		slog.Error(message, "error", err)
+	}
+	c.JSON(status, utils.GenericResponse[any]{
+		Success: false,
+		Message: message,
+		Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: code}},
+	})
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/services"
+	"github.com/manjurulhoque/foodie/backend/pkg/utils"
+)
+
+type PromotionHandler struct {
+	service services.PromotionService
+}
+
+func NewPromotionHandler(service services.PromotionService) *PromotionHandler {
+	return &PromotionHandler{service: service}
+}
+
+// GetPromotions godoc
+// @Summary List promotions
+// @Description Admins see every promotion, restaurant owners those of their restaurants
+// @Tags promotions
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[[]models.Promotion]
+// @Router /admin/promotions [get]
+// @Router /owner/promotions [get]
+func (h *PromotionHandler) GetPromotions(c *gin.Context) {
+	promotions, err := h.service.GetPromotions(utils.GetUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to fetch promotions",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[[]models.Promotion]{
+		Success: true,
+		Message: "Promotions fetched successfully",
+		Data:    promotions,
+	})
+}
+
+// CreatePromotion godoc
+// @Summary Create a promotion
+// @Description Restaurant owners can only create promotions limited to one of their restaurants
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Param promotion body services.PromotionInput true "Promotion"
+// @Success 201 {object} utils.GenericResponse[models.Promotion]
+// @Router /admin/promotions [post]
+// @Router /owner/promotions [post]
+func (h *PromotionHandler) CreatePromotion(c *gin.Context) {
+	var input services.PromotionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request body",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	promotion, err := h.service.CreatePromotion(utils.GetUser(c), input)
+	if err != nil {
+		respondPromotionError(c, "Failed to create promotion", err)
+		return
+	}
+	c.JSON(http.StatusCreated, utils.GenericResponse[models.Promotion]{
+		Success: true,
+		Message: "Promotion created successfully",
+		Data:    *promotion,
+	})
+}
+
+// UpdatePromotion godoc
+// @Summary Replace a promotion
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Param id path int true "Promotion ID"
+// @Param promotion body services.PromotionInput true "Promotion"
+// @Success 200 {object} utils.GenericResponse[models.Promotion]
+// @Router /admin/promotions/{id} [put]
+// @Router /owner/promotions/{id} [put]
+func (h *PromotionHandler) UpdatePromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid promotion ID",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	var input services.PromotionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request body",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	promotion, err := h.service.UpdatePromotion(utils.GetUser(c), uint(id), input)
+	if err != nil {
+		respondPromotionError(c, "Failed to update promotion", err)
+		return
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[models.Promotion]{
+		Success: true,
+		Message: "Promotion updated successfully",
+		Data:    *promotion,
+	})
+}
+
+// DeletePromotion godoc
+// @Summary Delete a promotion
+// @Tags promotions
+// @Produce json
+// @Param id path int true "Promotion ID"
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /admin/promotions/{id} [delete]
+// @Router /owner/promotions/{id} [delete]
+func (h *PromotionHandler) DeletePromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid promotion ID",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	if err := h.service.DeletePromotion(utils.GetUser(c), uint(id)); err != nil {
+		respondPromotionError(c, "Failed to delete promotion", err)
+		return
+	}
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Promotion deleted successfully",
+	})
+}
+
+// promotionErrorCode returns the error code for a promo code that cannot be used,
+// or "" if err is about something else
+func promotionErrorCode(err error) string {
+	switch {
+	case errors.Is(err, services.ErrPromotionNotFound):
+		return "promotion_not_found"
+	case errors.Is(err, services.ErrPromotionNotActive):
+		return "promotion_not_active"
+	case errors.Is(err, services.ErrPromotionNotApplicable):
+		return "promotion_not_applicable"
+	case errors.Is(err, services.ErrPromotionMinSubtotal):
+		return "promotion_min_subtotal"
+	case errors.Is(err, services.ErrPromotionUsedUp):
+		return "promotion_used_up"
+	}
+	return ""
+}
+
+// respondPromotionError maps promotion errors to HTTP responses
+func respondPromotionError(c *gin.Context, message string, err error) {
+	status := resourceErrorStatus(err)
+	code := promotionErrorCode(err)
+	switch {
+	case code != "":
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrInvalidPromotion):
+		status = http.StatusBadRequest
+		code = "invalid_promotion"
+	case errors.Is(err, services.ErrPromotionCodeTaken):
+		status = http.StatusConflict
+		code = "promotion_code_taken"
+	case errors.Is(err, services.ErrEmptyCart):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, utils.GenericResponse[any]{
+		Success: false,
+		Message: message,
+		Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: code}},
+	})
+}
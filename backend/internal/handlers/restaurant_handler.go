@@ -22,12 +22,13 @@ import (
 )
 
 type RestaurantHandler struct {
-	service services.RestaurantService
-	db      *gorm.DB
+	service   services.RestaurantService
+	db        *gorm.DB
+	uploadDir string
 }
 
-func NewRestaurantHandler(service services.RestaurantService, db *gorm.DB) *RestaurantHandler {
-	return &RestaurantHandler{service: service, db: db}
+func NewRestaurantHandler(service services.RestaurantService, db *gorm.DB, uploadDir string) *RestaurantHandler {
+	return &RestaurantHandler{service: service, db: db, uploadDir: uploadDir}
 }
 
 // CreateRestaurant restaurant handler
@@ -90,7 +91,7 @@ func (h *RestaurantHandler) CreateRestaurant(c *gin.Context) {
 	// Handle image upload if provided
 	if input.Image != nil {
 		// Define the path where files should be saved
-		uploadsPath := "./web/uploads/restaurants"
+		uploadsPath := filepath.Join(h.uploadDir, "restaurants")
 
 		// Check if the uploads directory exists; create it if it doesn't
 		if _, err := os.Stat(uploadsPath); os.IsNotExist(err) {
@@ -121,7 +122,7 @@ func (h *RestaurantHandler) CreateRestaurant(c *gin.Context) {
 			c.Abort()
 			return
 		}
-		restaurant.Image = filePath
+		restaurant.Image = uploadURL("restaurants", newFileName)
 	}
 
 	// Start a transaction
@@ -306,6 +307,8 @@ func (h *RestaurantHandler) UpdateRestaurant(c *gin.Context) {
 		Email       string                `form:"email" json:"email" binding:"required,email"`
 		CuisineIDs  []uint                `form:"cuisine_ids[]" json:"cuisine_ids"`
 		Image       *multipart.FileHeader `form:"image" json:"image"`
+		// Minutes after ordering during which customers may still cancel an accepted order
+		CancellationWindowMinutes *int `form:"cancellation_window_minutes" json:"cancellation_window_minutes" binding:"omitempty,min=0,max=1440"`
 	}
 
 	if err := c.ShouldBind(&restaurantInput); err != nil {
@@ -375,10 +378,13 @@ func (h *RestaurantHandler) UpdateRestaurant(c *gin.Context) {
 	restaurant.Phone = restaurantInput.Phone
 	restaurant.Email = restaurantInput.Email
 	restaurant.UserID = existingRestaurant.UserID
+	if restaurantInput.CancellationWindowMinutes != nil {
+		restaurant.CancellationWindowMinutes = *restaurantInput.CancellationWindowMinutes
+	}
 
 	// Handle image upload if provided
 	if restaurantInput.Image != nil {
-		uploadsPath := "./web/uploads/restaurants"
+		uploadsPath := filepath.Join(h.uploadDir, "restaurants")
 		extension := filepath.Ext(restaurantInput.Image.Filename)
 		newFileName := fmt.Sprintf("%s%s", uuid.New().String(), extension)
 		filePath := filepath.Join(uploadsPath, newFileName)
@@ -394,7 +400,7 @@ func (h *RestaurantHandler) UpdateRestaurant(c *gin.Context) {
 		}
 		// TODO: delete old image
 		// check if the image path exists
-		restaurant.Image = filePath
+		restaurant.Image = uploadURL("restaurants", newFileName)
 	}
 
 	// Update the restaurant
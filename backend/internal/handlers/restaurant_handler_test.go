@@ -132,7 +132,7 @@ func setupTestRouter(h *RestaurantHandler) *gin.Engine {
 func TestGetAllRestaurants(t *testing.T) {
 	mockService := new(MockRestaurantService)
 	mockDB := &gorm.DB{}
-	handler := NewRestaurantHandler(mockService, mockDB)
+	handler := NewRestaurantHandler(mockService, mockDB, t.TempDir())
 	router := setupTestRouter(handler)
 
 	tests := []struct {
@@ -191,7 +191,7 @@ func TestGetAllRestaurants(t *testing.T) {
 func TestGetRestaurant(t *testing.T) {
 	mockService := new(MockRestaurantService)
 	mockDB := &gorm.DB{}
-	handler := NewRestaurantHandler(mockService, mockDB)
+	handler := NewRestaurantHandler(mockService, mockDB, t.TempDir())
 	router := setupTestRouter(handler)
 
 	tests := []struct {
@@ -257,7 +257,7 @@ func TestCreateRestaurant(t *testing.T) {
 	db.AutoMigrate(&models.Restaurant{}, &models.Cuisine{})
 
 	mockDB := &MockDB{DB: db}
-	handler := NewRestaurantHandler(mockService, mockDB.DB)
+	handler := NewRestaurantHandler(mockService, mockDB.DB, t.TempDir())
 
 	// Create a mock context with user ID
 	gin.SetMode(gin.TestMode)
@@ -379,7 +379,7 @@ func TestUpdateRestaurant(t *testing.T) {
 	db.AutoMigrate(&models.Restaurant{}, &models.Cuisine{})
 
 	mockDB := &MockDB{DB: db}
-	handler := NewRestaurantHandler(mockService, mockDB.DB)
+	handler := NewRestaurantHandler(mockService, mockDB.DB, t.TempDir())
 
 	// Create a mock context with user ID
 	gin.SetMode(gin.TestMode)
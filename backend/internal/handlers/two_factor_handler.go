@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/manjurulhoque/foodie/backend/internal/services"
+	"github.com/manjurulhoque/foodie/backend/pkg/utils"
+)
+
+type TwoFactorHandler struct {
+	service services.TwoFactorService
+}
+
+func NewTwoFactorHandler(service services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{service: service}
+}
+
+// twoFactorErrorStatus maps two-factor errors to HTTP status codes
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, services.ErrTwoFactorNotEnabled),
+		errors.Is(err, services.ErrTwoFactorNotStarted):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrTwoFactorRequiredByRole):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// bindCode reads the {"code": "..."} body shared by the two-factor endpoints
+func bindCode(c *gin.Context) (string, bool) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return "", false
+	}
+	return input.Code, true
+}
+
+// GetStatus godoc
+// @Summary Get two-factor status
+// @Description Whether two-factor authentication is enabled or required, and how many recovery codes are left
+// @Tags 2fa
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[services.TwoFactorStatus]
+// @Router /2fa [get]
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	status, err := h.service.GetStatus(utils.GetUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to get two-factor status",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[*services.TwoFactorStatus]{
+		Success: true,
+		Message: "Two-factor status retrieved successfully",
+		Data:    status,
+	})
+}
+
+// BeginSetup godoc
+// @Summary Start two-factor setup
+// @Description Generate an authenticator app secret and otpauth URL. Two-factor authentication is enabled once a code is confirmed.
+// @Tags 2fa
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[services.TOTPEnrollment]
+// @Router /2fa/setup [post]
+func (h *TwoFactorHandler) BeginSetup(c *gin.Context) {
+	enrollment, err := h.service.BeginEnrollment(utils.GetUser(c))
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to start two-factor setup",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[*services.TOTPEnrollment]{
+		Success: true,
+		Message: "Scan the code with your authenticator app and confirm with a code",
+		Data:    enrollment,
+	})
+}
+
+// ConfirmSetup godoc
+// @Summary Confirm two-factor setup
+// @Description Enable two-factor authentication with a code from the authenticator app. The recovery codes are only shown once.
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /2fa/confirm [post]
+func (h *TwoFactorHandler) ConfirmSetup(c *gin.Context) {
+	code, ok := bindCode(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.service.ConfirmEnrollment(utils.GetUser(c), code)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to enable two-factor authentication",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "code"}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Two-factor authentication enabled",
+		Data:    gin.H{"recovery_codes": recoveryCodes},
+	})
+}
+
+// Disable godoc
+// @Summary Disable two-factor authentication
+// @Description Turn two-factor authentication off with an app or recovery code. Not allowed for roles that require it.
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /2fa/disable [post]
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	code, ok := bindCode(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Disable(utils.GetUser(c), code); err != nil {
+		c.JSON(twoFactorErrorStatus(err), utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to disable two-factor authentication",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "code"}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes after checking an app or recovery code. The new codes are only shown once.
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /2fa/recovery-codes [post]
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	code, ok := bindCode(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.service.RegenerateRecoveryCodes(utils.GetUser(c), code)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to regenerate recovery codes",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "code"}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Recovery codes regenerated",
+		Data:    gin.H{"recovery_codes": recoveryCodes},
+	})
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import "path"
+
+// uploadsURLPath is where the router serves the upload directory. Images are stored
+// with this path, so moving the directory does not break existing records.
+const uploadsURLPath = "web/uploads"
+
+// uploadURL returns the stored path of an uploaded file relative to the upload directory
+func uploadURL(elem ...string) string {
+	return path.Join(append([]string{uploadsURLPath}, elem...)...)
+}
@@ -1,7 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/manjurulhoque/foodie/backend/internal/models"
@@ -12,12 +18,39 @@ import (
 )
 
 type UserHandler struct {
-	userService services.UserService
-	db          *gorm.DB
+	userService          services.UserService
+	verificationService  services.EmailVerificationService
+	passwordResetService services.PasswordResetService
+	db                   *gorm.DB
 }
 
-func NewUserHandler(userService services.UserService, db *gorm.DB) *UserHandler {
-	return &UserHandler{userService: userService, db: db}
+func NewUserHandler(
+	userService services.UserService,
+	verificationService services.EmailVerificationService,
+	passwordResetService services.PasswordResetService,
+	db *gorm.DB,
+) *UserHandler {
+	return &UserHandler{
+		userService:          userService,
+		verificationService:  verificationService,
+		passwordResetService: passwordResetService,
+		db:                   db,
+	}
+}
+
+// setRetryAfter tells the client how many seconds to wait before trying again
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+}
+
+// validationErrors runs the validate tags of input and returns the failures, if any
+func validationErrors(input any) []utils.ErrorDetail {
+	errs := utils.TranslateError(input)
+	details := make([]utils.ErrorDetail, len(errs))
+	for i, err := range errs {
+		details[i] = utils.ErrorDetail{Message: err.Message, Code: err.Field}
+	}
+	return details
 }
 
 // Register user handler
@@ -82,6 +115,11 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// The account exists either way; the user can ask for another email later
+	if err := h.verificationService.SendVerification(input.Email); err != nil {
+		slog.Error("Failed to send verification email", "email", input.Email, "error", err)
+	}
+
 	c.JSON(http.StatusCreated, utils.GenericResponse[any]{
 		Success: true,
 		Message: "User registered successfully",
@@ -112,7 +150,40 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, err := h.userService.LoginUser(input.Email, input.Password)
+	accessToken, refreshToken, err := h.userService.LoginUser(input.Email, input.Password, services.LoginClient{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	var lockedErr *services.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		setRetryAfter(c, lockedErr.RetryAfter)
+		c.JSON(http.StatusTooManyRequests, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Too many failed login attempts",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "locked"}},
+		})
+		return
+	}
+	if errors.Is(err, services.ErrAccountDisabled) {
+		c.JSON(http.StatusForbidden, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Account is deactivated",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "account_disabled"}},
+		})
+		return
+	}
+	var twoFactorErr *services.TwoFactorRequiredError
+	if errors.As(err, &twoFactorErr) {
+		c.JSON(http.StatusOK, utils.GenericResponse[any]{
+			Success: true,
+			Message: "Two-factor authentication code required",
+			Data: gin.H{
+				"two_factor_required": true,
+				"challenge":           twoFactorErr.ChallengeToken,
+			},
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, utils.GenericResponse[any]{
 			Success: false,
@@ -132,6 +203,393 @@ func (h *UserHandler) Login(c *gin.Context) {
 	})
 }
 
+// LoginTwoFactor godoc
+// @Summary Complete a two-factor login
+// @Description Exchange the challenge returned by /login and an authenticator app or recovery code for an access and refresh token
+// @Tags user
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /login/2fa [post]
+func (h *UserHandler) LoginTwoFactor(c *gin.Context) {
+	var input struct {
+		Challenge string `json:"challenge" binding:"required"`
+		Code      string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	accessToken, refreshToken, err := h.userService.CompleteTwoFactorLogin(input.Challenge, input.Code, services.LoginClient{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	var lockedErr *services.LoginLockedError
+	if errors.As(err, &lockedErr) {
+		setRetryAfter(c, lockedErr.RetryAfter)
+		c.JSON(http.StatusTooManyRequests, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Too many failed login attempts",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "locked"}},
+		})
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid two-factor code",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "code"}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Login successful",
+		Data: gin.H{
+			"access":  accessToken,
+			"refresh": refreshToken,
+		},
+	})
+}
+
+// RefreshToken godoc
+// @Summary Refresh the access token
+// @Description Exchange a refresh token for a new access and refresh token. The old refresh token stops working.
+// @Tags user
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /token/refresh [post]
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	var input struct {
+		Refresh string `json:"refresh" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	accessToken, refreshToken, err := h.userService.RefreshTokens(input.Refresh)
+	if err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, services.ErrAccountDisabled) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid refresh token",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Token refreshed successfully",
+		Data: gin.H{
+			"access":  accessToken,
+			"refresh": refreshToken,
+		},
+	})
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the current access token and, if given, the refresh token's session
+// @Tags user
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /logout [post]
+func (h *UserHandler) Logout(c *gin.Context) {
+	var input struct {
+		Refresh string `json:"refresh"`
+	}
+	// the body is optional, an access token alone is enough to log out
+	_ = c.ShouldBindJSON(&input)
+
+	value, _ := c.Get("Claims")
+	claims, ok := value.(*services.JWTCustomClaims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	if err := h.userService.Logout(claims, input.Refresh); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidToken) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to logout",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Logged out successfully",
+	})
+}
+
+// VerifyEmail godoc
+// @Summary Verify an email address
+// @Description Verify the email address a verification token was issued for. The token may be sent as a query parameter or in the body.
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param token query string false "Verification token"
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /verify-email [post]
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token"`
+	}
+	input.Token = c.Query("token")
+	if input.Token == "" {
+		_ = c.ShouldBindJSON(&input)
+	}
+	if input.Token == "" {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: "token is required"}},
+		})
+		return
+	}
+
+	if _, err := h.verificationService.VerifyEmail(input.Token); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidToken) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to verify email",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Email verified successfully",
+	})
+}
+
+// ResendVerification godoc
+// @Summary Resend the verification email
+// @Description Send another verification email to the current user. Limited to one email per resend interval.
+// @Tags user
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[any]
+// @Failure 429 {object} utils.GenericResponse[any]
+// @Router /verify-email/resend [post]
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	err := h.verificationService.SendVerification(c.GetString("email"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, utils.GenericResponse[any]{
+			Success: true,
+			Message: "Verification email sent",
+		})
+	case errors.Is(err, services.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Email is already verified",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "already_verified"}},
+		})
+	case errors.Is(err, services.ErrVerificationRateLimited):
+		setRetryAfter(c, h.verificationService.ResendInterval())
+		c.JSON(http.StatusTooManyRequests, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Too many requests",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "rate_limited"}},
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to send verification email",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+	}
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a password reset link. The response is the same whether or not the email is registered.
+// @Tags user
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /password/forgot [post]
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	if errs := validationErrors(input); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  errs,
+		})
+		return
+	}
+
+	// Failures are only logged: answering differently would reveal which emails are registered
+	if err := h.passwordResetService.RequestReset(input.Email); err != nil {
+		slog.Error("Failed to request password reset", "email", input.Email, "error", err)
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "If an account exists for this email, a password reset link has been sent",
+	})
+}
+
+// ResetPassword godoc
+// @Summary Reset a forgotten password
+// @Description Set a new password with a token from a password reset email. All sessions are logged out.
+// @Tags user
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /password/reset [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var input struct {
+		Token     string `json:"token" validate:"required"`
+		Password1 string `json:"password1" validate:"required,min=6,max=32"`
+		Password2 string `json:"password2" validate:"required,min=6,max=32"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	if errs := validationErrors(input); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  errs,
+		})
+		return
+	}
+	if input.Password1 != input.Password2 {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Passwords do not match",
+			Errors:  []utils.ErrorDetail{{Message: "Passwords do not match"}},
+		})
+		return
+	}
+
+	if err := h.passwordResetService.ResetPassword(input.Token, input.Password1); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to reset password",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Password reset successfully",
+	})
+}
+
+// ChangePassword godoc
+// @Summary Change the current user's password
+// @Description Change the password after checking the current one. All sessions, including this one, are logged out.
+// @Tags user
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.GenericResponse[any]
+// @Router /me/password [put]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var input struct {
+		OldPassword string `json:"old_password" validate:"required"`
+		Password1   string `json:"password1" validate:"required,min=6,max=32"`
+		Password2   string `json:"password2" validate:"required,min=6,max=32"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  []utils.ErrorDetail{{Message: err.Error()}},
+		})
+		return
+	}
+	if errs := validationErrors(input); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Invalid request",
+			Errors:  errs,
+		})
+		return
+	}
+	if input.Password1 != input.Password2 {
+		c.JSON(http.StatusBadRequest, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Passwords do not match",
+			Errors:  []utils.ErrorDetail{{Message: "Passwords do not match"}},
+		})
+		return
+	}
+
+	if err := h.userService.ChangePassword(utils.GetUserID(c), input.OldPassword, input.Password1); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrWrongPassword) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, utils.GenericResponse[any]{
+			Success: false,
+			Message: "Failed to change password",
+			Errors:  []utils.ErrorDetail{{Message: err.Error(), Code: "old_password"}},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.GenericResponse[any]{
+		Success: true,
+		Message: "Password changed successfully, please log in again",
+	})
+}
+
 // Me user handler
 // @Summary Get the current user
 // @Description Get the current user
@@ -239,6 +697,14 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 			Success: false,
 			Message: "Failed to update user",
 		})
+		return
+	}
+
+	// A changed address is unverified again; the user can ask for another email later
+	if !strings.EqualFold(input.Email, user.Email) {
+		if err := h.verificationService.SendVerification(input.Email); err != nil {
+			slog.Error("Failed to send verification email", "email", input.Email, "error", err)
+		}
 	}
 
 	c.JSON(http.StatusOK, utils.GenericResponse[any]{
@@ -19,7 +19,13 @@ const (
 	userKey                = "user"
 )
 
-func AuthMiddleware(userRepo repositories.UserRepository, userService services.UserService) gin.HandlerFunc {
+// twoFactorSetupPaths stay reachable for users who still have to enable two-factor
+// authentication, so they can set it up
+var twoFactorSetupPaths = []string{"/api/2fa", "/api/me", "/api/logout"}
+
+// AuthMiddleware authenticates the bearer token. Users whose role requires two-factor
+// authentication are only let through to the setup routes until they have enabled it.
+func AuthMiddleware(userRepo repositories.UserRepository, userService services.UserService, twoFactorService services.TwoFactorService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.Request.Header.Get(authorizationHeaderKey)
 		parts := strings.Split(token, " ")
@@ -34,7 +40,7 @@ func AuthMiddleware(userRepo repositories.UserRepository, userService services.U
 		}
 
 		bearerToken := parts[1]
-		claims, err := userService.VerifyToken(bearerToken)
+		claims, err := userService.VerifyAccessToken(bearerToken)
 		if err != nil {
 			slog.Error("Error verifying token", "error", err.Error())
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -53,6 +59,22 @@ func AuthMiddleware(userRepo repositories.UserRepository, userService services.U
 			c.Abort()
 			return
 		}
+		if !user.IsActive {
+			slog.Error("Unauthorized access attempt", "error", "User is deactivated")
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Account is deactivated",
+			})
+			c.Abort()
+			return
+		}
+		if !user.TwoFactorEnabled && twoFactorService.Required(user) && !isTwoFactorSetupPath(c.FullPath()) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Two-factor authentication must be enabled for this account",
+				"code":  "two_factor_setup_required",
+			})
+			c.Abort()
+			return
+		}
 
 		c.Set(claimsKey, claims)
 		c.Set(userIdKey, user.ID)
@@ -62,6 +84,15 @@ func AuthMiddleware(userRepo repositories.UserRepository, userService services.U
 	}
 }
 
+func isTwoFactorSetupPath(path string) bool {
+	for _, prefix := range twoFactorSetupPaths {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 func AdminMiddleware(userRepo repositories.UserRepository, userService services.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authUser, _ := c.Get(userKey)
@@ -110,20 +141,22 @@ func OwnerMiddleware(userRepo repositories.UserRepository, userService services.
 	}
 }
 
-func AdminOrOwnerMiddleware(userRepo repositories.UserRepository, userService services.UserService) gin.HandlerFunc {
+// StaffMiddleware lets restaurant owners and anyone who works at a restaurant through.
+// Which restaurants they can act on is decided per resource by the policy package.
+func StaffMiddleware(userRepo repositories.UserRepository, userService services.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authUser, _ := c.Get(userKey)
 		user, ok := authUser.(*models.User)
 		if !ok {
-			slog.Error("Invalid user", "error", "User is not an admin or owner")
+			slog.Error("Invalid user", "error", "User is not restaurant staff")
 			c.JSON(http.StatusForbidden, gin.H{
 				"error": "Unauthorized",
 			})
 			c.Abort()
 			return
 		}
-		if user.Role != "admin" && user.Role != "owner" {
-			slog.Error("Unauthorized access attempt", "error", "User is not an admin or owner")
+		if user.Role != "owner" && len(user.Memberships) == 0 {
+			slog.Error("Unauthorized access attempt", "error", "User is not restaurant staff")
 			c.JSON(http.StatusForbidden, gin.H{
 				"error": "Unauthorized",
 			})
@@ -133,3 +166,25 @@ func AdminOrOwnerMiddleware(userRepo repositories.UserRepository, userService se
 		c.Next()
 	}
 }
+
+// VerifiedEmailMiddleware blocks users whose email address is not verified yet.
+// When required is false it lets everyone through.
+func VerifiedEmailMiddleware(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !required {
+			c.Next()
+			return
+		}
+		authUser, _ := c.Get(userKey)
+		user, ok := authUser.(*models.User)
+		if !ok || !user.IsEmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Email address is not verified",
+				"code":  "email_not_verified",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
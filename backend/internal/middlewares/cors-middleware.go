@@ -1,28 +1,55 @@
 package middlewares
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"github.com/manjurulhoque/foodie/backend/internal/config"
 )
 
+// CORSMiddleware answers preflight requests and sets the CORS headers for the
+// origins in cfg. A listed origin is echoed back; "*" is sent as is, which
+// config validation only allows without credentials.
+func CORSMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	anyOrigin := false
+	allowed := map[string]bool{}
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	methods := strings.Join(cfg.AllowMethods, ", ")
+	headers := strings.Join(cfg.AllowHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
 
-func CORSMiddleware() gin.HandlerFunc {
-    return func(c *gin.Context) {
-		// origin := c.Request.Header.Get("Origin")
-		// referer := c.Request.Header.Get("Referer")
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		origin := c.Request.Header.Get("Origin")
+		header.Add("Vary", "Origin")
 
-		// if origin == "" && referer != "" {
-		// 	origin = referer
-		// }
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-        c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-        c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-        c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		switch {
+		case anyOrigin:
+			header.Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && allowed[origin]:
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		header.Set("Access-Control-Allow-Headers", headers)
+		header.Set("Access-Control-Allow-Methods", methods)
 
-        if c.Request.Method == "OPTIONS" {
-            c.AbortWithStatus(204)
-            return
-        }
+		if c.Request.Method == http.MethodOptions {
+			if cfg.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
 
-        c.Next()
-    }
-}
\ No newline at end of file
+		c.Next()
+	}
+}
@@ -0,0 +1,130 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/manjurulhoque/foodie/backend/internal/services"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// IdempotencyMiddleware makes it safe to retry a request that changes something. A
+// request sent with an Idempotency-Key header runs once per user and key; sending it
+// again replays the first response, marked with an Idempotent-Replayed header, until
+// the key expires. Reusing a key for a different request is rejected with 409, as is
+// a retry while the first request is still running. Server errors are not stored, so
+// such requests can be retried with the same key. It must run after AuthMiddleware.
+func IdempotencyMiddleware(service services.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Idempotency-Key must not be longer than 255 characters",
+			})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIdempotentRequestBytes+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request body",
+			})
+			c.Abort()
+			return
+		}
+		if len(body) > maxIdempotentRequestBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "Request body is too large",
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, replay, err := service.Begin(c.GetUint(userIdKey), key, requestHash(c.Request, body))
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyReused):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+				"code":  "idempotency_key_reused",
+			})
+			c.Abort()
+			return
+		case errors.Is(err, services.ErrIdempotencyKeyInFlight):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+				"code":  "idempotency_key_in_flight",
+			})
+			c.Abort()
+			return
+		case err != nil:
+			slog.Error("Error claiming idempotency key", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to process request",
+			})
+			c.Abort()
+			return
+		}
+		if replay {
+			c.Header(idempotentReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if writer.Status() >= http.StatusInternalServerError {
+			err = service.Release(record)
+		} else {
+			err = service.Complete(record, writer.Status(), writer.Header().Get("Content-Type"), writer.body.Bytes())
+		}
+		if err != nil {
+			slog.Error("Error storing idempotent response", "key", key, "error", err)
+		}
+	}
+}
+
+// requestHash identifies a request by its method, path and body, so a key reused for
+// anything else can be told apart from a retry
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body it writes
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/db/dbtest"
+	"github.com/manjurulhoque/foodie/backend/internal/db/migrations"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"github.com/manjurulhoque/foodie/backend/internal/services"
+)
+
+func setupIdempotencyRouter(t *testing.T) (*gin.Engine, *int) {
+	database := dbtest.Open(t)
+	migrator, err := migrations.New(database)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	service := services.NewIdempotencyService(repositories.NewIdempotencyRepository(database), config.IdempotencyConfig{TTL: time.Hour})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// stands in for AuthMiddleware
+	authenticate := func(c *gin.Context) {
+		userID, _ := strconv.ParseUint(c.GetHeader("X-User"), 10, 32)
+		c.Set(userIdKey, uint(userID))
+	}
+	calls := 0
+	router.POST("/orders", authenticate, IdempotencyMiddleware(service), func(c *gin.Context) {
+		calls++
+		if c.Query("fail") != "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"order": calls})
+	})
+	return router, &calls
+}
+
+func postOrder(router *gin.Engine, user, key, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	router, calls := setupIdempotencyRouter(t)
+
+	first := postOrder(router, "1", "key-1", "/orders", `{"restaurant_id":1}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(idempotentReplayedHeader))
+
+	retry := postOrder(router, "1", "key-1", "/orders", `{"restaurant_id":1}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+	assert.Equal(t, 1, *calls, "the retry is answered without running the handler")
+
+	t.Run("different payload", func(t *testing.T) {
+		w := postOrder(router, "1", "key-1", "/orders", `{"restaurant_id":2}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "idempotency_key_reused")
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("keys belong to one user", func(t *testing.T) {
+		w := postOrder(router, "2", "key-1", "/orders", `{"restaurant_id":2}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("without a key every request runs", func(t *testing.T) {
+		postOrder(router, "1", "", "/orders", `{"restaurant_id":1}`)
+		postOrder(router, "1", "", "/orders", `{"restaurant_id":1}`)
+		assert.Equal(t, 4, *calls)
+	})
+
+	t.Run("server errors are not replayed", func(t *testing.T) {
+		w := postOrder(router, "1", "key-2", "/orders?fail=yes", `{}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		w = postOrder(router, "1", "key-2", "/orders?fail=yes", `{}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Empty(t, w.Header().Get(idempotentReplayedHeader))
+		assert.Equal(t, 6, *calls)
+	})
+
+	t.Run("overlong key", func(t *testing.T) {
+		w := postOrder(router, "1", strings.Repeat("k", maxIdempotencyKeyLength+1), "/orders", `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// ResourceLoader fetches the resource a request targets so a policy can be checked against
+// it. It returns gorm.ErrRecordNotFound if there is no such resource.
+type ResourceLoader func(c *gin.Context) (any, error)
+
+// PolicyMiddleware loads the targeted resource and only lets the request through if the
+// authenticated user may perform action on it. It must run after AuthMiddleware.
+func PolicyMiddleware(action policy.Action, load ResourceLoader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resource, err := load(c)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Not found",
+			})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to load resource", "error", err, "path", c.FullPath())
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Internal server error",
+			})
+			c.Abort()
+			return
+		}
+
+		authUser, _ := c.Get(userKey)
+		user, _ := authUser.(*models.User)
+		if !policy.Can(user, action, resource) {
+			slog.Error("Unauthorized access attempt", "error", "Policy denied", "action", action, "path", c.FullPath())
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Unauthorized",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RestaurantLoader loads the restaurant identified by the "id" route param
+func RestaurantLoader(repo repositories.RestaurantRepository) ResourceLoader {
+	return func(c *gin.Context) (any, error) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return repo.FindByID(uint(id))
+	}
+}
+
+// OrderLoader loads the order identified by the "id" route param
+func OrderLoader(repo repositories.OrderRepository) ResourceLoader {
+	return func(c *gin.Context) (any, error) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return repo.FindByID(uint(id))
+	}
+}
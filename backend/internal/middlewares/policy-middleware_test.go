@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
+)
+
+func TestPolicyMiddlewareLoadErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"missing resource", gorm.ErrRecordNotFound, http.StatusNotFound},
+		{"database failure", errors.New("connection refused"), http.StatusInternalServerError},
+		{"loaded", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			load := func(c *gin.Context) (any, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &models.Restaurant{}, nil
+			}
+			router.GET("/restaurants/:id", PolicyMiddleware(policy.ActionView, load), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/restaurants/1", nil))
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
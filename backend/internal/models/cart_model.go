@@ -8,18 +8,34 @@ import (
 
 type Cart struct {
 	BaseModel
-	UserID uint       `json:"user_id" gorm:"not null"`
-	User   User       `json:"-" gorm:"foreignKey:UserID"`
-	Items  []CartItem `json:"items" gorm:"foreignKey:CartID"`
+	UserID       uint       `json:"user_id" gorm:"not null"`
+	User         User       `json:"-" gorm:"foreignKey:UserID"`
+	RestaurantID *uint      `json:"restaurant_id" gorm:"default:null;null"` // restaurant all items belong to, nil while empty
+	PromotionID  *uint      `json:"promotion_id" gorm:"default:null;null"`  // promo code applied to the cart
+	Promotion    *Promotion `json:"promotion,omitempty" gorm:"foreignKey:PromotionID"`
+	Items        []CartItem `json:"items" gorm:"foreignKey:CartID"`
 }
 
+// CartItem is a line of a cart. The same menu item ordered with other options or notes
+// gets a line of its own.
 type CartItem struct {
 	BaseModel
-	CartID     uint     `json:"cart_id" gorm:"not null"`
-	Cart       Cart     `json:"-" gorm:"foreignKey:CartID"`
-	MenuItemID uint     `json:"menu_item_id" gorm:"not null"`
-	MenuItem   MenuItem `json:"menu_item" gorm:"foreignKey:MenuItemID"`
-	Quantity   int      `json:"quantity" gorm:"not null;default:1"`
+	CartID     uint             `json:"cart_id" gorm:"not null"`
+	Cart       Cart             `json:"-" gorm:"foreignKey:CartID"`
+	MenuItemID uint             `json:"menu_item_id" gorm:"not null"`
+	MenuItem   MenuItem         `json:"menu_item" gorm:"foreignKey:MenuItemID"`
+	Quantity   int              `json:"quantity" gorm:"not null;default:1"`
+	Notes      string           `json:"notes" gorm:"default:''"`
+	OptionsKey string           `json:"-" gorm:"default:''"` // sorted IDs of the selected options, e.g. "3,7"
+	Options    []CartItemOption `json:"options" gorm:"foreignKey:CartItemID"`
+}
+
+// CartItemOption is a modifier option selected for a cart item
+type CartItemOption struct {
+	BaseModel
+	CartItemID       uint           `json:"cart_item_id" gorm:"not null;index"`
+	ModifierOptionID uint           `json:"modifier_option_id" gorm:"not null"`
+	ModifierOption   ModifierOption `json:"modifier_option" gorm:"foreignKey:ModifierOptionID"`
 }
 
 func (Cart) BeforeCreate(tx *gorm.DB) error {
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to the first request a user sent with an
+// Idempotency-Key header, so a retry gets that response instead of running again. A
+// key without a status code belongs to a request that is still running.
+type IdempotencyKey struct {
+	BaseModel
+	UserID       uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `json:"key" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	RequestHash  string    `json:"-" gorm:"not null"` // method, path and body of the first request
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `json:"-"`
+	ResponseBody []byte    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external OpenID provider
+type UserIdentity struct {
+	BaseModel
+	UserID   uint   `json:"user_id" gorm:"not null;index"`
+	Provider string `json:"provider" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject  string `json:"-" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Email    string `json:"email"`
+}
+
+// OIDCAuthRequest remembers a login sent to a provider until it comes back. The state
+// is the lookup key; the PKCE verifier and nonce never leave the server.
+type OIDCAuthRequest struct {
+	BaseModel
+	State        string    `json:"-" gorm:"not null;uniqueIndex"`
+	Provider     string    `json:"provider" gorm:"not null"`
+	Nonce        string    `json:"-" gorm:"not null"`
+	CodeVerifier string    `json:"-" gorm:"not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+}
+
+func (OIDCAuthRequest) TableName() string {
+	return "oidc_auth_requests"
+}
@@ -0,0 +1,11 @@
+package models
+
+// LoginFailure is an audit record of a failed login attempt
+type LoginFailure struct {
+	BaseModel
+	Email     string `json:"email" gorm:"not null;index"`
+	UserID    *uint  `json:"user_id" gorm:"index"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Reason    string `json:"reason"` // unknown_email, wrong_password, wrong_code or locked
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Roles a user can hold within a single restaurant
+const (
+	MemberRoleOwner   = "owner"
+	MemberRoleManager = "manager"
+	MemberRoleStaff   = "staff"
+)
+
+// RestaurantMember gives a user a role at one restaurant
+type RestaurantMember struct {
+	BaseModel
+	RestaurantID uint        `json:"restaurant_id" gorm:"not null;uniqueIndex:idx_restaurant_member"`
+	Restaurant   *Restaurant `json:"restaurant,omitempty" gorm:"foreignKey:RestaurantID"`
+	UserID       uint        `json:"user_id" gorm:"not null;uniqueIndex:idx_restaurant_member"`
+	User         *User       `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Role         string      `json:"role" gorm:"not null;default:staff"`
+}
+
+// RestaurantInvitation is a pending offer to join a restaurant. Only a hash of
+// the token is stored, the token itself is handed out once when inviting.
+type RestaurantInvitation struct {
+	BaseModel
+	RestaurantID uint        `json:"restaurant_id" gorm:"not null;index"`
+	Restaurant   *Restaurant `json:"restaurant,omitempty" gorm:"foreignKey:RestaurantID"`
+	Email        string      `json:"email" gorm:"not null"`
+	Role         string      `json:"role" gorm:"not null"`
+	TokenHash    string      `json:"-" gorm:"not null;uniqueIndex"`
+	InvitedByID  uint        `json:"invited_by_id" gorm:"not null"`
+	ExpiresAt    time.Time   `json:"expires_at"`
+	AcceptedAt   *time.Time  `json:"accepted_at"`
+}
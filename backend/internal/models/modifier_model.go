@@ -0,0 +1,33 @@
+package models
+
+// ModifierGroup is a choice customers make when ordering a menu item, such as a size or
+// add-ons. A required group needs at least one selection even when MinSelections is 0.
+type ModifierGroup struct {
+	BaseModel
+	MenuItemID    uint             `json:"menu_item_id" gorm:"not null;index"`
+	Name          string           `json:"name" gorm:"not null"`
+	Required      bool             `json:"required" gorm:"default:false"`
+	MinSelections int              `json:"min_selections" gorm:"default:0"`
+	MaxSelections int              `json:"max_selections" gorm:"default:0"` // 0 for no limit
+	Position      int              `json:"position" gorm:"default:0"`
+	Options       []ModifierOption `json:"options" gorm:"foreignKey:GroupID"`
+}
+
+// MinRequired is the number of options that have to be selected from the group
+func (g *ModifierGroup) MinRequired() int {
+	if g.Required && g.MinSelections < 1 {
+		return 1
+	}
+	return g.MinSelections
+}
+
+// ModifierOption is one option of a modifier group. PriceDelta is added to the price
+// of the menu item for every unit ordered with the option.
+type ModifierOption struct {
+	BaseModel
+	GroupID     uint    `json:"group_id" gorm:"not null;index"`
+	Name        string  `json:"name" gorm:"not null"`
+	PriceDelta  float64 `json:"price_delta" gorm:"default:0"`
+	IsAvailable bool    `json:"is_available" gorm:"default:true"`
+	Position    int     `json:"position" gorm:"default:0"`
+}
@@ -0,0 +1,29 @@
+package models
+
+// PaymentStatusAuthorized is a payment the provider has approved but not yet taken;
+// the order stays pending until it is captured
+const PaymentStatusAuthorized = "authorized"
+
+// Payment is one attempt to collect an order's total through a payment provider
+type Payment struct {
+	BaseModel
+	OrderID     uint    `json:"order_id" gorm:"not null;index"`
+	Order       Order   `json:"-" gorm:"foreignKey:OrderID"`
+	Provider    string  `json:"provider" gorm:"not null;uniqueIndex:idx_payment_provider_ref"`
+	ProviderRef string  `json:"provider_ref" gorm:"not null;uniqueIndex:idx_payment_provider_ref"` // the provider's id for the payment
+	Amount      float64 `json:"amount"`
+	Status      string  `json:"status" gorm:"not null;default:'pending'"`
+	// ClientSecret lets the client finish the payment with the provider. It is only
+	// returned when the payment is created and never stored.
+	ClientSecret string `json:"client_secret,omitempty" gorm:"-"`
+}
+
+// PaymentEvent is a webhook delivery that has been applied. Providers retry and may
+// send an event more than once; the unique event id makes handling idempotent.
+type PaymentEvent struct {
+	BaseModel
+	Provider  string `json:"provider" gorm:"not null;uniqueIndex:idx_payment_event"`
+	EventID   string `json:"event_id" gorm:"not null;uniqueIndex:idx_payment_event"`
+	PaymentID uint   `json:"payment_id" gorm:"index"`
+	Status    string `json:"status"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+const (
+	PromotionTypePercentage   = "percentage"    // Value percent off the subtotal
+	PromotionTypeFixed        = "fixed"         // Value off the subtotal
+	PromotionTypeFreeDelivery = "free_delivery" // the delivery fee is waived
+)
+
+// What a discount line takes money off
+const (
+	DiscountTargetItems    = "items"
+	DiscountTargetDelivery = "delivery"
+)
+
+// Promotion is a promo code customers can apply to their cart. A promotion without a
+// restaurant or cuisine applies everywhere; zero limits and missing dates are unlimited.
+type Promotion struct {
+	BaseModel
+	Code           string      `json:"code" gorm:"not null;uniqueIndex:idx_promotions_code,where:deleted_at IS NULL"` // stored upper case
+	Description    string      `json:"description"`
+	Type           string      `json:"type" gorm:"not null"`
+	Value          float64     `json:"value"`
+	MinSubtotal    float64     `json:"min_subtotal" gorm:"default:0"`
+	MaxUses        int         `json:"max_uses" gorm:"default:0"`          // across all customers
+	MaxUsesPerUser int         `json:"max_uses_per_user" gorm:"default:0"` // per customer
+	StartsAt       *time.Time  `json:"starts_at"`
+	EndsAt         *time.Time  `json:"ends_at"`
+	IsActive       bool        `json:"is_active" gorm:"default:true"`
+	RestaurantID   *uint       `json:"restaurant_id" gorm:"default:null;null;index"`
+	Restaurant     *Restaurant `json:"restaurant,omitempty" gorm:"foreignKey:RestaurantID"`
+	CuisineID      *uint       `json:"cuisine_id" gorm:"default:null;null"` // restaurants serving this cuisine
+	Cuisine        *Cuisine    `json:"cuisine,omitempty" gorm:"foreignKey:CuisineID"`
+	CreatedByID    uint        `json:"created_by_id"`
+}
+
+// OrderDiscount is a discount line of an order as it was priced at checkout. The
+// lines of orders that were not cancelled or rejected count as uses of their promotion.
+type OrderDiscount struct {
+	BaseModel
+	OrderID     uint      `json:"order_id" gorm:"not null;index"`
+	PromotionID uint      `json:"promotion_id" gorm:"not null;index"`
+	Promotion   Promotion `json:"-" gorm:"foreignKey:PromotionID"`
+	Code        string    `json:"code"`
+	Description string    `json:"description"`
+	Target      string    `json:"target"` // items or delivery
+	Amount      float64   `json:"amount"`
+}
@@ -0,0 +1,28 @@
+package models
+
+// Refund is money returned to a customer from an order's captured payment. A refund
+// without items returns everything still refundable; one with items returns those
+// quantities only.
+type Refund struct {
+	BaseModel
+	OrderID     uint         `json:"order_id" gorm:"not null;index"`
+	Order       Order        `json:"-" gorm:"foreignKey:OrderID"`
+	PaymentID   uint         `json:"payment_id" gorm:"not null;index"`
+	Payment     Payment      `json:"-" gorm:"foreignKey:PaymentID"`
+	Amount      float64      `json:"amount"`
+	Reason      string       `json:"reason" gorm:"not null"`
+	ProviderRef string       `json:"provider_ref"` // the provider's id for the refund
+	CreatedByID uint         `json:"created_by_id"`
+	CreatedBy   User         `json:"-" gorm:"foreignKey:CreatedByID"`
+	Items       []RefundItem `json:"items,omitempty" gorm:"foreignKey:RefundID"`
+}
+
+// RefundItem is the part of a refund that returns some units of an order item
+type RefundItem struct {
+	BaseModel
+	RefundID    uint      `json:"refund_id" gorm:"not null;index"`
+	OrderItemID uint      `json:"order_item_id" gorm:"not null;index"`
+	OrderItem   OrderItem `json:"-" gorm:"foreignKey:OrderItemID"`
+	Quantity    int       `json:"quantity"`
+	Amount      float64   `json:"amount"`
+}
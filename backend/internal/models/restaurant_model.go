@@ -18,12 +18,14 @@ type Restaurant struct {
 	IsActive    bool    `json:"is_active" gorm:"default:true"`
 	IsOpen      bool    `json:"is_open" gorm:"default:true"`
 	UserID      *uint   `json:"user_id" gorm:"default:null;null"`
+	// CancellationWindowMinutes lets customers cancel accepted orders for this long after placing them
+	CancellationWindowMinutes int `json:"cancellation_window_minutes" gorm:"default:0"`
 
 	Cuisines     []*Cuisine     `json:"cuisines" gorm:"many2many:restaurant_cuisines;"`
 	User         *User          `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	MenuItems    []MenuItem     `json:"menu_items" gorm:"foreignKey:RestaurantID"`
 	WorkingHours []*WorkingHour `json:"working_hours" gorm:"foreignKey:RestaurantID"`
-	Orders       []*Order        `json:"orders" gorm:"foreignKey:RestaurantID"`
+	Orders       []*Order       `json:"orders" gorm:"foreignKey:RestaurantID"`
 }
 
 func (Restaurant) BeforeCreate(tx *gorm.DB) (err error) {
@@ -48,8 +50,9 @@ type MenuItem struct {
 	RestaurantID uint    `json:"restaurant_id"`
 	CuisineID    uint    `json:"cuisine_id,omitempty" gorm:"default:null;null"`
 
-	Cuisine    *Cuisine   `json:"cuisine,omitempty" gorm:"foreignKey:CuisineID"`
-	Restaurant Restaurant `json:"restaurant,omitempty" gorm:"foreignKey:RestaurantID"`
+	Cuisine        *Cuisine        `json:"cuisine,omitempty" gorm:"foreignKey:CuisineID"`
+	Restaurant     Restaurant      `json:"restaurant,omitempty" gorm:"foreignKey:RestaurantID"`
+	ModifierGroups []ModifierGroup `json:"modifier_groups,omitempty" gorm:"foreignKey:MenuItemID"`
 }
 
 func (MenuItem) BeforeCreate(tx *gorm.DB) (err error) {
@@ -63,18 +66,45 @@ func (MenuItem) BeforeUpdate(tx *gorm.DB) (err error) {
 	return nil
 }
 
+const (
+	OrderStatusPending        = "pending"
+	OrderStatusAccepted       = "accepted"
+	OrderStatusPreparing      = "preparing"
+	OrderStatusReady          = "ready"
+	OrderStatusOutForDelivery = "out_for_delivery"
+	OrderStatusDelivered      = "delivered"
+	OrderStatusCancelled      = "cancelled"
+	OrderStatusRejected       = "rejected"
+)
+
+const (
+	PaymentStatusPending       = "pending"
+	PaymentStatusPaid          = "paid"
+	PaymentStatusFailed        = "failed"
+	PaymentStatusRefundPending = "refund_pending"
+	// PaymentStatusPartiallyRefunded and PaymentStatusRefunded follow refunds recorded
+	// against the order's captured payment
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+)
+
 type Order struct {
 	BaseModel
-	UserID          uint        `json:"user_id"`
-	User            User        `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	RestaurantID    uint        `json:"restaurant_id"`
-	Restaurant      Restaurant  `json:"restaurant,omitempty" gorm:"foreignKey:RestaurantID"`
-	Items           []OrderItem `json:"items" gorm:"foreignKey:OrderID"`
-	TotalAmount     float64     `json:"total_amount"`
-	Status          string      `json:"status" gorm:"default:'pending'"`
-	DeliveryAddress string      `json:"delivery_address" binding:"required"`
-	PaymentStatus   string      `json:"payment_status" gorm:"default:'pending'"`
-	PaymentMethod   string      `json:"payment_method" binding:"required"`
+	UserID          uint            `json:"user_id"`
+	User            User            `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	RestaurantID    uint            `json:"restaurant_id"`
+	Restaurant      Restaurant      `json:"restaurant,omitempty" gorm:"foreignKey:RestaurantID"`
+	Items           []OrderItem     `json:"items" gorm:"foreignKey:OrderID"`
+	Subtotal        float64         `json:"subtotal" gorm:"default:0"`
+	TaxAmount       float64         `json:"tax_amount" gorm:"default:0"`
+	DeliveryFee     float64         `json:"delivery_fee" gorm:"default:0"`
+	DiscountAmount  float64         `json:"discount_amount" gorm:"default:0"`
+	Discounts       []OrderDiscount `json:"discounts,omitempty" gorm:"foreignKey:OrderID"`
+	TotalAmount     float64         `json:"total_amount"`
+	Status          string          `json:"status" gorm:"default:'pending'"`
+	DeliveryAddress string          `json:"delivery_address" binding:"required"`
+	PaymentStatus   string          `json:"payment_status" gorm:"default:'pending'"`
+	PaymentMethod   string          `json:"payment_method" binding:"required"`
 }
 
 func (Order) BeforeCreate(tx *gorm.DB) (err error) {
@@ -90,12 +120,25 @@ func (Order) BeforeUpdate(tx *gorm.DB) (err error) {
 
 type OrderItem struct {
 	BaseModel
-	OrderID    uint     `json:"order_id" gorm:"not null"`
-	Order      Order    `json:"-" gorm:"foreignKey:OrderID"`
-	MenuItemID uint     `json:"menu_item_id" gorm:"not null"`
-	MenuItem   MenuItem `json:"menu_item" gorm:"foreignKey:MenuItemID"`
-	Quantity   int      `json:"quantity" binding:"required"`
-	Price      float64  `json:"price"`
+	OrderID    uint              `json:"order_id" gorm:"not null"`
+	Order      Order             `json:"-" gorm:"foreignKey:OrderID"`
+	MenuItemID uint              `json:"menu_item_id" gorm:"not null"`
+	MenuItem   MenuItem          `json:"menu_item" gorm:"foreignKey:MenuItemID"`
+	Quantity   int               `json:"quantity" binding:"required"`
+	Price      float64           `json:"price"` // unit price including the options
+	Notes      string            `json:"notes"`
+	Options    []OrderItemOption `json:"options" gorm:"foreignKey:OrderItemID"`
+}
+
+// OrderItemOption is a modifier option of an order item as it was named and priced at
+// checkout
+type OrderItemOption struct {
+	BaseModel
+	OrderItemID      uint    `json:"order_item_id" gorm:"not null;index"`
+	ModifierOptionID uint    `json:"modifier_option_id"`
+	GroupName        string  `json:"group_name"`
+	Name             string  `json:"name"`
+	PriceDelta       float64 `json:"price_delta"`
 }
 
 func (OrderItem) BeforeCreate(tx *gorm.DB) (err error) {
@@ -136,10 +179,13 @@ func (WorkingHour) BeforeUpdate(tx *gorm.DB) (err error) {
 
 type OrderStatusHistory struct {
 	BaseModel
-	OrderID     uint      `json:"order_id" gorm:"not null"`
-	Status      string    `json:"status" gorm:"not null"`
-	Description string    `json:"description"`
-	Order       *Order    `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	OrderID     uint   `json:"order_id" gorm:"not null"`
+	FromStatus  string `json:"from_status"`
+	Status      string `json:"status" gorm:"not null"`
+	Description string `json:"description"`
+	ChangedByID *uint  `json:"changed_by_id" gorm:"default:null;null"`
+	ChangedBy   *User  `json:"changed_by,omitempty" gorm:"foreignKey:ChangedByID"`
+	Order       *Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
 }
 
 func (OrderStatusHistory) TableName() string {
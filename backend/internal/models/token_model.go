@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// RefreshToken tracks an issued refresh token. Tokens created by rotating one
+// another share a FamilyID, so a whole login session can be revoked at once.
+type RefreshToken struct {
+	BaseModel
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	JTI        string     `json:"-" gorm:"column:jti;not null;uniqueIndex"`
+	FamilyID   string     `json:"-" gorm:"not null;index"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy string     `json:"-"`
+}
+
+// PasswordResetToken is a single use token for resetting a forgotten password.
+// Only a hash of the token is stored.
+type PasswordResetToken struct {
+	BaseModel
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// RevokedToken is an access token that was revoked before it expired, e.g. on logout
+type RevokedToken struct {
+	BaseModel
+	JTI       string    `json:"-" gorm:"column:jti;not null;uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// TOTPSecret is a user's authenticator app secret. It only protects logins once
+// ConfirmedAt is set, i.e. after the user entered a code from the app.
+type TOTPSecret struct {
+	BaseModel
+	UserID      uint       `json:"user_id" gorm:"not null;uniqueIndex"`
+	Secret      string     `json:"-" gorm:"not null"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	// LastUsedStep is the time step of the last accepted code, so a code cannot be replayed
+	LastUsedStep int64 `json:"-"`
+}
+
+// RecoveryCode is a single use code for logging in without the authenticator app.
+// Only a hash of the code is stored.
+type RecoveryCode struct {
+	BaseModel
+	UserID   uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	UsedAt   *time.Time `json:"used_at"`
+}
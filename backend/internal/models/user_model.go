@@ -19,7 +19,7 @@ type User struct {
 	Email           string    `json:"email" gorm:"unique;not null" validate:"required,email"`
 	Name            string    `json:"name" gorm:"not null" validate:"required"`
 	Password        string    `json:"-" gorm:"not null" validate:"required,min=6"`
-	Phone           string    `json:"phone" gorm:"unique;not null" validate:"required"`
+	Phone           string    `json:"phone" gorm:"unique" validate:"required"` // NULL for users who signed up with an OpenID provider
 	Role            string    `json:"role" gorm:"not null;default:customer"`
 	Image           string    `json:"image"`
 	IsActive        bool      `json:"is_active" gorm:"default:true"`
@@ -33,6 +33,16 @@ type User struct {
 
 	// New fields for multi-restaurant functionality
 	DeliveryAddresses []Address `json:"delivery_addresses" gorm:"foreignKey:UserID"`
+
+	// Restaurants the user works at, see RestaurantMember
+	Memberships []RestaurantMember `json:"memberships,omitempty" gorm:"foreignKey:UserID"`
+
+	// When the last verification email went out, used to rate limit resends
+	EmailVerificationSentAt *time.Time `json:"-"`
+	// Bumped to revoke every session; access tokens carrying an older version are rejected
+	TokenVersion uint `json:"-" gorm:"not null;default:0"`
+	// Set once the user confirmed an authenticator app, see TOTPSecret
+	TwoFactorEnabled bool `json:"two_factor_enabled" gorm:"default:false"`
 }
 
 func (u *User) TableName() string {
@@ -0,0 +1,74 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/oidc"
+	"github.com/manjurulhoque/foodie/backend/internal/oidc/oidctest"
+)
+
+var testUser = oidctest.User{Subject: "user-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	fake := oidctest.NewProvider(t)
+	provider := oidc.NewProvider(fake.Config("fake"), nil)
+	ctx := context.Background()
+
+	verifier, err := oidc.NewVerifier()
+	require.NoError(t, err)
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, oidc.S256Challenge(verifier), parsed.Query().Get("code_challenge"))
+	assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+
+	code, state := fake.Authorize(t, authURL, testUser)
+	assert.Equal(t, "state-1", state)
+
+	t.Run("Wrong verifier", func(t *testing.T) {
+		code, _ := fake.Authorize(t, authURL, testUser)
+		other, err := oidc.NewVerifier()
+		require.NoError(t, err)
+		_, err = provider.Exchange(ctx, code, other, "nonce-1")
+		assert.Error(t, err)
+	})
+
+	claims, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "jane@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+
+	// codes are single use
+	_, err = provider.Exchange(ctx, code, verifier, "nonce-1")
+	assert.Error(t, err)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	fake := oidctest.NewProvider(t)
+	provider := oidc.NewProvider(fake.Config("fake"), nil)
+	ctx := context.Background()
+
+	_, err := provider.VerifyIDToken(ctx, fake.IDToken(testUser, "nonce-1", time.Hour), "nonce-1")
+	assert.NoError(t, err)
+
+	_, err = provider.VerifyIDToken(ctx, fake.IDToken(testUser, "nonce-1", time.Hour), "other")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken), "nonce mismatch")
+
+	_, err = provider.VerifyIDToken(ctx, fake.IDToken(testUser, "nonce-1", -time.Minute), "nonce-1")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken), "expired")
+
+	// a token from another provider with its own keys
+	other := oidctest.NewProvider(t)
+	_, err = provider.VerifyIDToken(ctx, other.IDToken(testUser, "nonce-1", time.Hour), "nonce-1")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken), "foreign token")
+}
@@ -0,0 +1,44 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// CashName is the payment method of cash on delivery
+const CashName = "cash"
+
+// CashOnDelivery is paid to the courier. A payment stays pending until the restaurant
+// captures it once the cash is collected, and refunds are handed back in person, so
+// the provider only records what happened and never sends webhooks.
+type CashOnDelivery struct{}
+
+func NewCashOnDelivery() CashOnDelivery {
+	return CashOnDelivery{}
+}
+
+func (CashOnDelivery) Name() string {
+	return CashName
+}
+
+func (CashOnDelivery) CreateIntent(_ context.Context, req IntentRequest) (*Intent, error) {
+	return &Intent{ID: randomID(fmt.Sprintf("cash_%d_", req.OrderID)), Status: StatusPending}, nil
+}
+
+func (CashOnDelivery) Capture(_ context.Context, _ string, _ float64) (Status, error) {
+	return StatusSucceeded, nil
+}
+
+// Refund records cash handed back. The provider does not know how much was collected,
+// so callers must keep refunds within the captured amount.
+func (CashOnDelivery) Refund(_ context.Context, _ string, amount float64) (string, error) {
+	if amount <= 0 {
+		return "", ErrRefundExceedsAmount
+	}
+	return randomID("cash_refund_"), nil
+}
+
+func (CashOnDelivery) VerifyWebhook(_ []byte, _ http.Header) (*Event, error) {
+	return nil, ErrWebhookNotSupported
+}
@@ -0,0 +1,127 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	// FakeCardName is the payment method served by FakeCard
+	FakeCardName = "card"
+	// FakeCardSignatureHeader carries the hex HMAC-SHA256 of a FakeCard webhook body
+	FakeCardSignatureHeader = "Fake-Signature"
+)
+
+// FakeCard is an in-memory card processor for tests and local development. Intents
+// wait for Settle, which plays the customer entering their card and returns the
+// signed webhook a real processor would send.
+type FakeCard struct {
+	secret []byte
+
+	mu      sync.Mutex
+	intents map[string]*fakeIntent
+}
+
+type fakeIntent struct {
+	amount   float64
+	captured float64
+	refunded float64
+	status   Status
+}
+
+func NewFakeCard(webhookSecret []byte) *FakeCard {
+	return &FakeCard{secret: webhookSecret, intents: map[string]*fakeIntent{}}
+}
+
+func (p *FakeCard) Name() string {
+	return FakeCardName
+}
+
+func (p *FakeCard) CreateIntent(_ context.Context, req IntentRequest) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := randomID("fake_pi_")
+	p.intents[id] = &fakeIntent{amount: req.Amount, status: StatusPending}
+	return &Intent{ID: id, Status: StatusPending, ClientSecret: randomID(id + "_secret_")}, nil
+}
+
+func (p *FakeCard) Capture(_ context.Context, intentID string, amount float64) (Status, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return "", ErrUnknownIntent
+	}
+	if intent.status != StatusAuthorized || amount > intent.amount {
+		return intent.status, ErrNotCapturable
+	}
+	intent.captured = amount
+	intent.status = StatusSucceeded
+	return intent.status, nil
+}
+
+func (p *FakeCard) Refund(_ context.Context, intentID string, amount float64) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return "", ErrUnknownIntent
+	}
+	// a cent of slack absorbs float rounding in the caller's sums
+	if amount <= 0 || intent.refunded+amount > intent.captured+0.005 {
+		return "", ErrRefundExceedsAmount
+	}
+	intent.refunded += amount
+	return randomID("fake_re_"), nil
+}
+
+func (p *FakeCard) VerifyWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := verifySignature(p.secret, payload, header.Get(FakeCardSignatureHeader)); err != nil {
+		return nil, err
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("decoding webhook: %w", err)
+	}
+	if event.ID == "" || event.IntentID == "" {
+		return nil, fmt.Errorf("webhook event is missing its id or intent")
+	}
+	return &event, nil
+}
+
+// Settle finishes a pending intent with status, as if the customer had completed or
+// abandoned the card form, and returns the webhook announcing it
+func (p *FakeCard) Settle(intentID string, status Status) ([]byte, http.Header, error) {
+	p.mu.Lock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		p.mu.Unlock()
+		return nil, nil, ErrUnknownIntent
+	}
+	if intent.status != StatusPending {
+		p.mu.Unlock()
+		return nil, nil, fmt.Errorf("intent %s is already %s", intentID, intent.status)
+	}
+	intent.status = status
+	if status == StatusSucceeded {
+		intent.captured = intent.amount
+	}
+	p.mu.Unlock()
+
+	payload, header := p.Webhook(Event{ID: randomID("fake_evt_"), IntentID: intentID, Status: status})
+	return payload, header, nil
+}
+
+// Webhook signs an arbitrary event, for replaying or reordering deliveries in tests
+func (p *FakeCard) Webhook(event Event) ([]byte, http.Header) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		panic(err)
+	}
+	header := http.Header{}
+	header.Set(FakeCardSignatureHeader, Sign(p.secret, payload))
+	return payload, header
+}
@@ -0,0 +1,97 @@
+// Package payments talks to the services that collect money for orders. Each provider
+// implements PaymentProvider; the application keeps its own record of every payment
+// and moves it forward from provider responses and webhooks.
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrInvalidSignature    = errors.New("invalid webhook signature")
+	ErrWebhookNotSupported = errors.New("provider does not send webhooks")
+	ErrUnknownIntent       = errors.New("unknown payment intent")
+	ErrNotCapturable       = errors.New("payment cannot be captured in its current state")
+	ErrRefundExceedsAmount = errors.New("refund exceeds the captured amount")
+)
+
+// Status is where a payment stands at the provider
+type Status string
+
+const (
+	StatusPending    Status = "pending"    // waiting for the customer or, for cash, for delivery
+	StatusAuthorized Status = "authorized" // approved and waiting to be captured
+	StatusSucceeded  Status = "succeeded"  // the money has been taken
+	StatusFailed     Status = "failed"
+)
+
+// IntentRequest asks a provider to start collecting an amount for an order
+type IntentRequest struct {
+	OrderID uint
+	Amount  float64
+}
+
+// Intent is a payment started at a provider
+type Intent struct {
+	ID     string
+	Status Status
+	// ClientSecret is handed to the client to finish the payment with the provider,
+	// empty when the customer has nothing to do
+	ClientSecret string
+}
+
+// Event is a verified webhook notification about an intent
+type Event struct {
+	ID       string `json:"id"` // unique per event, used to ignore redeliveries
+	IntentID string `json:"intent_id"`
+	Status   Status `json:"status"`
+}
+
+// PaymentProvider is a way of paying for an order
+type PaymentProvider interface {
+	// Name is the payment method customers choose at checkout, e.g. "cash"
+	Name() string
+	CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error)
+	// Capture takes an authorized amount and returns the resulting status
+	Capture(ctx context.Context, intentID string, amount float64) (Status, error)
+	// Refund returns part or all of a captured amount and returns the provider's refund id
+	Refund(ctx context.Context, intentID string, amount float64) (string, error)
+	// VerifyWebhook checks the signature of a webhook request and decodes its event
+	VerifyWebhook(payload []byte, header http.Header) (*Event, error)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of payload, the signature scheme used by
+// the fake card provider's webhooks
+func Sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature compares signature with the expected one in constant time
+func verifySignature(secret, payload []byte, signature string) error {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func randomID(prefix string) string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return prefix + hex.EncodeToString(b)
+}
@@ -0,0 +1,74 @@
+package payments_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/payments"
+)
+
+func TestFakeCard(t *testing.T) {
+	ctx := context.Background()
+	card := payments.NewFakeCard([]byte("whsec"))
+
+	intent, err := card.CreateIntent(ctx, payments.IntentRequest{OrderID: 1, Amount: 20})
+	require.NoError(t, err)
+	assert.Equal(t, payments.StatusPending, intent.Status)
+	assert.NotEmpty(t, intent.ClientSecret)
+
+	_, err = card.Capture(ctx, intent.ID, 20)
+	assert.ErrorIs(t, err, payments.ErrNotCapturable, "a pending intent cannot be captured")
+
+	payload, header, err := card.Settle(intent.ID, payments.StatusAuthorized)
+	require.NoError(t, err)
+	event, err := card.VerifyWebhook(payload, header)
+	require.NoError(t, err)
+	assert.Equal(t, intent.ID, event.IntentID)
+	assert.Equal(t, payments.StatusAuthorized, event.Status)
+
+	t.Run("tampered webhook", func(t *testing.T) {
+		_, err := card.VerifyWebhook(append(payload, ' '), header)
+		assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+		_, err = card.VerifyWebhook(payload, http.Header{})
+		assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+		_, err = payments.NewFakeCard([]byte("other")).VerifyWebhook(payload, header)
+		assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+	})
+
+	_, err = card.Capture(ctx, intent.ID, 25)
+	assert.ErrorIs(t, err, payments.ErrNotCapturable, "more than was authorized")
+	status, err := card.Capture(ctx, intent.ID, 20)
+	require.NoError(t, err)
+	assert.Equal(t, payments.StatusSucceeded, status)
+
+	_, err = card.Refund(ctx, intent.ID, 15)
+	require.NoError(t, err)
+	_, err = card.Refund(ctx, intent.ID, 10)
+	assert.ErrorIs(t, err, payments.ErrRefundExceedsAmount)
+	_, err = card.Refund(ctx, intent.ID, 5)
+	assert.NoError(t, err)
+
+	_, err = card.Capture(ctx, "fake_pi_unknown", 1)
+	assert.ErrorIs(t, err, payments.ErrUnknownIntent)
+}
+
+func TestCashOnDelivery(t *testing.T) {
+	ctx := context.Background()
+	cash := payments.NewCashOnDelivery()
+
+	intent, err := cash.CreateIntent(ctx, payments.IntentRequest{OrderID: 7, Amount: 12})
+	require.NoError(t, err)
+	assert.Equal(t, payments.StatusPending, intent.Status)
+	assert.Empty(t, intent.ClientSecret)
+
+	status, err := cash.Capture(ctx, intent.ID, 12)
+	require.NoError(t, err)
+	assert.Equal(t, payments.StatusSucceeded, status)
+
+	_, err = cash.VerifyWebhook([]byte("{}"), http.Header{})
+	assert.ErrorIs(t, err, payments.ErrWebhookNotSupported)
+}
@@ -0,0 +1,165 @@
+// Package policy decides which users may act on which resources.
+//
+// Handlers, middlewares and services should ask Can or Authorize instead of
+// comparing roles and owner IDs themselves, so every rule lives in one place.
+package policy
+
+import (
+	"errors"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+)
+
+type Action string
+
+const (
+	ActionView   Action = "view"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+
+	// ActionManageStaff covers inviting and removing a restaurant's members. Managers
+	// may do it too, but only for staff; the membership service enforces that.
+	ActionManageStaff Action = "manage_staff"
+	// ActionManagePayment covers changing an order's payment status
+	ActionManagePayment Action = "manage_payment"
+	// ActionPay covers paying for an order, which only the customer who placed it may do
+	ActionPay Action = "pay"
+)
+
+var ErrForbidden = errors.New("you are not allowed to perform this action")
+
+// Can reports whether user may perform action on resource. A nil user is an
+// anonymous visitor. Resources must have the relations the rules depend on
+// loaded: MenuItem.Restaurant, Order.Restaurant, CartItem.Cart and
+// Promotion.Restaurant, as must User.Memberships for restaurant staff.
+func Can(user *models.User, action Action, resource any) bool {
+	switch r := resource.(type) {
+	case *models.Restaurant:
+		return canRestaurant(user, action, r)
+	case *models.MenuItem:
+		return canMenuItem(user, action, r)
+	case *models.Order:
+		return canOrder(user, action, r)
+	case *models.Address:
+		return canAddress(user, action, r)
+	case *models.CartItem:
+		return canCartItem(user, r)
+	case *models.Promotion:
+		return canPromotion(user, r)
+	}
+	return false
+}
+
+// Authorize is Can for callers that want an error
+func Authorize(user *models.User, action Action, resource any) error {
+	if !Can(user, action, resource) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func canRestaurant(user *models.User, action Action, restaurant *models.Restaurant) bool {
+	switch action {
+	case ActionView:
+		return true
+	case ActionCreate:
+		return user != nil
+	case ActionManageStaff:
+		return isAdmin(user) || hasRestaurantRole(user, restaurant.ID, restaurant.UserID, models.MemberRoleManager)
+	}
+	return isAdmin(user) || hasRestaurantRole(user, restaurant.ID, restaurant.UserID, models.MemberRoleOwner)
+}
+
+func canMenuItem(user *models.User, action Action, menuItem *models.MenuItem) bool {
+	if action == ActionView {
+		return true
+	}
+	return isAdmin(user) || hasRestaurantRole(user, menuItem.RestaurantID, menuItem.Restaurant.UserID, models.MemberRoleOwner)
+}
+
+func canOrder(user *models.User, action Action, order *models.Order) bool {
+	if user == nil {
+		return false
+	}
+	switch action {
+	case ActionView:
+		return isAdmin(user) || order.UserID == user.ID ||
+			hasRestaurantRole(user, order.RestaurantID, order.Restaurant.UserID, models.MemberRoleStaff)
+	case ActionCreate:
+		return true
+	case ActionUpdate:
+		return isAdmin(user) || hasRestaurantRole(user, order.RestaurantID, order.Restaurant.UserID, models.MemberRoleStaff)
+	case ActionManagePayment:
+		return isAdmin(user) || hasRestaurantRole(user, order.RestaurantID, order.Restaurant.UserID, models.MemberRoleOwner)
+	case ActionPay:
+		return order.UserID == user.ID
+	}
+	return isAdmin(user)
+}
+
+func canAddress(user *models.User, action Action, address *models.Address) bool {
+	if user == nil {
+		return false
+	}
+	if action == ActionView && isAdmin(user) {
+		return true
+	}
+	return address.UserID == user.ID
+}
+
+// canCartItem only lets users touch their own cart, admins included
+func canCartItem(user *models.User, cartItem *models.CartItem) bool {
+	return user != nil && cartItem.Cart.UserID == user.ID
+}
+
+// canPromotion lets admins manage every promotion and restaurant owners the
+// promotions of their own restaurants
+func canPromotion(user *models.User, promotion *models.Promotion) bool {
+	if isAdmin(user) {
+		return true
+	}
+	if promotion.RestaurantID == nil || promotion.Restaurant == nil {
+		return false
+	}
+	return hasRestaurantRole(user, *promotion.RestaurantID, promotion.Restaurant.UserID, models.MemberRoleOwner)
+}
+
+func isAdmin(user *models.User) bool {
+	return user != nil && user.Role == models.RoleAdmin
+}
+
+// memberRanks orders restaurant roles so that each one includes the ones below it
+var memberRanks = map[string]int{
+	models.MemberRoleStaff:   1,
+	models.MemberRoleManager: 2,
+	models.MemberRoleOwner:   3,
+}
+
+// hasRestaurantRole reports whether user holds at least minRole at the restaurant,
+// either as its owner or through a membership
+func hasRestaurantRole(user *models.User, restaurantID uint, ownerID *uint, minRole string) bool {
+	role := RestaurantRole(user, restaurantID, ownerID)
+	return role != "" && memberRanks[role] >= memberRanks[minRole]
+}
+
+// RestaurantRole returns the highest member role user holds at the restaurant, or
+// an empty string if they hold none. Owners of the restaurant count as its owner.
+func RestaurantRole(user *models.User, restaurantID uint, ownerID *uint) string {
+	if user == nil {
+		return ""
+	}
+	if user.Role == models.RoleRestaurantOwner && ownerID != nil && *ownerID == user.ID {
+		return models.MemberRoleOwner
+	}
+	if restaurantID == 0 {
+		return ""
+	}
+	role := ""
+	for _, membership := range user.Memberships {
+		if membership.RestaurantID == restaurantID && memberRanks[membership.Role] > memberRanks[role] {
+			role = membership.Role
+		}
+	}
+	return role
+}
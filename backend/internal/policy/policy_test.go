@@ -0,0 +1,130 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+)
+
+func TestCan(t *testing.T) {
+	customer := &models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleCustomer}
+	otherCustomer := &models.User{BaseModel: models.BaseModel{ID: 2}, Role: models.RoleCustomer}
+	owner := &models.User{BaseModel: models.BaseModel{ID: 3}, Role: models.RoleRestaurantOwner}
+	otherOwner := &models.User{BaseModel: models.BaseModel{ID: 4}, Role: models.RoleRestaurantOwner}
+	staff := &models.User{BaseModel: models.BaseModel{ID: 5}, Role: models.RoleRestaurantStaff}
+	moderator := &models.User{BaseModel: models.BaseModel{ID: 6}, Role: models.RoleModerator}
+	admin := &models.User{BaseModel: models.BaseModel{ID: 7}, Role: models.RoleAdmin}
+
+	restaurant := &models.Restaurant{BaseModel: models.BaseModel{ID: 10}, UserID: &owner.ID}
+	staff.Memberships = []models.RestaurantMember{{RestaurantID: restaurant.ID, UserID: staff.ID, Role: models.MemberRoleStaff}}
+	manager := &models.User{BaseModel: models.BaseModel{ID: 8}, Role: models.RoleRestaurantStaff}
+	manager.Memberships = []models.RestaurantMember{{RestaurantID: restaurant.ID, UserID: manager.ID, Role: models.MemberRoleManager}}
+	outsider := &models.User{BaseModel: models.BaseModel{ID: 9}, Role: models.RoleRestaurantStaff}
+	outsider.Memberships = []models.RestaurantMember{{RestaurantID: 99, UserID: outsider.ID, Role: models.MemberRoleManager}}
+	unowned := &models.Restaurant{BaseModel: models.BaseModel{ID: 11}}
+	menuItem := &models.MenuItem{RestaurantID: restaurant.ID, Restaurant: *restaurant}
+	order := &models.Order{UserID: customer.ID, RestaurantID: restaurant.ID, Restaurant: *restaurant}
+	address := &models.Address{UserID: customer.ID}
+	cartItem := &models.CartItem{Cart: models.Cart{UserID: customer.ID}}
+	promotion := &models.Promotion{RestaurantID: &restaurant.ID, Restaurant: restaurant}
+	globalPromotion := &models.Promotion{}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		action   Action
+		resource any
+		want     bool
+	}{
+		// restaurants
+		{name: "Anyone views a restaurant", user: nil, action: ActionView, resource: restaurant, want: true},
+		{name: "Anonymous cannot create a restaurant", user: nil, action: ActionCreate, resource: &models.Restaurant{}, want: false},
+		{name: "Customer creates a restaurant", user: customer, action: ActionCreate, resource: &models.Restaurant{}, want: true},
+		{name: "Owner updates own restaurant", user: owner, action: ActionUpdate, resource: restaurant, want: true},
+		{name: "Owner deletes own restaurant", user: owner, action: ActionDelete, resource: restaurant, want: true},
+		{name: "Other owner cannot update restaurant", user: otherOwner, action: ActionUpdate, resource: restaurant, want: false},
+		{name: "Owner cannot update unowned restaurant", user: owner, action: ActionUpdate, resource: unowned, want: false},
+		{name: "Customer cannot update restaurant", user: customer, action: ActionUpdate, resource: restaurant, want: false},
+		{name: "Customer cannot delete restaurant", user: customer, action: ActionDelete, resource: restaurant, want: false},
+		{name: "Staff cannot update restaurant", user: staff, action: ActionUpdate, resource: restaurant, want: false},
+		{name: "Moderator cannot delete restaurant", user: moderator, action: ActionDelete, resource: restaurant, want: false},
+		{name: "Admin deletes any restaurant", user: admin, action: ActionDelete, resource: unowned, want: true},
+		{name: "Manager cannot update restaurant", user: manager, action: ActionUpdate, resource: restaurant, want: false},
+		{name: "Manager cannot delete restaurant", user: manager, action: ActionDelete, resource: restaurant, want: false},
+		{name: "Owner manages staff", user: owner, action: ActionManageStaff, resource: restaurant, want: true},
+		{name: "Manager manages staff", user: manager, action: ActionManageStaff, resource: restaurant, want: true},
+		{name: "Staff cannot manage staff", user: staff, action: ActionManageStaff, resource: restaurant, want: false},
+
+		// menu items
+		{name: "Anyone views a menu item", user: nil, action: ActionView, resource: menuItem, want: true},
+		{name: "Owner updates own menu item", user: owner, action: ActionUpdate, resource: menuItem, want: true},
+		{name: "Other owner cannot update menu item", user: otherOwner, action: ActionUpdate, resource: menuItem, want: false},
+		{name: "Customer cannot create menu item", user: customer, action: ActionCreate, resource: menuItem, want: false},
+		{name: "Staff cannot delete menu item", user: staff, action: ActionDelete, resource: menuItem, want: false},
+		{name: "Admin updates any menu item", user: admin, action: ActionUpdate, resource: menuItem, want: true},
+		{name: "Manager cannot update menu item", user: manager, action: ActionUpdate, resource: menuItem, want: false},
+		{name: "Manager of another restaurant cannot update menu item", user: outsider, action: ActionUpdate, resource: menuItem, want: false},
+
+		// orders
+		{name: "Anonymous cannot view order", user: nil, action: ActionView, resource: order, want: false},
+		{name: "Customer views own order", user: customer, action: ActionView, resource: order, want: true},
+		{name: "Customer cannot view others' order", user: otherCustomer, action: ActionView, resource: order, want: false},
+		{name: "Customer cannot update own order", user: customer, action: ActionUpdate, resource: order, want: false},
+		{name: "Owner views restaurant order", user: owner, action: ActionView, resource: order, want: true},
+		{name: "Owner updates restaurant order", user: owner, action: ActionUpdate, resource: order, want: true},
+		{name: "Other owner cannot view order", user: otherOwner, action: ActionView, resource: order, want: false},
+		{name: "Staff views restaurant order", user: staff, action: ActionView, resource: order, want: true},
+		{name: "Staff updates restaurant order", user: staff, action: ActionUpdate, resource: order, want: true},
+		{name: "Staff of another restaurant cannot update order", user: outsider, action: ActionUpdate, resource: order, want: false},
+		{name: "Staff cannot change payment status", user: staff, action: ActionManagePayment, resource: order, want: false},
+		{name: "Manager cannot change payment status", user: manager, action: ActionManagePayment, resource: order, want: false},
+		{name: "Owner changes payment status", user: owner, action: ActionManagePayment, resource: order, want: true},
+		{name: "Customer pays own order", user: customer, action: ActionPay, resource: order, want: true},
+		{name: "Customer cannot pay others' order", user: otherCustomer, action: ActionPay, resource: order, want: false},
+		{name: "Admin cannot pay for a customer", user: admin, action: ActionPay, resource: order, want: false},
+		{name: "Anonymous cannot pay order", user: nil, action: ActionPay, resource: order, want: false},
+		{name: "Moderator cannot view order", user: moderator, action: ActionView, resource: order, want: false},
+		{name: "Owner cannot delete order", user: owner, action: ActionDelete, resource: order, want: false},
+		{name: "Admin updates any order", user: admin, action: ActionUpdate, resource: order, want: true},
+
+		// addresses
+		{name: "Customer updates own address", user: customer, action: ActionUpdate, resource: address, want: true},
+		{name: "Customer deletes own address", user: customer, action: ActionDelete, resource: address, want: true},
+		{name: "Customer cannot update others' address", user: otherCustomer, action: ActionUpdate, resource: address, want: false},
+		{name: "Owner cannot view customer address", user: owner, action: ActionView, resource: address, want: false},
+		{name: "Admin views any address", user: admin, action: ActionView, resource: address, want: true},
+		{name: "Admin cannot update others' address", user: admin, action: ActionUpdate, resource: address, want: false},
+		{name: "Anonymous cannot view address", user: nil, action: ActionView, resource: address, want: false},
+
+		// cart items
+		{name: "Customer updates own cart item", user: customer, action: ActionUpdate, resource: cartItem, want: true},
+		{name: "Customer cannot remove others' cart item", user: otherCustomer, action: ActionDelete, resource: cartItem, want: false},
+		{name: "Admin cannot touch others' cart item", user: admin, action: ActionUpdate, resource: cartItem, want: false},
+		{name: "Anonymous cannot touch cart item", user: nil, action: ActionUpdate, resource: cartItem, want: false},
+
+		// promotions
+		{name: "Owner creates promotion for own restaurant", user: owner, action: ActionCreate, resource: promotion, want: true},
+		{name: "Other owner cannot update promotion", user: otherOwner, action: ActionUpdate, resource: promotion, want: false},
+		{name: "Manager cannot delete promotion", user: manager, action: ActionDelete, resource: promotion, want: false},
+		{name: "Owner cannot create global promotion", user: owner, action: ActionCreate, resource: globalPromotion, want: false},
+		{name: "Admin creates global promotion", user: admin, action: ActionCreate, resource: globalPromotion, want: true},
+		{name: "Customer cannot create promotion", user: customer, action: ActionCreate, resource: promotion, want: false},
+
+		{name: "Unknown resources are denied", user: admin, action: ActionView, resource: &models.Category{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Can(tt.user, tt.action, tt.resource))
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	customer := &models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleCustomer}
+
+	assert.NoError(t, Authorize(customer, ActionUpdate, &models.Address{UserID: customer.ID}))
+	assert.ErrorIs(t, Authorize(customer, ActionUpdate, &models.Address{UserID: 2}), ErrForbidden)
+}
@@ -13,9 +13,19 @@ func NewCartRepository(db *gorm.DB) CartRepository {
 	return CartRepository{db: db}
 }
 
+// WithTx returns a copy of the repository that runs its queries on tx
+func (r *CartRepository) WithTx(tx *gorm.DB) CartRepository {
+	return CartRepository{db: tx}
+}
+
+// Transaction runs fn inside a database transaction, rolling back if it returns an error
+func (r *CartRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
 func (r *CartRepository) FindByUser(userID uint) (*models.Cart, error) {
 	var cart models.Cart
-	err := r.db.Preload("Items.MenuItem").Where("user_id = ?", userID).First(&cart).Error
+	err := r.db.Preload("Items.MenuItem").Preload("Items.Options.ModifierOption").Preload("Promotion").Where("user_id = ?", userID).First(&cart).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			// Create a new cart if one doesn't exist
@@ -33,27 +43,38 @@ func (r *CartRepository) FindByUser(userID uint) (*models.Cart, error) {
 
 func (r *CartRepository) FindItemsByCart(cartID uint) ([]models.CartItem, error) {
 	var cartItems []models.CartItem
-	err := r.db.Preload("MenuItem").Where("cart_id = ?", cartID).Find(&cartItems).Error
+	err := r.db.Preload("MenuItem").Preload("Options.ModifierOption").Where("cart_id = ?", cartID).Find(&cartItems).Error
 	if err != nil {
 		return nil, err
 	}
 	return cartItems, nil
 }
 
-func (r *CartRepository) AddItem(cartID uint, menuItemID uint, quantity int) error {
+// FindItemByID returns a cart item together with the cart it belongs to
+func (r *CartRepository) FindItemByID(cartItemID uint) (*models.CartItem, error) {
 	var cartItem models.CartItem
-	err := r.db.Where("cart_id = ? AND menu_item_id = ?", cartID, menuItemID).First(&cartItem).Error
+	err := r.db.Preload("Cart").First(&cartItem, cartItemID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cartItem, nil
+}
+
+// AddItem adds item with its options to a cart, or adds its quantity to the line with
+// the same menu item, options and notes
+func (r *CartRepository) AddItem(item *models.CartItem) error {
+	var cartItem models.CartItem
+	err := r.db.Where("cart_id = ? AND menu_item_id = ? AND options_key = ? AND notes = ?",
+		item.CartID, item.MenuItemID, item.OptionsKey, item.Notes).First(&cartItem).Error
 	if err == gorm.ErrRecordNotFound {
 		// Create new cart item
-		cartItem = models.CartItem{
-			CartID:     cartID,
-			MenuItemID: menuItemID,
-			Quantity:   quantity,
-		}
-		return r.db.Create(&cartItem).Error
+		return r.db.Create(item).Error
+	}
+	if err != nil {
+		return err
 	}
 	// Update existing cart item quantity
-	cartItem.Quantity += quantity
+	cartItem.Quantity += item.Quantity
 	return r.db.Save(&cartItem).Error
 }
 
@@ -62,9 +83,45 @@ func (r *CartRepository) UpdateItemQuantity(cartItemID uint, quantity int) error
 }
 
 func (r *CartRepository) RemoveItem(cartItemID uint) error {
-	return r.db.Delete(&models.CartItem{}, cartItemID).Error
+	var cartItem models.CartItem
+	if err := r.db.First(&cartItem, cartItemID).Error; err != nil {
+		return err
+	}
+	if err := r.db.Where("cart_item_id = ?", cartItem.ID).Delete(&models.CartItemOption{}).Error; err != nil {
+		return err
+	}
+	if err := r.db.Delete(&cartItem).Error; err != nil {
+		return err
+	}
+
+	// Release the restaurant once the last item is gone
+	var remaining int64
+	if err := r.db.Model(&models.CartItem{}).Where("cart_id = ?", cartItem.CartID).Count(&remaining).Error; err != nil {
+		return err
+	}
+	if remaining == 0 {
+		return r.SetRestaurant(cartItem.CartID, nil)
+	}
+	return nil
 }
 
+// ClearCart removes every item and the promo code from a cart
 func (r *CartRepository) ClearCart(cartID uint) error {
-	return r.db.Where("cart_id = ?", cartID).Delete(&models.CartItem{}).Error
+	items := r.db.Model(&models.CartItem{}).Select("id").Where("cart_id = ?", cartID)
+	if err := r.db.Where("cart_item_id IN (?)", items).Delete(&models.CartItemOption{}).Error; err != nil {
+		return err
+	}
+	if err := r.db.Where("cart_id = ?", cartID).Delete(&models.CartItem{}).Error; err != nil {
+		return err
+	}
+	return r.db.Model(&models.Cart{}).Where("id = ?", cartID).
+		Updates(map[string]any{"restaurant_id": nil, "promotion_id": nil}).Error
+}
+
+func (r *CartRepository) SetRestaurant(cartID uint, restaurantID *uint) error {
+	return r.db.Model(&models.Cart{}).Where("id = ?", cartID).Update("restaurant_id", restaurantID).Error
+}
+
+func (r *CartRepository) SetPromotion(cartID uint, promotionID *uint) error {
+	return r.db.Model(&models.Cart{}).Where("id = ?", cartID).Update("promotion_id", promotionID).Error
 }
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return IdempotencyRepository{db: db}
+}
+
+func (r *IdempotencyRepository) Create(record *models.IdempotencyKey) error {
+	return r.db.Create(record).Error
+}
+
+func (r *IdempotencyRepository) Find(userID uint, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := r.db.Where("user_id = ? AND key = ?", userID, key).First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// SaveResponse stores the response of the request that claimed a key
+func (r *IdempotencyRepository) SaveResponse(id uint, statusCode int, contentType string, body []byte) error {
+	return r.db.Model(&models.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]any{
+		"status_code":   statusCode,
+		"content_type":  contentType,
+		"response_body": body,
+	}).Error
+}
+
+// Delete removes a key for good so that it can be claimed again
+func (r *IdempotencyRepository) Delete(id uint) error {
+	return r.db.Unscoped().Where("id = ?", id).Delete(&models.IdempotencyKey{}).Error
+}
+
+// DeleteExpired removes a user's keys that expired before now
+func (r *IdempotencyRepository) DeleteExpired(userID uint, now time.Time) error {
+	return r.db.Unscoped().Where("user_id = ? AND expires_at < ?", userID, now).Delete(&models.IdempotencyKey{}).Error
+}
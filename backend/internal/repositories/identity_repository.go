@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type IdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return IdentityRepository{db: db}
+}
+
+func (r *IdentityRepository) FindIdentity(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *IdentityRepository) FindByUser(userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := r.db.Where("user_id = ?", userID).Find(&identities).Error
+	return identities, err
+}
+
+func (r *IdentityRepository) CreateIdentity(identity *models.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// CreateUserWithIdentity signs up a user from an external identity. Such users have
+// no phone number yet, so the column is left NULL.
+func (r *IdentityRepository) CreateUserWithIdentity(user *models.User, identity *models.UserIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Phone").Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+func (r *IdentityRepository) CreateAuthRequest(request *models.OIDCAuthRequest) error {
+	return r.db.Create(request).Error
+}
+
+// ConsumeAuthRequest returns and deletes the login request with the given state, so
+// each state can only be completed once
+func (r *IdentityRepository) ConsumeAuthRequest(state string) (*models.OIDCAuthRequest, error) {
+	var request models.OIDCAuthRequest
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state = ?", state).First(&request).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id = ?", request.ID).Delete(&models.OIDCAuthRequest{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
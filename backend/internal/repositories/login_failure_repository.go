@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type LoginFailureRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginFailureRepository(db *gorm.DB) LoginFailureRepository {
+	return LoginFailureRepository{db: db}
+}
+
+func (r *LoginFailureRepository) Create(failure *models.LoginFailure) error {
+	return r.db.Create(failure).Error
+}
+
+// FindByEmail returns the most recent failures for an email, newest first
+func (r *LoginFailureRepository) FindByEmail(email string, limit int) ([]models.LoginFailure, error) {
+	var failures []models.LoginFailure
+	err := r.db.Where("email = ?", email).Order("created_at DESC").Limit(limit).Find(&failures).Error
+	return failures, err
+}
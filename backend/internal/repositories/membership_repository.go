@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type MembershipRepository struct {
+	db *gorm.DB
+}
+
+func NewMembershipRepository(db *gorm.DB) MembershipRepository {
+	return MembershipRepository{db: db}
+}
+
+func (r *MembershipRepository) FindMembers(restaurantID uint) ([]models.RestaurantMember, error) {
+	var members []models.RestaurantMember
+	err := r.db.Preload("User").Where("restaurant_id = ?", restaurantID).Find(&members).Error
+	return members, err
+}
+
+func (r *MembershipRepository) FindByUser(userID uint) ([]models.RestaurantMember, error) {
+	var members []models.RestaurantMember
+	err := r.db.Preload("Restaurant").Where("user_id = ?", userID).Find(&members).Error
+	return members, err
+}
+
+func (r *MembershipRepository) IsMember(restaurantID uint, userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RestaurantMember{}).
+		Where("restaurant_id = ? AND user_id = ?", restaurantID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FindMember returns a membership, but only if it belongs to the given restaurant
+func (r *MembershipRepository) FindMember(restaurantID uint, memberID uint) (*models.RestaurantMember, error) {
+	var member models.RestaurantMember
+	if err := r.db.Where("restaurant_id = ?", restaurantID).First(&member, memberID).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// DeleteMember removes a membership, but only from the given restaurant. The row is
+// deleted for good so the user can be invited again.
+func (r *MembershipRepository) DeleteMember(restaurantID uint, memberID uint) error {
+	result := r.db.Unscoped().Where("restaurant_id = ?", restaurantID).Delete(&models.RestaurantMember{}, memberID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *MembershipRepository) CreateInvitation(invitation *models.RestaurantInvitation) error {
+	return r.db.Create(invitation).Error
+}
+
+// FindInvitationByTokenHash returns an invitation that has not been accepted yet
+func (r *MembershipRepository) FindInvitationByTokenHash(tokenHash string) (*models.RestaurantInvitation, error) {
+	var invitation models.RestaurantInvitation
+	err := r.db.Preload("Restaurant").
+		Where("token_hash = ? AND accepted_at IS NULL", tokenHash).
+		First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *MembershipRepository) FindPendingInvitations(restaurantID uint) ([]models.RestaurantInvitation, error) {
+	var invitations []models.RestaurantInvitation
+	err := r.db.Where("restaurant_id = ? AND accepted_at IS NULL AND expires_at > ?", restaurantID, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// AcceptInvitation creates the membership and marks the invitation used in one
+// transaction. The user's global role is left alone; their access to the
+// restaurant comes from the membership.
+func (r *MembershipRepository) AcceptInvitation(invitation *models.RestaurantInvitation, member *models.RestaurantMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(invitation).Update("accepted_at", &now).Error; err != nil {
+			return err
+		}
+		invitation.AcceptedAt = &now
+		return nil
+	})
+}
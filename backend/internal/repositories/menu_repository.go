@@ -1,8 +1,11 @@
 package repositories
 
 import (
+	"slices"
+
 	"github.com/manjurulhoque/foodie/backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MenuRepository struct {
@@ -13,6 +16,11 @@ func NewMenuRepository(db *gorm.DB) MenuRepository {
 	return MenuRepository{db: db}
 }
 
+// WithTx returns a copy of the repository that runs its queries on tx
+func (r *MenuRepository) WithTx(tx *gorm.DB) MenuRepository {
+	return MenuRepository{db: tx}
+}
+
 func (r *MenuRepository) FindAllPaginated(page int, limit int) ([]models.MenuItem, int64, error) {
 	var menuItems []models.MenuItem
 	var total int64
@@ -34,18 +42,25 @@ func (r *MenuRepository) Create(menuItem map[string]interface{}) error {
 	return r.db.Model(&models.MenuItem{}).Create(menuItem).Error
 }
 
+// FindByID returns a menu item with its modifier groups and their options
 func (r *MenuRepository) FindByID(id uint) (*models.MenuItem, error) {
 	var menuItem models.MenuItem
-	err := r.db.First(&menuItem, id).Error
+	err := withModifiers(r.db).First(&menuItem, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &menuItem, nil
 }
 
+func (r *MenuRepository) FindByIDs(ids []uint) ([]models.MenuItem, error) {
+	var menuItems []models.MenuItem
+	err := withModifiers(r.db).Where("id IN ?", ids).Find(&menuItems).Error
+	return menuItems, err
+}
+
 func (r *MenuRepository) FindByRestaurant(restaurantID uint) ([]models.MenuItem, error) {
 	var menuItems []models.MenuItem
-	err := r.db.Where("restaurant_id = ?", restaurantID).Find(&menuItems).Error
+	err := withModifiers(r.db).Where("restaurant_id = ?", restaurantID).Find(&menuItems).Error
 	return menuItems, err
 }
 
@@ -64,3 +79,93 @@ func (r *MenuRepository) FindByCategory(restaurantID uint, category string) ([]m
 	err := r.db.Where("restaurant_id = ? AND category = ?", restaurantID, category).Find(&menuItems).Error
 	return menuItems, err
 }
+
+// ReplaceModifierGroups makes groups the modifier groups of a menu item. Groups and
+// options with the ID of an existing one of the item update it, those without an ID are
+// created, and existing ones left out are deleted.
+func (r *MenuRepository) ReplaceModifierGroups(menuItemID uint, groups []models.ModifierGroup) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.ModifierGroup
+		if err := tx.Preload("Options").Where("menu_item_id = ?", menuItemID).Find(&existing).Error; err != nil {
+			return err
+		}
+		existingOptions := make(map[uint]map[uint]bool, len(existing))
+		for _, group := range existing {
+			existingOptions[group.ID] = make(map[uint]bool, len(group.Options))
+			for _, option := range group.Options {
+				existingOptions[group.ID][option.ID] = true
+			}
+		}
+
+		keptGroups := make([]uint, 0, len(groups))
+		for i := range groups {
+			group := &groups[i]
+			group.MenuItemID = menuItemID
+			options := group.Options
+			group.Options = nil
+			if _, ok := existingOptions[group.ID]; !ok {
+				group.ID = 0
+			}
+			if err := saveModifier(tx, group, group.ID == 0); err != nil {
+				return err
+			}
+			keptGroups = append(keptGroups, group.ID)
+
+			keptOptions := make([]uint, 0, len(options))
+			for j := range options {
+				option := &options[j]
+				option.GroupID = group.ID
+				if !existingOptions[group.ID][option.ID] {
+					option.ID = 0
+				}
+				available := option.IsAvailable
+				if err := saveModifier(tx, option, option.ID == 0); err != nil {
+					return err
+				}
+				// gorm inserts the column default in place of a false IsAvailable
+				if !available && option.IsAvailable {
+					option.IsAvailable = false
+					if err := tx.Model(option).Update("is_available", false).Error; err != nil {
+						return err
+					}
+				}
+				keptOptions = append(keptOptions, option.ID)
+			}
+			group.Options = options
+			if err := tx.Where("group_id = ? AND id NOT IN ?", group.ID, append(keptOptions, 0)).
+				Delete(&models.ModifierOption{}).Error; err != nil {
+				return err
+			}
+		}
+
+		var removed []uint
+		for _, group := range existing {
+			if !slices.Contains(keptGroups, group.ID) {
+				removed = append(removed, group.ID)
+			}
+		}
+		if len(removed) == 0 {
+			return nil
+		}
+		if err := tx.Where("group_id IN ?", removed).Delete(&models.ModifierOption{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", removed).Delete(&models.ModifierGroup{}).Error
+	})
+}
+
+// saveModifier creates a modifier group or option, or writes every column of an
+// existing one so that zero values are saved too
+func saveModifier(tx *gorm.DB, value any, create bool) error {
+	if create {
+		return tx.Omit(clause.Associations).Create(value).Error
+	}
+	return tx.Select("*").Omit("created_at", "deleted_at", clause.Associations).Updates(value).Error
+}
+
+// withModifiers preloads the modifier groups and options of menu items in menu order
+func withModifiers(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("ModifierGroups", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("ModifierGroups.Options", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") })
+}
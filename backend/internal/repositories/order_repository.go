@@ -1,10 +1,22 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/manjurulhoque/foodie/backend/internal/models"
 	"gorm.io/gorm"
 )
 
+// OrderFilter narrows down order listings; zero values are ignored
+type OrderFilter struct {
+	Status       string
+	RestaurantID uint
+	From         *time.Time
+	To           *time.Time
+	Page         int
+	Limit        int
+}
+
 type OrderRepository struct {
 	db *gorm.DB
 }
@@ -13,13 +25,23 @@ func NewOrderRepository(db *gorm.DB) OrderRepository {
 	return OrderRepository{db: db}
 }
 
+// WithTx returns a copy of the repository that runs its queries on tx
+func (r *OrderRepository) WithTx(tx *gorm.DB) OrderRepository {
+	return OrderRepository{db: tx}
+}
+
+// Transaction runs fn inside a database transaction, rolling back if it returns an error
+func (r *OrderRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
 func (r *OrderRepository) Create(order *models.Order) error {
 	return r.db.Create(order).Error
 }
 
 func (r *OrderRepository) FindByID(id uint) (*models.Order, error) {
 	var order models.Order
-	err := r.db.Preload("Items").Preload("Items.MenuItem").First(&order, id).Error
+	err := r.db.Preload("Restaurant").Preload("Items").Preload("Items.MenuItem").Preload("Items.Options").Preload("Discounts").First(&order, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -28,14 +50,63 @@ func (r *OrderRepository) FindByID(id uint) (*models.Order, error) {
 
 func (r *OrderRepository) FindByUser(userID uint) ([]models.Order, error) {
 	var orders []models.Order
-	err := r.db.Preload("Items").Preload("Items.MenuItem").Where("user_id = ?", userID).Find(&orders).Error
+	err := r.db.Preload("Items").Preload("Items.MenuItem").Preload("Items.Options").Where("user_id = ?", userID).Find(&orders).Error
 	return orders, err
 }
 
-func (r *OrderRepository) FindByRestaurant(restaurantID uint) ([]models.Order, error) {
+// managedBy limits an order query to restaurants userID owns or is a member of
+func managedBy(userID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins("JOIN restaurants ON restaurants.id = orders.restaurant_id").
+			Where("restaurants.user_id = ? OR restaurants.id IN "+
+				"(SELECT restaurant_id FROM restaurant_members WHERE user_id = ? AND deleted_at IS NULL)", userID, userID)
+	}
+}
+
+// FindManagedBy returns the orders of restaurants userID owns or works at, newest first
+func (r *OrderRepository) FindManagedBy(userID uint, filter OrderFilter) ([]models.Order, int64, error) {
 	var orders []models.Order
-	err := r.db.Preload("Items").Preload("Items.MenuItem").Where("restaurant_id = ?", restaurantID).Find(&orders).Error
-	return orders, err
+	var total int64
+
+	query := r.db.Model(&models.Order{}).Scopes(managedBy(userID))
+
+	if filter.Status != "" {
+		query = query.Where("orders.status = ?", filter.Status)
+	}
+	if filter.RestaurantID > 0 {
+		query = query.Where("orders.restaurant_id = ?", filter.RestaurantID)
+	}
+	if filter.From != nil {
+		query = query.Where("orders.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("orders.created_at < ?", *filter.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (filter.Page - 1) * filter.Limit
+	err := query.Preload("User").Preload("Restaurant").Preload("Items").Preload("Items.MenuItem").Preload("Items.Options").
+		Order("orders.created_at DESC").
+		Offset(offset).
+		Limit(filter.Limit).
+		Find(&orders).Error
+
+	return orders, total, err
+}
+
+// FindByIDManagedBy returns an order only if it belongs to a restaurant userID owns or works at
+func (r *OrderRepository) FindByIDManagedBy(id uint, userID uint) (*models.Order, error) {
+	var order models.Order
+	err := r.db.Preload("Restaurant").Preload("Items").Preload("Items.MenuItem").Preload("Items.Options").
+		Scopes(managedBy(userID)).
+		First(&order, "orders.id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
 }
 
 func (r *OrderRepository) Update(order *models.Order) error {
@@ -50,8 +121,6 @@ func (r *OrderRepository) UpdatePaymentStatus(id uint, status string) error {
 	return r.db.Model(&models.Order{}).Where("id = ?", id).Update("payment_status", status).Error
 }
 
-func (r *OrderRepository) FindAll() ([]models.Order, error) {
-	var orders []models.Order
-	err := r.db.Preload("User").Preload("Restaurant").Preload("Items").Preload("Items.MenuItem").Find(&orders).Error
-	return orders, err
+func (r *OrderRepository) CreateStatusHistory(history *models.OrderStatusHistory) error {
+	return r.db.Create(history).Error
 }
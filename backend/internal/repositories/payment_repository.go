@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type PaymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) PaymentRepository {
+	return PaymentRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries on tx
+func (r *PaymentRepository) WithTx(tx *gorm.DB) PaymentRepository {
+	return PaymentRepository{db: tx}
+}
+
+// Transaction runs fn inside a database transaction, rolling back if it returns an error
+func (r *PaymentRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
+func (r *PaymentRepository) Create(payment *models.Payment) error {
+	return r.db.Create(payment).Error
+}
+
+func (r *PaymentRepository) UpdateStatus(id uint, status string) error {
+	return r.db.Model(&models.Payment{}).Where("id = ?", id).Update("status", status).Error
+}
+
+func (r *PaymentRepository) FindByProviderRef(provider, ref string) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Where("provider = ? AND provider_ref = ?", provider, ref).First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *PaymentRepository) FindByOrder(orderID uint) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.Where("order_id = ?", orderID).Order("id").Find(&payments).Error
+	return payments, err
+}
+
+// FindOpenByOrder returns the latest payment of an order that is still waiting for
+// the customer or to be captured
+func (r *PaymentRepository) FindOpenByOrder(orderID uint) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Where("order_id = ? AND status IN ?", orderID, []string{models.PaymentStatusPending, models.PaymentStatusAuthorized}).
+		Order("id DESC").
+		First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// RecordEvent stores a webhook event and reports false if it had already been recorded
+func (r *PaymentRepository) RecordEvent(event *models.PaymentEvent) (bool, error) {
+	var existing models.PaymentEvent
+	err := r.db.Where("provider = ? AND event_id = ?", event.Provider, event.EventID).First(&existing).Error
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if err := r.db.Create(event).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Lock takes the write lock of a payment row until the surrounding transaction ends.
+// The row is touched rather than selected FOR UPDATE so SQLite, which has no row
+// locks, serialises the callers as well.
+func (r *PaymentRepository) Lock(id uint) error {
+	return r.db.Model(&models.Payment{}).Where("id = ?", id).Update("updated_at", time.Now()).Error
+}
+
+// FindCapturedByOrder returns the latest payment of an order whose money was taken
+func (r *PaymentRepository) FindCapturedByOrder(orderID uint) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Where("order_id = ? AND status = ?", orderID, models.PaymentStatusPaid).
+		Order("id DESC").
+		First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromotionRepository struct {
+	db *gorm.DB
+}
+
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return PromotionRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries on tx
+func (r *PromotionRepository) WithTx(tx *gorm.DB) PromotionRepository {
+	return PromotionRepository{db: tx}
+}
+
+// Create saves a promotion without touching the restaurant it refers to. A code that
+// is already in use fails with gorm.ErrDuplicatedKey.
+func (r *PromotionRepository) Create(promotion *models.Promotion) error {
+	active := promotion.IsActive
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(promotion).Error; err != nil {
+			return r.translate(err)
+		}
+		if active {
+			return nil
+		}
+		// gorm inserts the column default in place of a false IsActive
+		promotion.IsActive = false
+		return tx.Model(promotion).Update("is_active", false).Error
+	})
+}
+
+// Update changes the given columns of a promotion. A code that is already in use
+// fails with gorm.ErrDuplicatedKey.
+func (r *PromotionRepository) Update(id uint, updates map[string]any) error {
+	return r.translate(r.db.Model(&models.Promotion{}).Where("id = ?", id).Updates(updates).Error)
+}
+
+// translate turns the database's unique constraint errors into gorm.ErrDuplicatedKey
+func (r *PromotionRepository) translate(err error) error {
+	if translator, ok := r.db.Dialector.(gorm.ErrorTranslator); ok && err != nil {
+		return translator.Translate(err)
+	}
+	return err
+}
+
+func (r *PromotionRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Promotion{}, id).Error
+}
+
+// FindByID returns a promotion with the restaurant it is limited to, if any
+func (r *PromotionRepository) FindByID(id uint) (*models.Promotion, error) {
+	var promotion models.Promotion
+	err := r.db.Preload("Restaurant").First(&promotion, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+// FindByIDForUpdate returns a promotion and locks its row until the transaction ends,
+// so checkouts using it count its uses one at a time. SQLite has no row locks, but
+// only lets one of two such transactions commit.
+func (r *PromotionRepository) FindByIDForUpdate(id uint) (*models.Promotion, error) {
+	var promotion models.Promotion
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promotion, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+func (r *PromotionRepository) FindByCode(code string) (*models.Promotion, error) {
+	var promotion models.Promotion
+	err := r.db.Preload("Restaurant").Where("code = ?", code).First(&promotion).Error
+	if err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+func (r *PromotionRepository) FindAll() ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	err := r.db.Preload("Restaurant").Order("id DESC").Find(&promotions).Error
+	return promotions, err
+}
+
+// FindOwnedBy returns the promotions of restaurants userID owns, newest first
+func (r *PromotionRepository) FindOwnedBy(userID uint) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	err := r.db.Preload("Restaurant").
+		Joins("JOIN restaurants ON restaurants.id = promotions.restaurant_id").
+		Where("restaurants.user_id = ? OR restaurants.id IN "+
+			"(SELECT restaurant_id FROM restaurant_members WHERE user_id = ? AND role = ? AND deleted_at IS NULL)",
+			userID, userID, models.MemberRoleOwner).
+		Order("promotions.id DESC").
+		Find(&promotions).Error
+	return promotions, err
+}
+
+// CountUses counts the orders a promotion was used on, leaving out cancelled and
+// rejected ones. With a userID only that customer's orders are counted.
+func (r *PromotionRepository) CountUses(promotionID uint, userID *uint) (int64, error) {
+	query := r.db.Model(&models.OrderDiscount{}).
+		Joins("JOIN orders ON orders.id = order_discounts.order_id").
+		Where("order_discounts.promotion_id = ? AND orders.status NOT IN ?",
+			promotionID, []string{models.OrderStatusCancelled, models.OrderStatusRejected})
+	if userID != nil {
+		query = query.Where("orders.user_id = ?", *userID)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
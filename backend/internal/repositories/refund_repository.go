@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type RefundRepository struct {
+	db *gorm.DB
+}
+
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return RefundRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries on tx
+func (r *RefundRepository) WithTx(tx *gorm.DB) RefundRepository {
+	return RefundRepository{db: tx}
+}
+
+// Create stores a refund together with its items
+func (r *RefundRepository) Create(refund *models.Refund) error {
+	return r.db.Create(refund).Error
+}
+
+func (r *RefundRepository) FindByOrder(orderID uint) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := r.db.Preload("Items").Where("order_id = ?", orderID).Order("id").Find(&refunds).Error
+	return refunds, err
+}
+
+// TotalByPayment sums the amounts refunded from a payment
+func (r *RefundRepository) TotalByPayment(paymentID uint) (float64, error) {
+	var total float64
+	err := r.db.Model(&models.Refund{}).Where("payment_id = ?", paymentID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+	return total, err
+}
+
+// RefundedQuantities returns how many units of each item of an order were refunded
+func (r *RefundRepository) RefundedQuantities(orderID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	err := r.db.Model(&models.RefundItem{}).
+		Select("refund_items.order_item_id, SUM(refund_items.quantity) AS quantity").
+		Joins("JOIN refunds ON refunds.id = refund_items.refund_id AND refunds.deleted_at IS NULL").
+		Where("refunds.order_id = ?", orderID).
+		Group("refund_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	quantities := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		quantities[row.OrderItemID] = row.Quantity
+	}
+	return quantities, nil
+}
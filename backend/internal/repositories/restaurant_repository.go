@@ -13,6 +13,11 @@ func NewRestaurantRepository(db *gorm.DB) RestaurantRepository {
 	return RestaurantRepository{db: db}
 }
 
+// WithTx returns a copy of the repository that runs its queries on tx
+func (r *RestaurantRepository) WithTx(tx *gorm.DB) RestaurantRepository {
+	return RestaurantRepository{db: tx}
+}
+
 func (r *RestaurantRepository) Create(restaurant map[string]interface{}) error {
 	return r.db.Model(&models.Restaurant{}).Create(&restaurant).Error
 }
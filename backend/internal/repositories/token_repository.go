@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+	return TokenRepository{db: db}
+}
+
+// WithTx returns a copy of the repository that runs its queries on tx
+func (r *TokenRepository) WithTx(tx *gorm.DB) TokenRepository {
+	return TokenRepository{db: tx}
+}
+
+// Transaction runs fn inside a database transaction, rolling back if it returns an error
+func (r *TokenRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
+func (r *TokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *TokenRepository) FindRefreshToken(jti string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("jti = ?", jti).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeRefreshToken marks a refresh token as used. It reports false when the token
+// had already been revoked, so two concurrent refreshes cannot both succeed.
+func (r *TokenRepository) RevokeRefreshToken(jti string, replacedBy string) (bool, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("jti = ? AND revoked_at IS NULL", jti).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by": replacedBy})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeFamily revokes every refresh token issued for the same login session
+func (r *TokenRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// ClaimAccessToken revokes a single-use token and reports whether this call was the
+// one that did; false means it had already been used or revoked. The unique jti
+// index decides between concurrent callers.
+func (r *TokenRepository) ClaimAccessToken(jti string, expiresAt time.Time) (bool, error) {
+	err := r.db.Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+	if translator, ok := r.db.Dialector.(gorm.ErrorTranslator); ok && err != nil {
+		err = translator.Translate(err)
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RevokeAccessToken blocks an access token until it would have expired anyway
+func (r *TokenRepository) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	return r.db.Where(models.RevokedToken{JTI: jti}).
+		Attrs(models.RevokedToken{ExpiresAt: expiresAt}).
+		FirstOrCreate(&models.RevokedToken{}).Error
+}
+
+func (r *TokenRepository) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// RevokeAllSessions ends every session of a user: refresh tokens are revoked and the
+// user's token version is bumped so access tokens issued until now stop being accepted
+func (r *TokenRepository) RevokeAllSessions(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1")).Error
+	})
+}
+
+func (r *TokenRepository) CreatePasswordResetToken(token *models.PasswordResetToken) error {
+	return r.db.Create(token).Error
+}
+
+// FindPasswordResetToken returns a reset token that has not been used yet
+func (r *TokenRepository) FindPasswordResetToken(tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.Where("token_hash = ? AND used_at IS NULL", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// UsePasswordResetToken marks a reset token used. It reports false when the token had
+// already been used, so a token cannot be redeemed twice.
+func (r *TokenRepository) UsePasswordResetToken(id uint) (bool, error) {
+	result := r.db.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// InvalidatePasswordResetTokens marks every outstanding reset token of a user used
+func (r *TokenRepository) InvalidatePasswordResetTokens(userID uint) error {
+	return r.db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type TwoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepository(db *gorm.DB) TwoFactorRepository {
+	return TwoFactorRepository{db: db}
+}
+
+func (r *TwoFactorRepository) FindSecret(userID uint) (*models.TOTPSecret, error) {
+	var secret models.TOTPSecret
+	err := r.db.Where("user_id = ?", userID).First(&secret).Error
+	if err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// SaveSecret stores a new, unconfirmed secret for a user, replacing any earlier one
+func (r *TwoFactorRepository) SaveSecret(secret *models.TOTPSecret) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", secret.UserID).Delete(&models.TOTPSecret{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(secret).Error
+	})
+}
+
+// Enable confirms the user's secret, replaces their recovery codes and turns
+// two-factor authentication on in one transaction
+func (r *TwoFactorRepository) Enable(secret *models.TOTPSecret, codes []models.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(secret).Update("confirmed_at", &now).Error; err != nil {
+			return err
+		}
+		secret.ConfirmedAt = &now
+		if err := replaceRecoveryCodes(tx, secret.UserID, codes); err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", secret.UserID).Update("two_factor_enabled", true).Error
+	})
+}
+
+// Disable removes the user's secret and recovery codes and turns two-factor authentication off
+func (r *TwoFactorRepository) Disable(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.TOTPSecret{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", false).Error
+	})
+}
+
+// UseStep records that the code of a time step was used. It reports false when a code
+// of this or a later step was already accepted, so codes cannot be replayed.
+func (r *TwoFactorRepository) UseStep(secretID uint, step int64) (bool, error) {
+	result := r.db.Model(&models.TOTPSecret{}).
+		Where("id = ? AND last_used_step < ?", secretID, step).
+		Update("last_used_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID uint, codes []models.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codes []models.RecoveryCode) error {
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Create(&codes).Error
+}
+
+// UseRecoveryCode marks an unused recovery code used, reporting whether there was one
+func (r *TwoFactorRepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *TwoFactorRepository) CountRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
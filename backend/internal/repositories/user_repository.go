@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/manjurulhoque/foodie/backend/internal/models"
 	"gorm.io/gorm"
 )
@@ -13,6 +15,8 @@ type UserRepository interface {
 	UpdateUser(uint, map[string]interface{}) error
 	GetDB() *gorm.DB
 	FindAllUsers() ([]models.PublicUser, error)
+	// MarkVerificationSent records when the last verification email was sent
+	MarkVerificationSent(id uint, sentAt time.Time) error
 }
 
 type userRepository struct {
@@ -39,7 +43,7 @@ func (r *userRepository) GetUserById(id uint) (*models.User, error) {
 
 func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.Preload("Memberships").Where("email = ?", email).First(&user).Error
 	return &user, err
 }
 
@@ -58,3 +62,7 @@ func (r *userRepository) FindAllUsers() ([]models.PublicUser, error) {
 	err := r.db.Model(&models.User{}).Find(&users).Error
 	return users, err
 }
+
+func (r *userRepository) MarkVerificationSent(id uint, sentAt time.Time) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("email_verification_sent_at", sentAt).Error
+}
@@ -2,13 +2,14 @@ package services
 
 import (
 	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
 	"github.com/manjurulhoque/foodie/backend/internal/repositories"
 )
 
 type AddressService interface {
 	CreateAddress(address *models.Address) error
-	UpdateAddress(id uint, address map[string]interface{}) error
-	DeleteAddress(id uint) error
+	UpdateAddress(user *models.User, id uint, address map[string]interface{}) error
+	DeleteAddress(user *models.User, id uint) error
 	GetUserAddresses(userID uint) ([]models.Address, error)
 	GetAddress(id uint) (*models.Address, error)
 }
@@ -25,11 +26,17 @@ func (s *addressService) CreateAddress(address *models.Address) error {
 	return s.repo.Create(address)
 }
 
-func (s *addressService) UpdateAddress(id uint, address map[string]interface{}) error {
+func (s *addressService) UpdateAddress(user *models.User, id uint, address map[string]interface{}) error {
+	if err := s.authorize(user, policy.ActionUpdate, id); err != nil {
+		return err
+	}
 	return s.repo.Update(id, address)
 }
 
-func (s *addressService) DeleteAddress(id uint) error {
+func (s *addressService) DeleteAddress(user *models.User, id uint) error {
+	if err := s.authorize(user, policy.ActionDelete, id); err != nil {
+		return err
+	}
 	return s.repo.Delete(id)
 }
 
@@ -40,3 +47,11 @@ func (s *addressService) GetUserAddresses(userID uint) ([]models.Address, error)
 func (s *addressService) GetAddress(id uint) (*models.Address, error) {
 	return s.repo.FindByID(id)
 }
+
+func (s *addressService) authorize(user *models.User, action policy.Action, id uint) error {
+	existing, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	return policy.Authorize(user, action, existing)
+}
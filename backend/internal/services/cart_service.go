@@ -1,16 +1,39 @@
 package services
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
 	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"gorm.io/gorm"
 )
 
+var ErrCartRestaurantMismatch = errors.New("cart already contains items from another restaurant")
+
 type CartService struct {
-	repo repositories.CartRepository
+	repo           repositories.CartRepository
+	menuRepo       repositories.MenuRepository
+	promotionRepo  repositories.PromotionRepository
+	restaurantRepo repositories.RestaurantRepository
+	pricing        PricingService
 }
 
-func NewCartService(repo repositories.CartRepository) CartService {
-	return CartService{repo: repo}
+func NewCartService(
+	repo repositories.CartRepository,
+	menuRepo repositories.MenuRepository,
+	promotionRepo repositories.PromotionRepository,
+	restaurantRepo repositories.RestaurantRepository,
+	pricing PricingService,
+) CartService {
+	return CartService{
+		repo:           repo,
+		menuRepo:       menuRepo,
+		promotionRepo:  promotionRepo,
+		restaurantRepo: restaurantRepo,
+		pricing:        pricing,
+	}
 }
 
 func (s *CartService) GetUserCart(userID uint) (*models.Cart, error) {
@@ -21,22 +44,147 @@ func (s *CartService) GetCartItems(cartID uint) ([]models.CartItem, error) {
 	return s.repo.FindItemsByCart(cartID)
 }
 
-func (s *CartService) AddToCart(userID uint, menuItemID uint, quantity int) error {
-	cart, err := s.repo.FindByUser(userID)
+// CartItemInput is a menu item as the customer configured it
+type CartItemInput struct {
+	MenuItemID uint   `json:"menu_item_id" binding:"required"`
+	Quantity   int    `json:"quantity" binding:"required,min=1"`
+	OptionIDs  []uint `json:"option_ids"`
+	Notes      string `json:"notes" binding:"max=500"`
+}
+
+// AddToCart adds a menu item with the chosen options to the user's cart, returning
+// ErrInvalidOptions if they break the rules of the item's modifier groups. A cart only
+// holds items from one restaurant; when replace is set, items from another restaurant
+// are dropped first, otherwise ErrCartRestaurantMismatch is returned.
+func (s *CartService) AddToCart(userID uint, input CartItemInput, replace bool) error {
+	menuItem, err := s.menuRepo.FindByID(input.MenuItemID)
 	if err != nil {
 		return err
 	}
-	return s.repo.AddItem(cart.ID, menuItemID, quantity)
+	options, err := selectOptions(menuItem, input.OptionIDs)
+	if err != nil {
+		return err
+	}
+
+	// Clearing the cart, moving it to the new restaurant and adding the item happen
+	// together or not at all
+	return s.repo.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+
+		cart, err := repo.FindByUser(userID)
+		if err != nil {
+			return err
+		}
+
+		if current := cartRestaurantID(cart); len(cart.Items) > 0 && current != nil && *current != menuItem.RestaurantID {
+			if !replace {
+				return ErrCartRestaurantMismatch
+			}
+			if err := repo.ClearCart(cart.ID); err != nil {
+				return err
+			}
+		}
+
+		if cart.RestaurantID == nil || *cart.RestaurantID != menuItem.RestaurantID {
+			if err := repo.SetRestaurant(cart.ID, &menuItem.RestaurantID); err != nil {
+				return err
+			}
+		}
+
+		item := &models.CartItem{
+			CartID:     cart.ID,
+			MenuItemID: menuItem.ID,
+			Quantity:   input.Quantity,
+			Notes:      strings.TrimSpace(input.Notes),
+			OptionsKey: optionsKey(options),
+		}
+		for _, option := range options {
+			item.Options = append(item.Options, models.CartItemOption{ModifierOptionID: option.ID})
+		}
+		return repo.AddItem(item)
+	})
 }
 
-func (s *CartService) UpdateCartItemQuantity(cartItemID uint, quantity int) error {
+func (s *CartService) UpdateCartItemQuantity(user *models.User, cartItemID uint, quantity int) error {
+	if err := s.authorizeItem(user, cartItemID); err != nil {
+		return err
+	}
 	return s.repo.UpdateItemQuantity(cartItemID, quantity)
 }
 
-func (s *CartService) RemoveFromCart(cartItemID uint) error {
+func (s *CartService) RemoveFromCart(user *models.User, cartItemID uint) error {
+	if err := s.authorizeItem(user, cartItemID); err != nil {
+		return err
+	}
 	return s.repo.RemoveItem(cartItemID)
 }
 
 func (s *CartService) ClearCart(cartID uint) error {
 	return s.repo.ClearCart(cartID)
 }
+
+// ApplyPromotion puts a promo code on the user's cart if it can be used on it now and
+// returns the cart's price with the discount. Checkout checks the code again.
+func (s *CartService) ApplyPromotion(userID uint, code string) (*PriceQuote, error) {
+	cart, err := s.repo.FindByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	promotion, err := s.promotionRepo.FindByCode(normalizePromoCode(code))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPromotionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := s.pricing.QuoteCart(cart.Items)
+	if err != nil {
+		return nil, err
+	}
+	restaurant, err := s.restaurantRepo.FindByID(quote.RestaurantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPromotion(&s.promotionRepo, promotion, restaurant, userID, quote); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetPromotion(cart.ID, &promotion.ID); err != nil {
+		return nil, err
+	}
+	s.pricing.ApplyPromotion(quote, promotion)
+	return quote, nil
+}
+
+// RemovePromotion takes the promo code off the user's cart
+func (s *CartService) RemovePromotion(userID uint) error {
+	cart, err := s.repo.FindByUser(userID)
+	if err != nil {
+		return err
+	}
+	return s.repo.SetPromotion(cart.ID, nil)
+}
+
+func (s *CartService) authorizeItem(user *models.User, cartItemID uint) error {
+	cartItem, err := s.repo.FindItemByID(cartItemID)
+	if err != nil {
+		return err
+	}
+	return policy.Authorize(user, policy.ActionUpdate, cartItem)
+}
+
+// cartRestaurantID returns the restaurant a cart is tied to, falling back to its
+// items for carts created before the restaurant was tracked
+func cartRestaurantID(cart *models.Cart) *uint {
+	if cart.RestaurantID != nil {
+		return cart.RestaurantID
+	}
+	for _, item := range cart.Items {
+		if item.MenuItem.RestaurantID != 0 {
+			restaurantID := item.MenuItem.RestaurantID
+			return &restaurantID
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+func TestAddToCartSingleRestaurant(t *testing.T) {
+	db := newTestDB(t)
+	menuRepo := repositories.NewMenuRepository(db)
+	service := NewCartService(
+		repositories.NewCartRepository(db),
+		menuRepo,
+		repositories.NewPromotionRepository(db),
+		repositories.NewRestaurantRepository(db),
+		NewPricingService(menuRepo, config.PricingConfig{}),
+	)
+
+	burger := models.MenuItem{Name: "Burger", Price: 8, Category: "Main", RestaurantID: 1}
+	fries := models.MenuItem{Name: "Fries", Price: 3, Category: "Sides", RestaurantID: 1}
+	sushi := models.MenuItem{Name: "Sushi", Price: 15, Category: "Main", RestaurantID: 2}
+	db.Create(&burger)
+	db.Create(&fries)
+	db.Create(&sushi)
+
+	user := &models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleCustomer}
+	userID := user.ID
+	assert.NoError(t, service.AddToCart(userID, CartItemInput{MenuItemID: burger.ID, Quantity: 1}, false))
+	assert.NoError(t, service.AddToCart(userID, CartItemInput{MenuItemID: fries.ID, Quantity: 2}, false))
+
+	cart, err := service.GetUserCart(userID)
+	assert.NoError(t, err)
+	assert.Len(t, cart.Items, 2)
+	assert.Equal(t, uint(1), *cart.RestaurantID)
+
+	// items from another restaurant are rejected unless the cart is replaced
+	assert.ErrorIs(t, service.AddToCart(userID, CartItemInput{MenuItemID: sushi.ID, Quantity: 1}, false), ErrCartRestaurantMismatch)
+
+	assert.NoError(t, service.AddToCart(userID, CartItemInput{MenuItemID: sushi.ID, Quantity: 1}, true))
+	cart, err = service.GetUserCart(userID)
+	assert.NoError(t, err)
+	assert.Len(t, cart.Items, 1)
+	assert.Equal(t, sushi.ID, cart.Items[0].MenuItemID)
+	assert.Equal(t, uint(2), *cart.RestaurantID)
+
+	// other users cannot touch the item
+	stranger := &models.User{BaseModel: models.BaseModel{ID: 2}, Role: models.RoleAdmin}
+	assert.ErrorIs(t, service.UpdateCartItemQuantity(stranger, cart.Items[0].ID, 5), policy.ErrForbidden)
+	assert.ErrorIs(t, service.RemoveFromCart(stranger, cart.Items[0].ID), policy.ErrForbidden)
+
+	// removing the last item releases the restaurant
+	assert.NoError(t, service.RemoveFromCart(user, cart.Items[0].ID))
+	cart, err = service.GetUserCart(userID)
+	assert.NoError(t, err)
+	assert.Empty(t, cart.Items)
+	assert.Nil(t, cart.RestaurantID)
+}
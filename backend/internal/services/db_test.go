@@ -0,0 +1,21 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/db/dbtest"
+	"github.com/manjurulhoque/foodie/backend/internal/db/migrations"
+)
+
+// newTestDB opens a migrated database for service tests, see dbtest for the backends
+func newTestDB(t *testing.T) *gorm.DB {
+	db := dbtest.Open(t)
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	return db
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+// TokenTypeEmailVerification marks tokens that are only good for verifying an email address
+const TokenTypeEmailVerification = "email_verification"
+
+var (
+	ErrEmailAlreadyVerified    = errors.New("email address is already verified")
+	ErrVerificationRateLimited = errors.New("a verification email was sent recently, please try again later")
+)
+
+type EmailVerificationService interface {
+	// SendVerification emails a verification link to the user with the given address
+	SendVerification(email string) error
+	// VerifyEmail marks the address in a verification token as verified
+	VerifyEmail(token string) (*models.User, error)
+	// ResendInterval is the minimum time between two verification emails
+	ResendInterval() time.Duration
+}
+
+type emailVerificationService struct {
+	userRepo repositories.UserRepository
+	keys     *KeySet
+	mailer   Mailer
+	cfg      config.VerificationConfig
+}
+
+func NewEmailVerificationService(userRepo repositories.UserRepository, keys *KeySet, mailer Mailer, cfg config.VerificationConfig) EmailVerificationService {
+	return &emailVerificationService{userRepo: userRepo, keys: keys, mailer: mailer, cfg: cfg}
+}
+
+func (s *emailVerificationService) ResendInterval() time.Duration {
+	return s.cfg.ResendInterval
+}
+
+func (s *emailVerificationService) SendVerification(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user.IsEmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	if user.EmailVerificationSentAt != nil && time.Since(*user.EmailVerificationSentAt) < s.cfg.ResendInterval {
+		return ErrVerificationRateLimited
+	}
+
+	// The token carries the address it was issued for, so a link stops working
+	// once the user changes their email
+	now := time.Now()
+	token, err := s.keys.Sign(&JWTCustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   fmt.Sprintf("%d", user.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.TokenTTL)),
+		},
+		Email:     user.Email,
+		Id:        user.ID,
+		TokenType: TokenTypeEmailVerification,
+	})
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.cfg.LinkBaseURL, "/") + "/verify-email?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+		user.Name, link, s.cfg.TokenTTL)
+	if err := s.mailer.Send(user.Email, "Verify your email address", body); err != nil {
+		return err
+	}
+	// Only a delivered email counts towards the resend interval, so a failed
+	// attempt can be retried straight away
+	return s.userRepo.MarkVerificationSent(user.ID, now)
+}
+
+func (s *emailVerificationService) VerifyEmail(tokenString string) (*models.User, error) {
+	claims := &JWTCustomClaims{}
+	token, err := s.keys.Parse(tokenString, claims)
+	if err != nil || !token.Valid || claims.TokenType != TokenTypeEmailVerification {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetUserById(claims.Id)
+	if err != nil || !strings.EqualFold(user.Email, claims.Email) {
+		return nil, ErrInvalidToken
+	}
+	if user.IsEmailVerified {
+		return user, nil
+	}
+
+	if err := s.userRepo.UpdateUser(user.ID, map[string]interface{}{"is_email_verified": true}); err != nil {
+		return nil, err
+	}
+	user.IsEmailVerified = true
+	return user, nil
+}
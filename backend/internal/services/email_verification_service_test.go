@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+type sentMail struct {
+	to, subject, body string
+}
+
+type recordingMailer struct {
+	sent []sentMail
+	err  error // returned instead of sending when set
+}
+
+func (m *recordingMailer) Send(to, subject, body string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentMail{to: to, subject: subject, body: body})
+	return nil
+}
+
+// tokenFromMail pulls the token out of the verification link in an email body
+func tokenFromMail(t *testing.T, mail sentMail) string {
+	for _, field := range strings.Fields(mail.body) {
+		if u, err := url.Parse(field); err == nil && u.Query().Get("token") != "" {
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatalf("no verification link in %q", mail.body)
+	return ""
+}
+
+func TestEmailVerification(t *testing.T) {
+	db := newTestDB(t)
+	keys, err := NewKeySet(config.JWTConfig{Algorithm: "HS256", KeyID: "test", Secret: "test-secret"})
+	require.NoError(t, err)
+	mailer := &recordingMailer{}
+	service := NewEmailVerificationService(repositories.NewUserRepository(db), keys, mailer, config.VerificationConfig{
+		LinkBaseURL:    "http://localhost:3000/",
+		TokenTTL:       time.Hour,
+		ResendInterval: time.Minute,
+	})
+
+	user := models.User{Name: "Jane", Email: "jane@example.com", Password: "x", Phone: "1", Role: models.RoleCustomer}
+	db.Create(&user)
+
+	// a send that fails does not count towards the resend interval
+	mailer.err = errors.New("smtp unavailable")
+	assert.Error(t, service.SendVerification("jane@example.com"))
+	mailer.err = nil
+
+	require.NoError(t, service.SendVerification("jane@example.com"))
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, "jane@example.com", mailer.sent[0].to)
+	assert.Contains(t, mailer.sent[0].body, "http://localhost:3000/verify-email?token=")
+	token := tokenFromMail(t, mailer.sent[0])
+
+	t.Run("Resend is rate limited", func(t *testing.T) {
+		assert.ErrorIs(t, service.SendVerification("jane@example.com"), ErrVerificationRateLimited)
+		assert.Len(t, mailer.sent, 1)
+
+		db.Model(&user).Update("email_verification_sent_at", time.Now().Add(-2*time.Minute))
+		require.NoError(t, service.SendVerification("jane@example.com"))
+		assert.Len(t, mailer.sent, 2)
+	})
+
+	t.Run("Other token types are rejected", func(t *testing.T) {
+		access, err := keys.Sign(testClaims())
+		require.NoError(t, err)
+		_, err = service.VerifyEmail(access)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		verified, err := service.VerifyEmail(token)
+		require.NoError(t, err)
+		assert.True(t, verified.IsEmailVerified)
+
+		var stored models.User
+		db.First(&stored, user.ID)
+		assert.True(t, stored.IsEmailVerified)
+
+		assert.ErrorIs(t, service.SendVerification("jane@example.com"), ErrEmailAlreadyVerified)
+	})
+
+	t.Run("Token is bound to the address", func(t *testing.T) {
+		other := models.User{Name: "John", Email: "john@example.com", Password: "x", Phone: "2", Role: models.RoleCustomer}
+		db.Create(&other)
+		require.NoError(t, service.SendVerification("john@example.com"))
+		token := tokenFromMail(t, mailer.sent[len(mailer.sent)-1])
+
+		db.Model(&other).Update("email", "john@example.org")
+		_, err := service.VerifyEmail(token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
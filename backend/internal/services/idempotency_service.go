@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is still being processed")
+)
+
+// abandonedRequestAfter is when a key whose request never finished, e.g. because the
+// server stopped, may be claimed again. Requests cannot outlive the server's timeouts.
+const abandonedRequestAfter = 5 * time.Minute
+
+type IdempotencyService interface {
+	// Begin claims key for a request. It returns a new record to Complete or Release
+	// once the request is handled, or, with replay set, the record of an earlier
+	// identical request whose response should be sent again.
+	Begin(userID uint, key, requestHash string) (record *models.IdempotencyKey, replay bool, err error)
+	// Complete stores the response to replay for the key of record
+	Complete(record *models.IdempotencyKey, statusCode int, contentType string, body []byte) error
+	// Release gives up a claimed key without a response, so the request can be retried
+	Release(record *models.IdempotencyKey) error
+}
+
+type idempotencyService struct {
+	repo repositories.IdempotencyRepository
+	cfg  config.IdempotencyConfig
+}
+
+func NewIdempotencyService(repo repositories.IdempotencyRepository, cfg config.IdempotencyConfig) IdempotencyService {
+	return &idempotencyService{repo: repo, cfg: cfg}
+}
+
+func (s *idempotencyService) Begin(userID uint, key, requestHash string) (*models.IdempotencyKey, bool, error) {
+	now := time.Now()
+	if err := s.repo.DeleteExpired(userID, now); err != nil {
+		return nil, false, err
+	}
+
+	existing, err := s.repo.Find(userID, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			RequestHash: requestHash,
+			ExpiresAt:   now.Add(s.cfg.TTL),
+		}
+		if err := s.repo.Create(record); err == nil {
+			return record, false, nil
+		}
+		// the unique index lost a race against a concurrent request with the same key
+		existing, err = s.repo.Find(userID, key)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch {
+	case existing.RequestHash != requestHash:
+		return nil, false, ErrIdempotencyKeyReused
+	case existing.StatusCode != 0:
+		return existing, true, nil
+	case now.Sub(existing.CreatedAt) < abandonedRequestAfter:
+		return nil, false, ErrIdempotencyKeyInFlight
+	}
+	if err := s.repo.Delete(existing.ID); err != nil {
+		return nil, false, err
+	}
+	return s.Begin(userID, key, requestHash)
+}
+
+func (s *idempotencyService) Complete(record *models.IdempotencyKey, statusCode int, contentType string, body []byte) error {
+	if err := s.repo.SaveResponse(record.ID, statusCode, contentType, body); err != nil {
+		return err
+	}
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.ResponseBody = body
+	return nil
+}
+
+func (s *idempotencyService) Release(record *models.IdempotencyKey) error {
+	return s.repo.Delete(record.ID)
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	db := newTestDB(t)
+	service := NewIdempotencyService(repositories.NewIdempotencyRepository(db), config.IdempotencyConfig{TTL: time.Hour})
+
+	record, replay, err := service.Begin(1, "checkout-1", "hash-a")
+	require.NoError(t, err)
+	assert.False(t, replay)
+
+	_, _, err = service.Begin(1, "checkout-1", "hash-a")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyInFlight, "the first request has not finished")
+
+	require.NoError(t, service.Complete(record, 201, "application/json", []byte(`{"id":7}`)))
+	stored, replay, err := service.Begin(1, "checkout-1", "hash-a")
+	require.NoError(t, err)
+	assert.True(t, replay)
+	assert.Equal(t, 201, stored.StatusCode)
+	assert.Equal(t, `{"id":7}`, string(stored.ResponseBody))
+
+	_, _, err = service.Begin(1, "checkout-1", "hash-b")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	t.Run("expired keys can be used again", func(t *testing.T) {
+		db.Model(&models.IdempotencyKey{}).Where("id = ?", record.ID).Update("expires_at", time.Now().Add(-time.Minute))
+		_, replay, err := service.Begin(1, "checkout-1", "hash-b")
+		require.NoError(t, err)
+		assert.False(t, replay)
+	})
+
+	t.Run("abandoned requests can be retried", func(t *testing.T) {
+		abandoned, _, err := service.Begin(1, "checkout-2", "hash-a")
+		require.NoError(t, err)
+		db.Model(&models.IdempotencyKey{}).Where("id = ?", abandoned.ID).
+			UpdateColumn("created_at", time.Now().Add(-abandonedRequestAfter-time.Second))
+
+		retried, replay, err := service.Begin(1, "checkout-2", "hash-a")
+		require.NoError(t, err)
+		assert.False(t, replay)
+		assert.NotEqual(t, abandoned.ID, retried.ID)
+	})
+
+	t.Run("released keys can be used again", func(t *testing.T) {
+		released, _, err := service.Begin(1, "checkout-3", "hash-a")
+		require.NoError(t, err)
+		require.NoError(t, service.Release(released))
+		_, replay, err := service.Begin(1, "checkout-3", "hash-b")
+		require.NoError(t, err)
+		assert.False(t, replay)
+	})
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+)
+
+// jwtKey is one key of a KeySet. Keys kept only for verification have no signKey.
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// KeySet signs tokens with its active key and verifies them with any key it holds,
+// picked by the token's "kid" header, so keys can be rotated without logging users out
+type KeySet struct {
+	active *jwtKey
+	keys   map[string]*jwtKey
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeySet builds a KeySet from configuration. Without any HS256 secret a random one
+// is generated, which is fine for development but invalidates tokens on restart.
+func NewKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	active, err := newSigningKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{active: active, keys: map[string]*jwtKey{active.id: active}}
+	for kid, data := range cfg.PreviousKeys {
+		if kid == active.id {
+			return nil, fmt.Errorf("previous key %q has the same kid as the active key", kid)
+		}
+		key, err := parseVerificationKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("previous key %q: %w", kid, err)
+		}
+		set.keys[kid] = key
+	}
+	return set, nil
+}
+
+func newSigningKey(cfg config.JWTConfig) (*jwtKey, error) {
+	key := &jwtKey{id: cfg.KeyID}
+
+	switch cfg.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret := []byte(cfg.Secret)
+		if len(secret) == 0 {
+			slog.Warn("JWT_SECRET is not set, using a random secret; tokens will not survive a restart")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, err
+			}
+		}
+		key.method, key.signKey, key.verifyKey = jwt.SigningMethodHS256, secret, secret
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(cfg.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("parsing RS256 private key: %w", err)
+		}
+		key.method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, privateKey, &privateKey.PublicKey
+	case jwt.SigningMethodEdDSA.Alg():
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(cfg.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("parsing EdDSA private key: %w", err)
+		}
+		edKey := privateKey.(ed25519.PrivateKey)
+		key.method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, edKey, edKey.Public()
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+	return key, nil
+}
+
+// parseVerificationKey reads a retired key. PEM data may hold a public or private RSA
+// or Ed25519 key; anything else is taken as an HS256 secret.
+func parseVerificationKey(kid string, data []byte) (*jwtKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		secret := bytes.TrimSpace(data)
+		if len(secret) == 0 {
+			return nil, errors.New("empty key")
+		}
+		return &jwtKey{id: kid, method: jwt.SigningMethodHS256, verifyKey: secret}, nil
+	}
+
+	if publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return &jwtKey{id: kid, method: jwt.SigningMethodRS256, verifyKey: publicKey}, nil
+	}
+	if privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return &jwtKey{id: kid, method: jwt.SigningMethodRS256, verifyKey: &privateKey.PublicKey}, nil
+	}
+	if publicKey, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return &jwtKey{id: kid, method: jwt.SigningMethodEdDSA, verifyKey: publicKey}, nil
+	}
+	if privateKey, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		edKey := privateKey.(ed25519.PrivateKey)
+		return &jwtKey{id: kid, method: jwt.SigningMethodEdDSA, verifyKey: edKey.Public()}, nil
+	}
+	return nil, errors.New("unsupported PEM key")
+}
+
+// Sign signs claims with the active key and sets the "kid" header
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.id
+	return token.SignedString(k.active.signKey)
+}
+
+// Parse verifies a token with the key named by its "kid" header. Tokens without a
+// kid were issued before keys were named and are checked against the active key.
+func (k *KeySet) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, k.keyFunc, jwt.WithValidMethods(k.methods()))
+}
+
+func (k *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := k.active
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = k.keys[kid]; !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("key %q does not accept %s tokens", key.id, token.Method.Alg())
+	}
+	return key.verifyKey, nil
+}
+
+func (k *KeySet) methods() []string {
+	seen := map[string]bool{}
+	var methods []string
+	for _, key := range k.keys {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWKS returns the public keys of the set. HS256 secrets are never published.
+func (k *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range k.keys {
+		switch publicKey := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
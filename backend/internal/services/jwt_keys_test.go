@@ -0,0 +1,137 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+)
+
+func rsaPEM(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func ed25519PEM(t *testing.T) []byte {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func testClaims() *JWTCustomClaims {
+	return &JWTCustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Email:            "jane@example.com",
+		TokenType:        TokenTypeAccess,
+	}
+}
+
+func TestKeySetAlgorithms(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.JWTConfig
+		wantKty string
+	}{
+		{name: "HS256", cfg: config.JWTConfig{Algorithm: "HS256", KeyID: "hs", Secret: "secret"}},
+		{name: "RS256", cfg: config.JWTConfig{Algorithm: "RS256", KeyID: "rs", PrivateKey: rsaPEM(t)}, wantKty: "RSA"},
+		{name: "EdDSA", cfg: config.JWTConfig{Algorithm: "EdDSA", KeyID: "ed", PrivateKey: ed25519PEM(t)}, wantKty: "OKP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := NewKeySet(tt.cfg)
+			require.NoError(t, err)
+
+			signed, err := keys.Sign(testClaims())
+			require.NoError(t, err)
+
+			token, err := keys.Parse(signed, &JWTCustomClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.cfg.KeyID, token.Header["kid"])
+			assert.Equal(t, tt.name, token.Method.Alg())
+
+			jwks := keys.JWKS()
+			if tt.wantKty == "" {
+				assert.Empty(t, jwks.Keys, "secrets must never be published")
+				return
+			}
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, tt.wantKty, jwks.Keys[0].Kty)
+			assert.Equal(t, tt.cfg.KeyID, jwks.Keys[0].Kid)
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKeys, err := NewKeySet(config.JWTConfig{Algorithm: "HS256", KeyID: "2024", Secret: "old-secret"})
+	require.NoError(t, err)
+	oldToken, err := oldKeys.Sign(testClaims())
+	require.NoError(t, err)
+
+	keys, err := NewKeySet(config.JWTConfig{
+		Algorithm:    "RS256",
+		KeyID:        "2025",
+		PrivateKey:   rsaPEM(t),
+		PreviousKeys: map[string][]byte{"2024": []byte("old-secret\n")},
+	})
+	require.NoError(t, err)
+
+	// tokens signed with the retired key keep working until they expire
+	_, err = keys.Parse(oldToken, &JWTCustomClaims{})
+	assert.NoError(t, err)
+
+	newToken, err := keys.Sign(testClaims())
+	require.NoError(t, err)
+	token, err := keys.Parse(newToken, &JWTCustomClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "2025", token.Header["kid"])
+
+	// the retired HS256 secret is not published
+	jwks := keys.JWKS()
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "2025", jwks.Keys[0].Kid)
+
+	t.Run("Unknown kid", func(t *testing.T) {
+		other, err := NewKeySet(config.JWTConfig{Algorithm: "HS256", KeyID: "someone-else", Secret: "old-secret"})
+		require.NoError(t, err)
+		forged, err := other.Sign(testClaims())
+		require.NoError(t, err)
+
+		_, err = keys.Parse(forged, &JWTCustomClaims{})
+		assert.Error(t, err)
+	})
+
+	t.Run("Algorithm must match the key", func(t *testing.T) {
+		// an HS256 token claiming the RSA key's kid must not be accepted
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+		forged.Header["kid"] = "2025"
+		signed, err := forged.SignedString([]byte("old-secret"))
+		require.NoError(t, err)
+
+		_, err = keys.Parse(signed, &JWTCustomClaims{})
+		assert.Error(t, err)
+	})
+}
+
+func TestKeySetConfigErrors(t *testing.T) {
+	_, err := NewKeySet(config.JWTConfig{Algorithm: "none", KeyID: "x"})
+	assert.Error(t, err)
+
+	_, err = NewKeySet(config.JWTConfig{Algorithm: "RS256", KeyID: "x", PrivateKey: []byte("not a key")})
+	assert.Error(t, err)
+
+	_, err = NewKeySet(config.JWTConfig{Algorithm: "HS256", KeyID: "x", Secret: "s", PreviousKeys: map[string][]byte{"x": []byte("s")}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+// Reasons recorded for failed logins
+const (
+	LoginFailureUnknownEmail  = "unknown_email"
+	LoginFailureWrongPassword = "wrong_password"
+	LoginFailureWrongCode     = "wrong_code"
+	LoginFailureLocked        = "locked"
+)
+
+// LoginClient describes where a login attempt comes from
+type LoginClient struct {
+	IP        string
+	UserAgent string
+}
+
+// LoginLockedError is returned while an account or IP address is locked out
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// AttemptRecord is the failed login count of one account or IP address
+type AttemptRecord struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// AttemptStore keeps failed login counts. The in-memory store works for a single
+// instance; running several instances needs a shared implementation.
+type AttemptStore interface {
+	Get(key string) (AttemptRecord, error)
+	// RecordFailure atomically counts a failure for key. The record is dropped without
+	// further failures after ttl, which is given the new failure count.
+	RecordFailure(key string, ttl func(failures int) time.Duration) (AttemptRecord, error)
+	Reset(key string) error
+}
+
+type memoryAttemptEntry struct {
+	record    AttemptRecord
+	expiresAt time.Time
+}
+
+type memoryAttemptStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryAttemptEntry
+	lastSweep time.Time
+}
+
+// NewMemoryAttemptStore returns an AttemptStore that keeps records in process memory
+func NewMemoryAttemptStore() AttemptStore {
+	return &memoryAttemptStore{entries: map[string]*memoryAttemptEntry{}}
+}
+
+func (s *memoryAttemptStore) Get(key string) (AttemptRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return AttemptRecord{}, nil
+	}
+	return entry.record, nil
+}
+
+func (s *memoryAttemptStore) RecordFailure(key string, ttl func(failures int) time.Duration) (AttemptRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+
+	entry, ok := s.entries[key]
+	if !ok || now.After(entry.expiresAt) {
+		entry = &memoryAttemptEntry{}
+		s.entries[key] = entry
+	}
+	entry.record.Failures++
+	entry.record.LastFailure = now
+	entry.expiresAt = now.Add(ttl(entry.record.Failures))
+	return entry.record, nil
+}
+
+func (s *memoryAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops expired records now and then so the map does not grow without bound
+func (s *memoryAttemptStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// unlockLookback is how many of an account's latest failed logins Unlock looks
+// through for IP addresses to clear
+const unlockLookback = 100
+
+// LoginGuard tracks failed logins per account and per IP address and locks them out
+// for an exponentially growing time once they pass the configured limits
+type LoginGuard struct {
+	store AttemptStore
+	repo  repositories.LoginFailureRepository
+	cfg   config.LoginProtectionConfig
+}
+
+func NewLoginGuard(store AttemptStore, repo repositories.LoginFailureRepository, cfg config.LoginProtectionConfig) *LoginGuard {
+	return &LoginGuard{store: store, repo: repo, cfg: cfg}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// lockout returns how long a key with the given failures stays locked after its last failure
+func (g *LoginGuard) lockout(failures, limit int) time.Duration {
+	if limit <= 0 || failures < limit {
+		return 0
+	}
+	lockout := float64(g.cfg.BaseLockout) * math.Pow(2, float64(failures-limit))
+	if lockout > float64(g.cfg.MaxLockout) {
+		return g.cfg.MaxLockout
+	}
+	return time.Duration(lockout)
+}
+
+// Check returns a *LoginLockedError if the account or IP address is locked out
+func (g *LoginGuard) Check(email string, client LoginClient) error {
+	var retryAfter time.Duration
+	check := func(key string, limit int) error {
+		record, err := g.store.Get(key)
+		if err != nil {
+			return err
+		}
+		if remaining := time.Until(record.LastFailure.Add(g.lockout(record.Failures, limit))); remaining > retryAfter {
+			retryAfter = remaining
+		}
+		return nil
+	}
+
+	if err := check(accountKey(email), g.cfg.MaxAccountFailures); err != nil {
+		return err
+	}
+	if client.IP != "" {
+		if err := check(ipKey(client.IP), g.cfg.MaxIPFailures); err != nil {
+			return err
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login against the account and the IP address and
+// writes an audit record, plus a second one if the failure starts a lockout
+func (g *LoginGuard) RecordFailure(email string, userID *uint, client LoginClient, reason string) {
+	g.audit(email, userID, client, reason)
+
+	// record reports whether the failure locked key out. Locked keys are refused by
+	// Check before the password is tried, so every lockout starts here.
+	record := func(key string, limit int) bool {
+		// keep the record for as long as the lockout the failure causes
+		ttl := func(failures int) time.Duration {
+			return max(g.cfg.FailureWindow, g.lockout(failures, limit))
+		}
+		updated, err := g.store.RecordFailure(key, ttl)
+		if err != nil {
+			slog.Error("Failed to record login attempt", "key", key, "error", err)
+			return false
+		}
+		return g.lockout(updated.Failures, limit) > 0
+	}
+	locked := record(accountKey(email), g.cfg.MaxAccountFailures)
+	if client.IP != "" && record(ipKey(client.IP), g.cfg.MaxIPFailures) {
+		locked = true
+	}
+	if locked {
+		g.audit(email, userID, client, LoginFailureLocked)
+	}
+}
+
+// RecordSuccess clears the failures of the account. IP address failures are kept so a
+// valid login cannot be used to reset the count while guessing other passwords.
+func (g *LoginGuard) RecordSuccess(email string) {
+	if err := g.store.Reset(accountKey(email)); err != nil {
+		slog.Error("Failed to reset login attempts", "email", email, "error", err)
+	}
+}
+
+// Unlock clears the failures and any lockout of an account, and of the IP addresses its
+// recent failed logins came from so the user is not still locked out from there
+func (g *LoginGuard) Unlock(email string) error {
+	failures, err := g.RecentFailures(email, unlockLookback)
+	if err != nil {
+		return err
+	}
+	for _, failure := range failures {
+		if failure.IP == "" {
+			continue
+		}
+		if err := g.store.Reset(ipKey(failure.IP)); err != nil {
+			return err
+		}
+	}
+	return g.store.Reset(accountKey(email))
+}
+
+// RecentFailures returns the latest audit records for an account
+func (g *LoginGuard) RecentFailures(email string, limit int) ([]models.LoginFailure, error) {
+	return g.repo.FindByEmail(strings.ToLower(strings.TrimSpace(email)), limit)
+}
+
+func (g *LoginGuard) audit(email string, userID *uint, client LoginClient, reason string) {
+	failure := &models.LoginFailure{
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		UserID:    userID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Reason:    reason,
+	}
+	if err := g.repo.Create(failure); err != nil {
+		slog.Error("Failed to write login audit record", "email", email, "error", err)
+	}
+	slog.Warn("Failed login", "email", failure.Email, "ip", client.IP, "reason", reason)
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+func TestLoginLockout(t *testing.T) {
+	service, db := setupUserTest(t)
+
+	for i := 0; i < 3; i++ {
+		_, _, err := service.LoginUser("jane@example.com", "wrong", testClient)
+		require.Error(t, err)
+		var lockedErr *LoginLockedError
+		require.NotErrorAs(t, err, &lockedErr, "attempt %d", i+1)
+	}
+
+	// even the right password is refused while locked
+	_, _, err := service.LoginUser("jane@example.com", "secret123", testClient)
+	var lockedErr *LoginLockedError
+	require.ErrorAs(t, err, &lockedErr)
+	assert.InDelta(t, time.Minute.Seconds(), lockedErr.RetryAfter.Seconds(), 1)
+
+	var failures []models.LoginFailure
+	db.Order("id").Find(&failures)
+	require.Len(t, failures, 4)
+	assert.Equal(t, LoginFailureWrongPassword, failures[0].Reason)
+	assert.Equal(t, "192.0.2.1", failures[0].IP)
+	assert.NotNil(t, failures[0].UserID)
+	assert.Equal(t, LoginFailureLocked, failures[3].Reason, "the failure that starts the lockout is audited")
+
+	// other clients are locked out of the account too, but the client may try other accounts
+	_, _, err = service.LoginUser("jane@example.com", "secret123", LoginClient{IP: "198.51.100.7"})
+	assert.ErrorAs(t, err, &lockedErr)
+	_, _, err = service.LoginUser("john@example.com", "whatever", testClient)
+	assert.NotErrorAs(t, err, &lockedErr)
+
+	var count int64
+	db.Model(&models.LoginFailure{}).Where("email = ?", "jane@example.com").Count(&count)
+	assert.Equal(t, int64(4), count, "attempts refused while locked are not audited again")
+}
+
+func TestLoginGuard(t *testing.T) {
+	db := newTestDB(t)
+	store := NewMemoryAttemptStore()
+	guard := NewLoginGuard(store, repositories.NewLoginFailureRepository(db), config.LoginProtectionConfig{
+		MaxAccountFailures: 2,
+		MaxIPFailures:      3,
+		BaseLockout:        time.Minute,
+		MaxLockout:         3 * time.Minute,
+		FailureWindow:      15 * time.Minute,
+	})
+
+	t.Run("Lockout doubles up to the maximum", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), guard.lockout(1, 2))
+		assert.Equal(t, time.Minute, guard.lockout(2, 2))
+		assert.Equal(t, 2*time.Minute, guard.lockout(3, 2))
+		assert.Equal(t, 3*time.Minute, guard.lockout(4, 2))
+		assert.Equal(t, 3*time.Minute, guard.lockout(40, 2))
+	})
+
+	t.Run("Success and unlock reset the account", func(t *testing.T) {
+		client := LoginClient{IP: "203.0.113.1"}
+		guard.RecordFailure("a@example.com", nil, client, LoginFailureWrongPassword)
+		guard.RecordSuccess("a@example.com")
+		guard.RecordFailure("a@example.com", nil, client, LoginFailureWrongPassword)
+		assert.NoError(t, guard.Check("a@example.com", client))
+
+		guard.RecordFailure("A@example.com", nil, client, LoginFailureWrongPassword)
+		assert.Error(t, guard.Check("a@example.com", LoginClient{}), "emails are compared case-insensitively")
+
+		// the IP address has failed three times too
+		var lockedErr *LoginLockedError
+		assert.ErrorAs(t, guard.Check("b@example.com", client), &lockedErr)
+
+		require.NoError(t, guard.Unlock("a@example.com"))
+		assert.NoError(t, guard.Check("a@example.com", client), "the IP addresses the account failed from are cleared too")
+		assert.NoError(t, guard.Check("b@example.com", client))
+	})
+
+	t.Run("IP lockout spans accounts", func(t *testing.T) {
+		client := LoginClient{IP: "203.0.113.2"}
+		guard.RecordFailure("c@example.com", nil, client, LoginFailureUnknownEmail)
+		guard.RecordFailure("d@example.com", nil, client, LoginFailureUnknownEmail)
+		guard.RecordFailure("e@example.com", nil, client, LoginFailureUnknownEmail)
+
+		var lockedErr *LoginLockedError
+		assert.ErrorAs(t, guard.Check("f@example.com", client), &lockedErr)
+		assert.NoError(t, guard.Check("f@example.com", LoginClient{IP: "203.0.113.3"}))
+	})
+
+	t.Run("Concurrent failures are all counted", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := store.RecordFailure("account:g@example.com", func(int) time.Duration { return time.Minute })
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		record, err := store.Get("account:g@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, 50, record.Failures)
+	})
+
+	t.Run("Failures expire", func(t *testing.T) {
+		_, err := store.RecordFailure("ip:198.51.100.1", func(int) time.Duration { return -time.Second })
+		require.NoError(t, err)
+		record, err := store.Get("ip:198.51.100.1")
+		require.NoError(t, err)
+		assert.Zero(t, record.Failures)
+	})
+
+	failures, err := guard.RecentFailures("A@Example.com", 10)
+	require.NoError(t, err)
+	assert.Len(t, failures, 4)
+	assert.Equal(t, LoginFailureLocked, failures[0].Reason, "newest first")
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer, err := NewMailer(config.MailConfig{Driver: "file", Dir: dir, From: "Foodie <no-reply@foodie.local>"})
+	require.NoError(t, err)
+
+	require.NoError(t, mailer.Send("jane@example.com", "Hello", "line one\nline two"))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "To: jane@example.com\r\n")
+	assert.Contains(t, string(data), "Subject: Hello\r\n")
+	assert.Contains(t, string(data), "line one\r\nline two")
+}
+
+func TestNewMailerConfig(t *testing.T) {
+	_, err := NewMailer(config.MailConfig{Driver: "smtp"})
+	assert.Error(t, err, "smtp needs a host")
+
+	_, err = NewMailer(config.MailConfig{Driver: "pigeon"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+// invitationTTL is how long an invitation token can be accepted
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidMemberRole       = errors.New("members can only be invited as manager or staff")
+	ErrAlreadyMember           = errors.New("user is already a member of this restaurant")
+	ErrInvitationNotFound      = errors.New("invitation not found or already used")
+	ErrInvitationExpired       = errors.New("invitation has expired")
+	ErrInvitationEmailMismatch = errors.New("invitation was sent to a different email address")
+	ErrManagerStaffOnly        = errors.New("managers can only invite and remove staff")
+)
+
+type MembershipService interface {
+	// InviteMember creates an invitation and returns it with the token to hand to the invitee.
+	// Owners and admins can invite managers and staff, managers only staff.
+	InviteMember(restaurantID uint, inviter *models.User, email string, role string) (*models.RestaurantInvitation, string, error)
+	AcceptInvitation(user *models.User, token string) (*models.RestaurantMember, error)
+	GetMembers(restaurantID uint) ([]models.RestaurantMember, error)
+	GetPendingInvitations(restaurantID uint) ([]models.RestaurantInvitation, error)
+	GetUserMemberships(userID uint) ([]models.RestaurantMember, error)
+	// RemoveMember deletes a membership. Managers can only remove staff.
+	RemoveMember(restaurantID uint, remover *models.User, memberID uint) error
+}
+
+type membershipService struct {
+	repo           repositories.MembershipRepository
+	userRepo       repositories.UserRepository
+	restaurantRepo repositories.RestaurantRepository
+	notifier       Notifier
+	linkBaseURL    string // frontend URL the accept link in invitations points to
+}
+
+func NewMembershipService(
+	repo repositories.MembershipRepository,
+	userRepo repositories.UserRepository,
+	restaurantRepo repositories.RestaurantRepository,
+	notifier Notifier,
+	linkBaseURL string,
+) MembershipService {
+	return &membershipService{repo: repo, userRepo: userRepo, restaurantRepo: restaurantRepo, notifier: notifier, linkBaseURL: linkBaseURL}
+}
+
+func (s *membershipService) InviteMember(restaurantID uint, inviter *models.User, email string, role string) (*models.RestaurantInvitation, string, error) {
+	if role != models.MemberRoleManager && role != models.MemberRoleStaff {
+		return nil, "", ErrInvalidMemberRole
+	}
+	restaurant, err := s.restaurantRepo.FindByID(restaurantID)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := canManageMemberRole(restaurant, inviter, role); err != nil {
+		return nil, "", err
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	invitee, err := s.userRepo.GetUserByEmail(email)
+	if err == nil {
+		member, err := s.repo.IsMember(restaurantID, invitee.ID)
+		if err != nil {
+			return nil, "", err
+		}
+		if member {
+			return nil, "", ErrAlreadyMember
+		}
+	}
+
+	token, err := newRandomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	invitation := &models.RestaurantInvitation{
+		RestaurantID: restaurantID,
+		Email:        email,
+		Role:         role,
+		TokenHash:    hashToken(token),
+		InvitedByID:  inviter.ID,
+		ExpiresAt:    time.Now().Add(invitationTTL),
+	}
+	if err := s.repo.CreateInvitation(invitation); err != nil {
+		return nil, "", err
+	}
+
+	if invitee != nil && invitee.ID != 0 {
+		link := strings.TrimRight(s.linkBaseURL, "/") + "/invitations/accept?token=" + url.QueryEscape(token)
+		message := fmt.Sprintf("You have been invited to join %s as %s. Accept the invitation at %s", restaurant.Name, role, link)
+		if err := s.notifier.Notify(invitee.ID, "Restaurant invitation", message); err != nil {
+			slog.Error("Failed to notify invitee", "invitation_id", invitation.ID, "error", err.Error())
+		}
+	}
+	return invitation, token, nil
+}
+
+func (s *membershipService) AcceptInvitation(user *models.User, token string) (*models.RestaurantMember, error) {
+	invitation, err := s.repo.FindInvitationByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, ErrInvitationNotFound
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvitationExpired
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		return nil, ErrInvitationEmailMismatch
+	}
+
+	member, err := s.repo.IsMember(invitation.RestaurantID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return nil, ErrAlreadyMember
+	}
+
+	membership := &models.RestaurantMember{
+		RestaurantID: invitation.RestaurantID,
+		UserID:       user.ID,
+		Role:         invitation.Role,
+	}
+	if err := s.repo.AcceptInvitation(invitation, membership); err != nil {
+		return nil, err
+	}
+	membership.Restaurant = invitation.Restaurant
+	return membership, nil
+}
+
+func (s *membershipService) GetMembers(restaurantID uint) ([]models.RestaurantMember, error) {
+	return s.repo.FindMembers(restaurantID)
+}
+
+func (s *membershipService) GetPendingInvitations(restaurantID uint) ([]models.RestaurantInvitation, error) {
+	return s.repo.FindPendingInvitations(restaurantID)
+}
+
+func (s *membershipService) GetUserMemberships(userID uint) ([]models.RestaurantMember, error) {
+	return s.repo.FindByUser(userID)
+}
+
+func (s *membershipService) RemoveMember(restaurantID uint, remover *models.User, memberID uint) error {
+	restaurant, err := s.restaurantRepo.FindByID(restaurantID)
+	if err != nil {
+		return err
+	}
+	member, err := s.repo.FindMember(restaurantID, memberID)
+	if err != nil {
+		return err
+	}
+	if err := canManageMemberRole(restaurant, remover, member.Role); err != nil {
+		return err
+	}
+	return s.repo.DeleteMember(restaurantID, memberID)
+}
+
+// canManageMemberRole checks that actor may invite or remove a member with the
+// given role: admins and owners any, managers only staff
+func canManageMemberRole(restaurant *models.Restaurant, actor *models.User, role string) error {
+	if actor.Role == models.RoleAdmin {
+		return nil
+	}
+	switch policy.RestaurantRole(actor, restaurant.ID, restaurant.UserID) {
+	case models.MemberRoleOwner:
+		return nil
+	case models.MemberRoleManager:
+		if role == models.MemberRoleStaff {
+			return nil
+		}
+		return ErrManagerStaffOnly
+	}
+	return policy.ErrForbidden
+}
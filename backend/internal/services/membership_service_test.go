@@ -0,0 +1,140 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+func TestMembershipInvitation(t *testing.T) {
+	db := newTestDB(t)
+	notifier := &recordingNotifier{}
+	membershipRepo := repositories.NewMembershipRepository(db)
+	service := NewMembershipService(membershipRepo, repositories.NewUserRepository(db), repositories.NewRestaurantRepository(db), notifier, "http://localhost:3000")
+	orderRepo := repositories.NewOrderRepository(db)
+
+	owner := models.User{Name: "Owner", Email: "owner@example.com", Password: "x", Phone: "1", Role: models.RoleRestaurantOwner}
+	cook := models.User{Name: "Cook", Email: "cook@example.com", Password: "x", Phone: "2", Role: models.RoleCustomer}
+	stranger := models.User{Name: "Stranger", Email: "stranger@example.com", Password: "x", Phone: "3", Role: models.RoleCustomer}
+	db.Create(&owner)
+	db.Create(&cook)
+	db.Create(&stranger)
+	restaurant := models.Restaurant{Name: "Pizza Place", Address: "Main St", Phone: "123", Email: "pizza@example.com", UserID: &owner.ID}
+	other := models.Restaurant{Name: "Other", Address: "Side St", Phone: "456", Email: "other@example.com"}
+	db.Create(&restaurant)
+	db.Create(&other)
+	db.Create(&models.Order{UserID: stranger.ID, RestaurantID: restaurant.ID, Status: models.OrderStatusPending})
+	db.Create(&models.Order{UserID: stranger.ID, RestaurantID: other.ID, Status: models.OrderStatusPending})
+
+	_, _, err := service.InviteMember(restaurant.ID, &owner, "cook@example.com", models.MemberRoleOwner)
+	assert.ErrorIs(t, err, ErrInvalidMemberRole)
+
+	invitation, token, err := service.InviteMember(restaurant.ID, &owner, " Cook@Example.com ", models.MemberRoleStaff)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotEqual(t, token, invitation.TokenHash)
+	assert.Equal(t, "cook@example.com", invitation.Email)
+	assert.Equal(t, []uint{cook.ID}, notifier.userIDs)
+	assert.Contains(t, notifier.messages[0], "http://localhost:3000/invitations/accept?token="+token)
+
+	t.Run("Wrong user", func(t *testing.T) {
+		_, err := service.AcceptInvitation(&stranger, token)
+		assert.ErrorIs(t, err, ErrInvitationEmailMismatch)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		_, err := service.AcceptInvitation(&cook, "nope")
+		assert.ErrorIs(t, err, ErrInvitationNotFound)
+	})
+
+	t.Run("Accept", func(t *testing.T) {
+		member, err := service.AcceptInvitation(&cook, token)
+		require.NoError(t, err)
+		assert.Equal(t, restaurant.ID, member.RestaurantID)
+		assert.Equal(t, models.MemberRoleStaff, member.Role)
+
+		var stored models.User
+		db.First(&stored, cook.ID)
+		assert.Equal(t, models.RoleCustomer, stored.Role)
+
+		// tokens are single use
+		_, err = service.AcceptInvitation(&cook, token)
+		assert.ErrorIs(t, err, ErrInvitationNotFound)
+
+		_, _, err = service.InviteMember(restaurant.ID, &owner, "cook@example.com", models.MemberRoleManager)
+		assert.ErrorIs(t, err, ErrAlreadyMember)
+	})
+
+	t.Run("Staff only see their restaurant's orders", func(t *testing.T) {
+		orders, total, err := orderRepo.FindManagedBy(cook.ID, repositories.OrderFilter{Page: 1, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		require.Len(t, orders, 1)
+		assert.Equal(t, restaurant.ID, orders[0].RestaurantID)
+	})
+
+	t.Run("Expired invitation", func(t *testing.T) {
+		invitation, token, err := service.InviteMember(restaurant.ID, &owner, "stranger@example.com", models.MemberRoleStaff)
+		require.NoError(t, err)
+		db.Model(invitation).Update("expires_at", time.Now().Add(-time.Hour))
+
+		_, err = service.AcceptInvitation(&stranger, token)
+		assert.ErrorIs(t, err, ErrInvitationExpired)
+	})
+
+	t.Run("Remove member", func(t *testing.T) {
+		members, err := service.GetMembers(restaurant.ID)
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+
+		assert.Error(t, service.RemoveMember(other.ID, &owner, members[0].ID))
+		assert.NoError(t, service.RemoveMember(restaurant.ID, &owner, members[0].ID))
+
+		orders, _, err := orderRepo.FindManagedBy(cook.ID, repositories.OrderFilter{Page: 1, Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, orders)
+	})
+}
+
+func TestManagerManagesStaffOnly(t *testing.T) {
+	db := newTestDB(t)
+	membershipRepo := repositories.NewMembershipRepository(db)
+	service := NewMembershipService(membershipRepo, repositories.NewUserRepository(db), repositories.NewRestaurantRepository(db), &recordingNotifier{}, "http://localhost:3000")
+
+	owner := models.User{Name: "Owner", Email: "owner@example.com", Password: "x", Phone: "1", Role: models.RoleRestaurantOwner}
+	manager := models.User{Name: "Manager", Email: "manager@example.com", Password: "x", Phone: "2", Role: models.RoleCustomer}
+	cook := models.User{Name: "Cook", Email: "cook@example.com", Password: "x", Phone: "3", Role: models.RoleCustomer}
+	deputy := models.User{Name: "Deputy", Email: "deputy@example.com", Password: "x", Phone: "4", Role: models.RoleCustomer}
+	db.Create(&owner)
+	db.Create(&manager)
+	db.Create(&cook)
+	db.Create(&deputy)
+	restaurant := models.Restaurant{Name: "Pizza Place", Address: "Main St", Phone: "123", Email: "pizza@example.com", UserID: &owner.ID}
+	db.Create(&restaurant)
+
+	managerMember := models.RestaurantMember{RestaurantID: restaurant.ID, UserID: manager.ID, Role: models.MemberRoleManager}
+	cookMember := models.RestaurantMember{RestaurantID: restaurant.ID, UserID: cook.ID, Role: models.MemberRoleStaff}
+	deputyMember := models.RestaurantMember{RestaurantID: restaurant.ID, UserID: deputy.ID, Role: models.MemberRoleManager}
+	db.Create(&managerMember)
+	db.Create(&cookMember)
+	db.Create(&deputyMember)
+	manager.Memberships = []models.RestaurantMember{managerMember}
+
+	_, _, err := service.InviteMember(restaurant.ID, &manager, "waiter@example.com", models.MemberRoleStaff)
+	assert.NoError(t, err)
+	_, _, err = service.InviteMember(restaurant.ID, &manager, "boss@example.com", models.MemberRoleManager)
+	assert.ErrorIs(t, err, ErrManagerStaffOnly)
+
+	assert.ErrorIs(t, service.RemoveMember(restaurant.ID, &manager, deputyMember.ID), ErrManagerStaffOnly)
+	assert.NoError(t, service.RemoveMember(restaurant.ID, &manager, cookMember.ID))
+	assert.NoError(t, service.RemoveMember(restaurant.ID, &owner, deputyMember.ID))
+
+	_, _, err = service.InviteMember(restaurant.ID, &cook, "friend@example.com", models.MemberRoleStaff)
+	assert.ErrorIs(t, err, policy.ErrForbidden)
+}
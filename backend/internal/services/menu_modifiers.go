@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+)
+
+var (
+	ErrInvalidModifiers = errors.New("invalid modifier groups")
+	ErrInvalidOptions   = errors.New("invalid options")
+)
+
+// ModifierGroupInput is a modifier group of a menu item as owners set it. Groups and
+// options keep their identity when sent back with their ID.
+type ModifierGroupInput struct {
+	ID            uint                  `json:"id"`
+	Name          string                `json:"name" binding:"required,max=100"`
+	Required      bool                  `json:"required"`
+	MinSelections int                   `json:"min_selections" binding:"min=0"`
+	MaxSelections int                   `json:"max_selections" binding:"min=0"` // 0 for no limit
+	Options       []ModifierOptionInput `json:"options" binding:"required,min=1,dive"`
+}
+
+type ModifierOptionInput struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name" binding:"required,max=100"`
+	PriceDelta  float64 `json:"price_delta" binding:"min=0"`
+	IsAvailable *bool   `json:"is_available"` // defaults to true
+}
+
+// modifierGroups checks the groups of a menu item and turns them into models in the
+// order they were given
+func modifierGroups(inputs []ModifierGroupInput) ([]models.ModifierGroup, error) {
+	groups := make([]models.ModifierGroup, 0, len(inputs))
+	for i, input := range inputs {
+		group := models.ModifierGroup{
+			Name:          strings.TrimSpace(input.Name),
+			Required:      input.Required,
+			MinSelections: input.MinSelections,
+			MaxSelections: input.MaxSelections,
+			Position:      i,
+		}
+		group.ID = input.ID
+		switch {
+		case group.Name == "":
+			return nil, fmt.Errorf("%w: every group needs a name", ErrInvalidModifiers)
+		case len(input.Options) == 0:
+			return nil, fmt.Errorf("%w: %s has no options", ErrInvalidModifiers, group.Name)
+		case group.MaxSelections > 0 && group.MaxSelections < group.MinRequired():
+			return nil, fmt.Errorf("%w: %s allows fewer selections than it requires", ErrInvalidModifiers, group.Name)
+		case group.MinRequired() > len(input.Options):
+			return nil, fmt.Errorf("%w: %s requires more selections than it has options", ErrInvalidModifiers, group.Name)
+		}
+
+		for j, optionInput := range input.Options {
+			option := models.ModifierOption{
+				Name:        strings.TrimSpace(optionInput.Name),
+				PriceDelta:  roundMoney(optionInput.PriceDelta),
+				IsAvailable: optionInput.IsAvailable == nil || *optionInput.IsAvailable,
+				Position:    j,
+			}
+			option.ID = optionInput.ID
+			if option.Name == "" {
+				return nil, fmt.Errorf("%w: every option of %s needs a name", ErrInvalidModifiers, group.Name)
+			}
+			group.Options = append(group.Options, option)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// selectOptions checks that optionIDs are a valid choice of options for menuItem, which
+// must have its modifier groups loaded, and returns the selected options
+func selectOptions(menuItem *models.MenuItem, optionIDs []uint) ([]models.ModifierOption, error) {
+	selected := make(map[uint]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		if selected[id] {
+			return nil, fmt.Errorf("%w: option %d is selected more than once", ErrInvalidOptions, id)
+		}
+		selected[id] = true
+	}
+
+	var options []models.ModifierOption
+	for _, group := range menuItem.ModifierGroups {
+		count := 0
+		for _, option := range group.Options {
+			if !selected[option.ID] {
+				continue
+			}
+			if !option.IsAvailable {
+				return nil, fmt.Errorf("%w: %s is currently unavailable", ErrInvalidOptions, option.Name)
+			}
+			delete(selected, option.ID)
+			options = append(options, option)
+			count++
+		}
+		if count < group.MinRequired() {
+			return nil, fmt.Errorf("%w: choose at least %d from %s", ErrInvalidOptions, group.MinRequired(), group.Name)
+		}
+		if group.MaxSelections > 0 && count > group.MaxSelections {
+			return nil, fmt.Errorf("%w: choose at most %d from %s", ErrInvalidOptions, group.MaxSelections, group.Name)
+		}
+	}
+	for id := range selected {
+		return nil, fmt.Errorf("%w: option %d is not offered for %s", ErrInvalidOptions, id, menuItem.Name)
+	}
+	return options, nil
+}
+
+// optionsKey identifies a choice of options regardless of the order they were picked in
+func optionsKey(options []models.ModifierOption) string {
+	ids := make([]int, 0, len(options))
+	for _, option := range options {
+		ids = append(ids, int(option.ID))
+	}
+	slices.Sort(ids)
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, strconv.Itoa(id))
+	}
+	return strings.Join(keys, ",")
+}
+
+// findOption returns an option of menuItem, which must have its modifier groups loaded,
+// together with its group
+func findOption(menuItem *models.MenuItem, optionID uint) (*models.ModifierGroup, *models.ModifierOption, bool) {
+	for i := range menuItem.ModifierGroups {
+		group := &menuItem.ModifierGroups[i]
+		for j := range group.Options {
+			if group.Options[j].ID == optionID {
+				return group, &group.Options[j], true
+			}
+		}
+	}
+	return nil, nil, false
+}
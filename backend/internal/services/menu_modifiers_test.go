@@ -0,0 +1,189 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+// createPizzaWithModifiers adds a 10.00 pizza with a required size and up to two toppings
+func createPizzaWithModifiers(t *testing.T, db *gorm.DB, menu MenuService, restaurantID uint) *models.MenuItem {
+	pizza := models.MenuItem{Name: "Pizza", Price: 10, Category: "Main", RestaurantID: restaurantID}
+	require.NoError(t, db.Create(&pizza).Error)
+
+	item, err := menu.SetModifierGroups(pizza.ID, []ModifierGroupInput{
+		{Name: "Size", Required: true, MaxSelections: 1, Options: []ModifierOptionInput{
+			{Name: "Regular"},
+			{Name: "Large", PriceDelta: 3},
+		}},
+		{Name: "Toppings", MaxSelections: 2, Options: []ModifierOptionInput{
+			{Name: "Extra cheese", PriceDelta: 1.5},
+			{Name: "Olives", PriceDelta: 1},
+			{Name: "Ham", PriceDelta: 2},
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, item.ModifierGroups, 2)
+	return item
+}
+
+func optionID(t *testing.T, item *models.MenuItem, name string) uint {
+	for _, group := range item.ModifierGroups {
+		for _, option := range group.Options {
+			if option.Name == name {
+				return option.ID
+			}
+		}
+	}
+	t.Fatalf("%s has no option %s", item.Name, name)
+	return 0
+}
+
+func TestSetModifierGroups(t *testing.T) {
+	db := newTestDB(t)
+	menu := NewMenuService(repositories.NewMenuRepository(db))
+	pizza := createPizzaWithModifiers(t, db, menu, 1)
+	size := pizza.ModifierGroups[0]
+	large := size.Options[1]
+
+	t.Run("Invalid groups", func(t *testing.T) {
+		_, err := menu.SetModifierGroups(pizza.ID, []ModifierGroupInput{
+			{Name: "Sauce", MinSelections: 2, MaxSelections: 1, Options: []ModifierOptionInput{{Name: "Tomato"}, {Name: "Cream"}}},
+		})
+		assert.ErrorIs(t, err, ErrInvalidModifiers)
+
+		_, err = menu.SetModifierGroups(pizza.ID, []ModifierGroupInput{
+			{Name: "Sauce", MinSelections: 3, Options: []ModifierOptionInput{{Name: "Tomato"}, {Name: "Cream"}}},
+		})
+		assert.ErrorIs(t, err, ErrInvalidModifiers)
+	})
+
+	unavailable := false
+	item, err := menu.SetModifierGroups(pizza.ID, []ModifierGroupInput{
+		{ID: size.ID, Name: "Size", Required: true, MaxSelections: 1, Options: []ModifierOptionInput{
+			{ID: large.ID, Name: "Large", PriceDelta: 4},
+			{Name: "Family", PriceDelta: 8, IsAvailable: &unavailable},
+		}},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, item.ModifierGroups, 1, "groups left out are removed")
+	group := item.ModifierGroups[0]
+	assert.Equal(t, size.ID, group.ID)
+	require.Len(t, group.Options, 2)
+	assert.Equal(t, large.ID, group.Options[0].ID, "options keep their ID and follow the given order")
+	assert.Equal(t, 4.0, group.Options[0].PriceDelta)
+	assert.Equal(t, "Family", group.Options[1].Name)
+	assert.False(t, group.Options[1].IsAvailable)
+
+	var options int64
+	db.Model(&models.ModifierOption{}).Count(&options)
+	assert.Equal(t, int64(2), options)
+}
+
+func TestAddToCartWithOptions(t *testing.T) {
+	_, cartService, db := setupOrderTest(t)
+	menu := NewMenuService(repositories.NewMenuRepository(db))
+
+	pizza := createPizzaWithModifiers(t, db, menu, 1)
+	regular := optionID(t, pizza, "Regular")
+	large := optionID(t, pizza, "Large")
+	cheese := optionID(t, pizza, "Extra cheese")
+	olives := optionID(t, pizza, "Olives")
+	ham := optionID(t, pizza, "Ham")
+	userID := uint(7)
+
+	invalid := map[string][]uint{
+		"required group left out": {cheese},
+		"too many from a group":   {regular, large},
+		"too many toppings":       {regular, cheese, olives, ham},
+		"option of another item":  {regular, 999},
+		"option selected twice":   {regular, cheese, cheese},
+	}
+	for name, optionIDs := range invalid {
+		t.Run(name, func(t *testing.T) {
+			err := cartService.AddToCart(userID, CartItemInput{MenuItemID: pizza.ID, Quantity: 1, OptionIDs: optionIDs}, false)
+			assert.ErrorIs(t, err, ErrInvalidOptions)
+		})
+	}
+
+	add := func(optionIDs []uint, notes string) {
+		require.NoError(t, cartService.AddToCart(userID, CartItemInput{MenuItemID: pizza.ID, Quantity: 1, OptionIDs: optionIDs, Notes: notes}, false))
+	}
+	add([]uint{large, cheese, olives}, "")
+	add([]uint{olives, large, cheese}, "") // same options in another order
+	add([]uint{regular}, "")
+	add([]uint{regular}, "well done")
+
+	cart, err := cartService.GetUserCart(userID)
+	require.NoError(t, err)
+	require.Len(t, cart.Items, 3)
+	assert.Equal(t, 2, cart.Items[0].Quantity)
+	assert.Len(t, cart.Items[0].Options, 3)
+	assert.Equal(t, "well done", cart.Items[2].Notes)
+
+	quote, err := cartService.pricing.QuoteCart(cart.Items)
+	require.NoError(t, err)
+	assert.Equal(t, 15.5, quote.Items[0].Price) // 10 + 3 large + 1.5 cheese + 1 olives
+	assert.Equal(t, 10.0, quote.Items[1].Price)
+	assert.Equal(t, 51.0, quote.Subtotal)
+
+	t.Run("Unavailable option", func(t *testing.T) {
+		db.Model(&models.ModifierOption{}).Where("id = ?", ham).Update("is_available", false)
+		err := cartService.AddToCart(userID, CartItemInput{MenuItemID: pizza.ID, Quantity: 1, OptionIDs: []uint{regular, ham}}, false)
+		assert.ErrorIs(t, err, ErrInvalidOptions)
+	})
+}
+
+func TestCheckoutSnapshotsOptions(t *testing.T) {
+	orderService, cartService, db := setupOrderTest(t)
+	menu := NewMenuService(repositories.NewMenuRepository(db))
+
+	restaurant := models.Restaurant{Name: "Pizza Place", Address: "Main St", Phone: "123", Email: "pizza@example.com"}
+	require.NoError(t, db.Create(&restaurant).Error)
+	pizza := createPizzaWithModifiers(t, db, menu, restaurant.ID)
+	large := optionID(t, pizza, "Large")
+	cheese := optionID(t, pizza, "Extra cheese")
+
+	userID := uint(7)
+	input := CheckoutInput{UserID: userID, DeliveryAddress: "1 Test Rd", PaymentMethod: "cash", ClientTotal: 29}
+	require.NoError(t, cartService.AddToCart(userID, CartItemInput{
+		MenuItemID: pizza.ID, Quantity: 2, OptionIDs: []uint{large, cheese}, Notes: " no basil ",
+	}, false))
+
+	t.Run("Options that cannot be chosen any more block checkout", func(t *testing.T) {
+		db.Model(&models.ModifierOption{}).Where("id = ?", cheese).Update("is_available", false)
+		defer db.Model(&models.ModifierOption{}).Where("id = ?", cheese).Update("is_available", true)
+
+		_, _, err := orderService.Checkout(input)
+		var checkoutErr *CheckoutError
+		require.ErrorAs(t, err, &checkoutErr)
+		assert.Equal(t, CheckoutItemsUnavailable, checkoutErr.Code)
+		require.Len(t, checkoutErr.Issues, 1)
+		assert.Equal(t, pizza.ID, checkoutErr.Issues[0].MenuItemID)
+	})
+
+	order, _, err := orderService.Checkout(input)
+	require.NoError(t, err)
+	assert.Equal(t, 29.0, order.Subtotal) // 2 x (10 + 3 + 1.5)
+
+	// later menu changes must not rewrite the order
+	db.Model(&models.ModifierOption{}).Where("id = ?", large).Updates(map[string]any{"name": "Big", "price_delta": 5})
+
+	stored, err := orderService.GetOrder(order.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Items, 1)
+	item := stored.Items[0]
+	assert.Equal(t, 14.5, item.Price)
+	assert.Equal(t, "no basil", item.Notes)
+	require.Len(t, item.Options, 2)
+	assert.Equal(t, "Size", item.Options[0].GroupName)
+	assert.Equal(t, "Large", item.Options[0].Name)
+	assert.Equal(t, 3.0, item.Options[0].PriceDelta)
+	assert.Equal(t, "Extra cheese", item.Options[1].Name)
+}
@@ -13,6 +13,7 @@ type MenuService interface {
 	DeleteMenuItem(uint) error
 	GetMenuItemsByCategory(uint, string) ([]models.MenuItem, error)
 	GetRestaurantMenuItems(uint) ([]models.MenuItem, error)
+	SetModifierGroups(uint, []ModifierGroupInput) (*models.MenuItem, error)
 }
 
 type menuService struct {
@@ -51,3 +52,16 @@ func (s *menuService) DeleteMenuItem(id uint) error {
 func (s *menuService) GetMenuItemsByCategory(restaurantID uint, category string) ([]models.MenuItem, error) {
 	return s.repo.FindByCategory(restaurantID, category)
 }
+
+// SetModifierGroups replaces the modifier groups of a menu item, returning
+// ErrInvalidModifiers if they cannot be ordered from
+func (s *menuService) SetModifierGroups(menuItemID uint, inputs []ModifierGroupInput) (*models.MenuItem, error) {
+	groups, err := modifierGroups(inputs)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceModifierGroups(menuItemID, groups); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(menuItemID)
+}
@@ -0,0 +1,20 @@
+package services
+
+import "log/slog"
+
+// Notifier delivers short messages to users
+type Notifier interface {
+	Notify(userID uint, subject, message string) error
+}
+
+type logNotifier struct{}
+
+// NewLogNotifier returns a Notifier that only writes notifications to the log
+func NewLogNotifier() Notifier {
+	return &logNotifier{}
+}
+
+func (n *logNotifier) Notify(userID uint, subject, message string) error {
+	slog.Info("Notification", "user_id", userID, "subject", subject, "message", message)
+	return nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/oidc"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// oidcRequestTTL is how long a user has to log in at the provider
+const oidcRequestTTL = 10 * time.Minute
+
+var (
+	ErrUnknownProvider      = errors.New("unknown login provider")
+	ErrInvalidOIDCState     = errors.New("login request is invalid or has expired, please try again")
+	ErrOIDCEmailMissing     = errors.New("the provider did not share an email address")
+	ErrOIDCEmailNotVerified = errors.New("an account with this email exists; verify the email at the provider or log in with your password")
+)
+
+// OIDCLogin is where to send the user to log in at a provider. The state comes back
+// with the redirect and must be checked against the stored one by the client.
+type OIDCLogin struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+type OIDCService interface {
+	// Providers lists the names of the configured providers
+	Providers() []string
+	BeginLogin(ctx context.Context, provider string) (*OIDCLogin, error)
+	// CompleteLogin exchanges the code from the provider's redirect and logs the user in,
+	// linking or creating the account. It returns the same errors as UserService.LoginUser.
+	CompleteLogin(ctx context.Context, provider, state, code string) (string, string, error)
+}
+
+type oidcService struct {
+	providers    map[string]*oidc.Provider
+	identityRepo repositories.IdentityRepository
+	userRepo     repositories.UserRepository
+	userService  UserService
+	hasher       PasswordHasher
+}
+
+func NewOIDCService(
+	providers []*oidc.Provider,
+	identityRepo repositories.IdentityRepository,
+	userRepo repositories.UserRepository,
+	userService UserService,
+	hasher PasswordHasher,
+) OIDCService {
+	byName := map[string]*oidc.Provider{}
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return &oidcService{providers: byName, identityRepo: identityRepo, userRepo: userRepo, userService: userService, hasher: hasher}
+}
+
+// NewOIDCProviders creates the providers from configuration
+func NewOIDCProviders(cfgs []config.OIDCProviderConfig) []*oidc.Provider {
+	providers := make([]*oidc.Provider, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:         cfg.Name,
+			Issuer:       cfg.Issuer,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		}, nil))
+	}
+	return providers
+}
+
+func (s *oidcService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *oidcService) BeginLogin(ctx context.Context, providerName string) (*OIDCLogin, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	request := &models.OIDCAuthRequest{Provider: providerName, ExpiresAt: time.Now().Add(oidcRequestTTL)}
+	var err error
+	if request.State, err = oidc.NewNonce(); err != nil {
+		return nil, err
+	}
+	if request.Nonce, err = oidc.NewNonce(); err != nil {
+		return nil, err
+	}
+	if request.CodeVerifier, err = oidc.NewVerifier(); err != nil {
+		return nil, err
+	}
+
+	url, err := provider.AuthCodeURL(ctx, request.State, request.Nonce, request.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.identityRepo.CreateAuthRequest(request); err != nil {
+		return nil, err
+	}
+	return &OIDCLogin{URL: url, State: request.State}, nil
+}
+
+func (s *oidcService) CompleteLogin(ctx context.Context, providerName, state, code string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	request, err := s.identityRepo.ConsumeAuthRequest(state)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", "", ErrInvalidOIDCState
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if request.Provider != providerName || time.Now().After(request.ExpiresAt) {
+		return "", "", ErrInvalidOIDCState
+	}
+
+	claims, err := provider.Exchange(ctx, code, request.CodeVerifier, request.Nonce)
+	if err != nil {
+		return "", "", err
+	}
+
+	user, err := s.findOrCreateUser(providerName, claims)
+	if err != nil {
+		return "", "", err
+	}
+	return s.userService.LoginAuthenticatedUser(user)
+}
+
+// findOrCreateUser returns the user linked to the identity. Unknown identities are
+// linked to the account with the same email, but only if the provider verified that
+// email; otherwise anyone could register it at a provider and take the account over.
+func (s *oidcService) findOrCreateUser(providerName string, claims *oidc.Claims) (*models.User, error) {
+	identity, err := s.identityRepo.FindIdentity(providerName, claims.Subject)
+	if err == nil {
+		return s.userRepo.GetUserById(identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" {
+		return nil, ErrOIDCEmailMissing
+	}
+	identity = &models.UserIdentity{Provider: providerName, Subject: claims.Subject, Email: email}
+
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err == nil {
+		if !claims.EmailVerified {
+			return nil, ErrOIDCEmailNotVerified
+		}
+		identity.UserID = user.ID
+		if err := s.identityRepo.CreateIdentity(identity); err != nil {
+			return nil, err
+		}
+		if !user.IsEmailVerified {
+			if err := s.userRepo.UpdateUser(user.ID, map[string]interface{}{"is_email_verified": true}); err != nil {
+				return nil, err
+			}
+			user.IsEmailVerified = true
+		}
+		slog.Info("Linked external identity", "user_id", user.ID, "provider", providerName)
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// New account. It has no usable password until the user sets one with a reset.
+	password, err := newRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name = strings.Split(email, "@")[0]
+	}
+	user = &models.User{
+		Name:            name,
+		Email:           email,
+		Password:        hashedPassword,
+		Role:            models.RoleCustomer,
+		IsActive:        true,
+		IsEmailVerified: claims.EmailVerified,
+	}
+	if err := s.identityRepo.CreateUserWithIdentity(user, identity); err != nil {
+		return nil, err
+	}
+	slog.Info("Registered user from external identity", "user_id", user.ID, "provider", providerName)
+	return user, nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/oidc"
+	"github.com/manjurulhoque/foodie/backend/internal/oidc/oidctest"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+func setupOIDCTest(t *testing.T) (OIDCService, *oidctest.Provider, UserService, *gorm.DB) {
+	userService, db := setupUserTest(t)
+	fake := oidctest.NewProvider(t)
+	service := NewOIDCService(
+		[]*oidc.Provider{oidc.NewProvider(fake.Config("fake"), nil)},
+		repositories.NewIdentityRepository(db),
+		repositories.NewUserRepository(db),
+		userService,
+		testHasher,
+	)
+	return service, fake, userService, db
+}
+
+// oidcLogin runs a full login at the fake provider as user
+func oidcLogin(t *testing.T, service OIDCService, fake *oidctest.Provider, user oidctest.User) (string, string, error) {
+	ctx := context.Background()
+	login, err := service.BeginLogin(ctx, "fake")
+	require.NoError(t, err)
+	code, state := fake.Authorize(t, login.URL, user)
+	require.Equal(t, login.State, state)
+	return service.CompleteLogin(ctx, "fake", state, code)
+}
+
+func TestOIDCRegistersNewUser(t *testing.T) {
+	service, fake, userService, db := setupOIDCTest(t)
+
+	access, _, err := oidcLogin(t, service, fake, oidctest.User{Subject: "sub-1", Email: "New@Example.com", EmailVerified: true, Name: "New User"})
+	require.NoError(t, err)
+	claims, err := userService.VerifyAccessToken(access)
+	require.NoError(t, err)
+
+	var user models.User
+	require.NoError(t, db.First(&user, claims.Id).Error)
+	assert.Equal(t, "new@example.com", user.Email)
+	assert.Equal(t, "New User", user.Name)
+	assert.Equal(t, models.RoleCustomer, user.Role)
+	assert.True(t, user.IsEmailVerified)
+
+	// logging in again uses the linked identity, not a new account
+	access, _, err = oidcLogin(t, service, fake, oidctest.User{Subject: "sub-1", Email: "changed@example.com"})
+	require.NoError(t, err)
+	claims, err = userService.VerifyAccessToken(access)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, claims.Id)
+
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestOIDCLinksVerifiedEmail(t *testing.T) {
+	service, fake, userService, db := setupOIDCTest(t)
+	var jane models.User
+	db.Where("email = ?", "jane@example.com").First(&jane)
+
+	_, _, err := oidcLogin(t, service, fake, oidctest.User{Subject: "sub-1", Email: "jane@example.com", EmailVerified: false})
+	assert.ErrorIs(t, err, ErrOIDCEmailNotVerified)
+
+	access, _, err := oidcLogin(t, service, fake, oidctest.User{Subject: "sub-1", Email: "jane@example.com", EmailVerified: true})
+	require.NoError(t, err)
+	claims, err := userService.VerifyAccessToken(access)
+	require.NoError(t, err)
+	assert.Equal(t, jane.ID, claims.Id)
+
+	var identity models.UserIdentity
+	require.NoError(t, db.Where("user_id = ?", jane.ID).First(&identity).Error)
+	assert.Equal(t, "fake", identity.Provider)
+	assert.Equal(t, "sub-1", identity.Subject)
+
+	// the password still works
+	_, _, err = userService.LoginUser("jane@example.com", "secret123", testClient)
+	assert.NoError(t, err)
+}
+
+func TestOIDCState(t *testing.T) {
+	service, fake, _, _ := setupOIDCTest(t)
+	ctx := context.Background()
+	user := oidctest.User{Subject: "sub-1", Email: "new@example.com", EmailVerified: true}
+
+	_, err := service.BeginLogin(ctx, "other")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+
+	login, err := service.BeginLogin(ctx, "fake")
+	require.NoError(t, err)
+	code, state := fake.Authorize(t, login.URL, user)
+
+	_, _, err = service.CompleteLogin(ctx, "fake", "forged", code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+
+	_, _, err = service.CompleteLogin(ctx, "fake", state, code)
+	require.NoError(t, err)
+
+	// a state can only be used once
+	_, _, err = service.CompleteLogin(ctx, "fake", state, code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+}
+
+func TestOIDCRespectsAccountState(t *testing.T) {
+	service, fake, userService, db := setupOIDCTest(t)
+	var jane models.User
+	db.Where("email = ?", "jane@example.com").First(&jane)
+	user := oidctest.User{Subject: "sub-1", Email: "jane@example.com", EmailVerified: true}
+
+	require.NoError(t, userService.SetUserActive(jane.ID, false))
+	_, _, err := oidcLogin(t, service, fake, user)
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+
+	require.NoError(t, userService.SetUserActive(jane.ID, true))
+	require.NoError(t, db.Model(&jane).Update("two_factor_enabled", true).Error)
+	_, _, err = oidcLogin(t, service, fake, user)
+	var twoFactorErr *TwoFactorRequiredError
+	assert.True(t, errors.As(err, &twoFactorErr), "two-factor authentication still applies")
+}
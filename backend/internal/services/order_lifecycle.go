@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
+)
+
+var (
+	ErrInvalidTransition   = errors.New("order cannot move to the requested status")
+	ErrTransitionForbidden = errors.New("you are not allowed to make this status change")
+	ErrReasonRequired      = errors.New("a reason is required for this status change")
+	ErrCancellationClosed  = errors.New("the cancellation window for this order has passed")
+)
+
+// TransitionRequest describes a status change requested by a user
+type TransitionRequest struct {
+	Actor  *models.User
+	To     string
+	Reason string
+}
+
+// OrderTransition is one allowed edge of the order lifecycle
+type OrderTransition struct {
+	From  string
+	To    string
+	Roles []string
+	// Guards run after the role check and can veto the transition
+	Guards []TransitionGuard
+}
+
+// TransitionGuard vetoes a transition by returning an error
+type TransitionGuard func(order *models.Order, req TransitionRequest) error
+
+var restaurantRoles = []string{models.RoleRestaurantOwner, models.RoleRestaurantStaff, models.RoleAdmin}
+
+// orderTransitions is the complete order lifecycle; anything not listed is rejected
+var orderTransitions = []OrderTransition{
+	{From: models.OrderStatusPending, To: models.OrderStatusAccepted, Roles: restaurantRoles},
+	{From: models.OrderStatusPending, To: models.OrderStatusRejected, Roles: restaurantRoles, Guards: []TransitionGuard{requireReason}},
+	{From: models.OrderStatusPending, To: models.OrderStatusCancelled, Roles: []string{models.RoleCustomer, models.RoleAdmin}, Guards: []TransitionGuard{requireReason}},
+	{From: models.OrderStatusAccepted, To: models.OrderStatusPreparing, Roles: restaurantRoles},
+	{From: models.OrderStatusAccepted, To: models.OrderStatusCancelled, Roles: []string{models.RoleCustomer, models.RoleRestaurantOwner, models.RoleAdmin}, Guards: []TransitionGuard{requireReason, withinCancellationWindow}},
+	{From: models.OrderStatusPreparing, To: models.OrderStatusReady, Roles: restaurantRoles},
+	{From: models.OrderStatusPreparing, To: models.OrderStatusCancelled, Roles: []string{models.RoleAdmin}, Guards: []TransitionGuard{requireReason}},
+	{From: models.OrderStatusReady, To: models.OrderStatusOutForDelivery, Roles: restaurantRoles, Guards: []TransitionGuard{requireDeliveryAddress}},
+	{From: models.OrderStatusOutForDelivery, To: models.OrderStatusDelivered, Roles: restaurantRoles},
+}
+
+// findTransition returns the lifecycle edge between two statuses, if any
+func findTransition(from, to string) (*OrderTransition, bool) {
+	for i := range orderTransitions {
+		if orderTransitions[i].From == from && orderTransitions[i].To == to {
+			return &orderTransitions[i], true
+		}
+	}
+	return nil, false
+}
+
+// CheckTransition validates a requested status change against the lifecycle,
+// the roles the actor holds towards the order and the transition's guards
+func CheckTransition(order *models.Order, req TransitionRequest) error {
+	transition, ok := findTransition(order.Status, req.To)
+	if !ok {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, order.Status, req.To)
+	}
+	if req.Actor == nil || !slices.ContainsFunc(orderRoles(order, req.Actor), func(role string) bool {
+		return slices.Contains(transition.Roles, role)
+	}) {
+		return ErrTransitionForbidden
+	}
+	for _, guard := range transition.Guards {
+		if err := guard(order, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func requireReason(_ *models.Order, req TransitionRequest) error {
+	if req.Reason == "" {
+		return ErrReasonRequired
+	}
+	return nil
+}
+
+// orderRoles lists the roles actor holds towards order: admin, the owner or staff
+// of its restaurant through ownership or a membership, and customer if they placed
+// it. A user's global role alone does not make them staff of any restaurant.
+func orderRoles(order *models.Order, actor *models.User) []string {
+	var roles []string
+	if actor.Role == models.RoleAdmin {
+		roles = append(roles, models.RoleAdmin)
+	}
+	switch policy.RestaurantRole(actor, order.RestaurantID, order.Restaurant.UserID) {
+	case models.MemberRoleOwner:
+		roles = append(roles, models.RoleRestaurantOwner)
+	case models.MemberRoleManager, models.MemberRoleStaff:
+		roles = append(roles, models.RoleRestaurantStaff)
+	}
+	if actor.ID == order.UserID {
+		roles = append(roles, models.RoleCustomer)
+	}
+	return roles
+}
+
+// withinCancellationWindow lets customers cancel an accepted order only within the
+// restaurant's cancellation window; admins and the restaurant's owner are not
+// limited by it
+func withinCancellationWindow(order *models.Order, req TransitionRequest) error {
+	roles := orderRoles(order, req.Actor)
+	if slices.Contains(roles, models.RoleAdmin) || slices.Contains(roles, models.RoleRestaurantOwner) {
+		return nil
+	}
+	window := time.Duration(order.Restaurant.CancellationWindowMinutes) * time.Minute
+	if time.Since(order.CreatedAt) > window {
+		return ErrCancellationClosed
+	}
+	return nil
+}
+
+func requireDeliveryAddress(order *models.Order, _ TransitionRequest) error {
+	if order.DeliveryAddress == "" {
+		return fmt.Errorf("%w: order has no delivery address", ErrInvalidTransition)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+)
+
+func TestCheckTransition(t *testing.T) {
+	customer := &models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleCustomer}
+	otherCustomer := &models.User{BaseModel: models.BaseModel{ID: 2}, Role: models.RoleCustomer}
+	owner := &models.User{BaseModel: models.BaseModel{ID: 3}, Role: models.RoleRestaurantOwner}
+	otherOwner := &models.User{BaseModel: models.BaseModel{ID: 6}, Role: models.RoleRestaurantOwner}
+	staff := &models.User{BaseModel: models.BaseModel{ID: 4}, Role: models.RoleRestaurantStaff}
+	admin := &models.User{BaseModel: models.BaseModel{ID: 5}, Role: models.RoleAdmin}
+	member := &models.User{BaseModel: models.BaseModel{ID: 7}, Role: models.RoleRestaurantStaff}
+	member.Memberships = []models.RestaurantMember{{RestaurantID: 1, UserID: member.ID, Role: models.MemberRoleStaff}}
+	customerMember := &models.User{BaseModel: models.BaseModel{ID: 8}, Role: models.RoleCustomer}
+	customerMember.Memberships = []models.RestaurantMember{{RestaurantID: 1, UserID: customerMember.ID, Role: models.MemberRoleManager}}
+
+	tests := []struct {
+		name    string
+		from    string
+		window  int           // restaurant cancellation window in minutes
+		age     time.Duration // how long ago the order was placed
+		req     TransitionRequest
+		wantErr error
+	}{
+		{name: "Owner accepts pending order", from: models.OrderStatusPending, req: TransitionRequest{Actor: owner, To: models.OrderStatusAccepted}},
+		{name: "Admin prepares accepted order", from: models.OrderStatusAccepted, req: TransitionRequest{Actor: admin, To: models.OrderStatusPreparing}},
+		{name: "Owner sends ready order out", from: models.OrderStatusReady, req: TransitionRequest{Actor: owner, To: models.OrderStatusOutForDelivery}},
+		{name: "Owner delivers", from: models.OrderStatusOutForDelivery, req: TransitionRequest{Actor: owner, To: models.OrderStatusDelivered}},
+		{name: "Customer cancels own pending order", from: models.OrderStatusPending, req: TransitionRequest{Actor: customer, To: models.OrderStatusCancelled, Reason: "Changed my mind"}},
+		{name: "Admin cancels preparing order", from: models.OrderStatusPreparing, req: TransitionRequest{Actor: admin, To: models.OrderStatusCancelled, Reason: "Fraud"}},
+		{name: "Delivered cannot go back to pending", from: models.OrderStatusDelivered, req: TransitionRequest{Actor: owner, To: models.OrderStatusPending}, wantErr: ErrInvalidTransition},
+		{name: "Pending cannot skip to delivered", from: models.OrderStatusPending, req: TransitionRequest{Actor: admin, To: models.OrderStatusDelivered}, wantErr: ErrInvalidTransition},
+		{name: "Unknown status", from: models.OrderStatusPending, req: TransitionRequest{Actor: admin, To: "teleported"}, wantErr: ErrInvalidTransition},
+		{name: "Customer cannot accept", from: models.OrderStatusPending, req: TransitionRequest{Actor: customer, To: models.OrderStatusAccepted}, wantErr: ErrTransitionForbidden},
+		{name: "Staff cannot cancel accepted order", from: models.OrderStatusAccepted, req: TransitionRequest{Actor: staff, To: models.OrderStatusCancelled, Reason: "Busy"}, wantErr: ErrTransitionForbidden},
+		{name: "Customer cannot cancel someone else's order", from: models.OrderStatusPending, req: TransitionRequest{Actor: otherCustomer, To: models.OrderStatusCancelled, Reason: "Nope"}, wantErr: ErrTransitionForbidden},
+		{name: "Rejection needs a reason", from: models.OrderStatusPending, req: TransitionRequest{Actor: owner, To: models.OrderStatusRejected}, wantErr: ErrReasonRequired},
+		{name: "Customer cancels accepted order within window", from: models.OrderStatusAccepted, window: 10, age: 5 * time.Minute, req: TransitionRequest{Actor: customer, To: models.OrderStatusCancelled, Reason: "Too slow"}},
+		{name: "Customer cannot cancel after window", from: models.OrderStatusAccepted, window: 10, age: 15 * time.Minute, req: TransitionRequest{Actor: customer, To: models.OrderStatusCancelled, Reason: "Too slow"}, wantErr: ErrCancellationClosed},
+		{name: "Customer cannot cancel accepted order without window", from: models.OrderStatusAccepted, age: time.Minute, req: TransitionRequest{Actor: customer, To: models.OrderStatusCancelled, Reason: "Too slow"}, wantErr: ErrCancellationClosed},
+		{name: "Owner cancels accepted order after window", from: models.OrderStatusAccepted, age: time.Hour, req: TransitionRequest{Actor: owner, To: models.OrderStatusCancelled, Reason: "Out of stock"}},
+		{name: "Staff member prepares accepted order", from: models.OrderStatusAccepted, req: TransitionRequest{Actor: member, To: models.OrderStatusPreparing}},
+		{name: "Staff member cannot reject without reason", from: models.OrderStatusPending, req: TransitionRequest{Actor: member, To: models.OrderStatusRejected}, wantErr: ErrReasonRequired},
+		{name: "Customer with a membership accepts pending order", from: models.OrderStatusPending, req: TransitionRequest{Actor: customerMember, To: models.OrderStatusAccepted}},
+		{name: "Customer with a membership cannot cancel someone else's order", from: models.OrderStatusPending, req: TransitionRequest{Actor: customerMember, To: models.OrderStatusCancelled, Reason: "Nope"}, wantErr: ErrTransitionForbidden},
+		{name: "Staff without access to the restaurant cannot prepare", from: models.OrderStatusAccepted, req: TransitionRequest{Actor: staff, To: models.OrderStatusPreparing}, wantErr: ErrTransitionForbidden},
+		{name: "Owner of another restaurant cannot accept", from: models.OrderStatusPending, req: TransitionRequest{Actor: otherOwner, To: models.OrderStatusAccepted}, wantErr: ErrTransitionForbidden},
+		{name: "Missing actor", from: models.OrderStatusPending, req: TransitionRequest{To: models.OrderStatusAccepted}, wantErr: ErrTransitionForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &models.Order{
+				UserID:          customer.ID,
+				RestaurantID:    1,
+				Status:          tt.from,
+				DeliveryAddress: "1 Test Rd",
+				Restaurant:      models.Restaurant{UserID: &owner.ID, CancellationWindowMinutes: tt.window},
+			}
+			order.CreatedAt = time.Now().Add(-tt.age)
+			err := CheckTransition(order, tt.req)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransitionOrderRecordsHistory(t *testing.T) {
+	orderService, _, db := setupOrderTest(t)
+
+	owner := models.User{Name: "Owner", Email: "owner@example.com", Password: "x", Phone: "1", Role: models.RoleRestaurantOwner}
+	db.Create(&owner)
+	restaurant := models.Restaurant{Name: "Pizza Place", Address: "Main St", Phone: "123", Email: "pizza@example.com", UserID: &owner.ID}
+	db.Create(&restaurant)
+	order := models.Order{UserID: 1, RestaurantID: restaurant.ID, Status: models.OrderStatusPending, DeliveryAddress: "1 Test Rd", PaymentMethod: "cash"}
+	db.Create(&order)
+
+	updated, err := orderService.TransitionOrder(order.ID, TransitionRequest{Actor: &owner, To: models.OrderStatusAccepted})
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusAccepted, updated.Status)
+
+	_, err = orderService.TransitionOrder(order.ID, TransitionRequest{Actor: &owner, To: models.OrderStatusDelivered})
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+
+	var history []models.OrderStatusHistory
+	db.Where("order_id = ?", order.ID).Find(&history)
+	require.Len(t, history, 1)
+	assert.Equal(t, models.OrderStatusPending, history[0].FromStatus)
+	assert.Equal(t, models.OrderStatusAccepted, history[0].Status)
+	assert.Equal(t, owner.ID, *history[0].ChangedByID)
+}
@@ -1,50 +1,324 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+
 	"github.com/manjurulhoque/foodie/backend/internal/models"
 	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	CheckoutItemsUnavailable      = "items_unavailable"
+	CheckoutRestaurantUnavailable = "restaurant_unavailable"
+	// CheckoutPromotionUnavailable means the promo code on the cart can no longer be
+	// used; the client removes it with DELETE /cart/promo to order without it
+	CheckoutPromotionUnavailable = "promotion_unavailable"
 )
 
+// CheckoutIssue describes a single cart item that blocks checkout
+type CheckoutIssue struct {
+	MenuItemID uint   `json:"menu_item_id"`
+	Name       string `json:"name,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// CheckoutError is returned when the cart cannot be turned into an order
+type CheckoutError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Issues  []CheckoutIssue `json:"issues,omitempty"`
+	Err     error           `json:"-"` // the underlying error, if any
+}
+
+func (e *CheckoutError) Error() string {
+	return e.Message
+}
+
+func (e *CheckoutError) Unwrap() error {
+	return e.Err
+}
+
+// CheckoutInput holds the customer-supplied part of an order
+type CheckoutInput struct {
+	UserID          uint
+	DeliveryAddress string
+	PaymentMethod   string
+	ClientTotal     float64
+}
+
 type OrderService struct {
-	repo     repositories.OrderRepository
-	menuRepo repositories.MenuRepository
+	repo           repositories.OrderRepository
+	menuRepo       repositories.MenuRepository
+	cartRepo       repositories.CartRepository
+	restaurantRepo repositories.RestaurantRepository
+	promotionRepo  repositories.PromotionRepository
+	pricing        PricingService
+	notifier       Notifier
 }
 
-func NewOrderService(repo repositories.OrderRepository, menuRepo repositories.MenuRepository) OrderService {
+func NewOrderService(
+	repo repositories.OrderRepository,
+	menuRepo repositories.MenuRepository,
+	cartRepo repositories.CartRepository,
+	restaurantRepo repositories.RestaurantRepository,
+	promotionRepo repositories.PromotionRepository,
+	pricing PricingService,
+	notifier Notifier,
+) OrderService {
 	return OrderService{
-		repo:     repo,
-		menuRepo: menuRepo,
+		repo:           repo,
+		menuRepo:       menuRepo,
+		cartRepo:       cartRepo,
+		restaurantRepo: restaurantRepo,
+		promotionRepo:  promotionRepo,
+		pricing:        pricing,
+		notifier:       notifier,
 	}
 }
 
-func (s *OrderService) CreateOrder(order *models.Order) error {
-	return s.repo.Create(order)
+// Checkout turns the user's cart into an order in a single transaction. It checks that
+// every item is still available and the restaurant is accepting orders, prices the cart
+// with the discount of its promo code, which must still be usable, writes the order with
+// its items, discounts and first status history entry, and clears the cart. A promo code
+// that was deleted or can no longer be used fails with CheckoutPromotionUnavailable.
+// The quote is returned alongside ErrPriceMismatch so the client can show the real total.
+func (s *OrderService) Checkout(input CheckoutInput) (*models.Order, *PriceQuote, error) {
+	var order *models.Order
+	var quote *PriceQuote
+
+	err := s.repo.Transaction(func(tx *gorm.DB) error {
+		orderRepo := s.repo.WithTx(tx)
+		cartRepo := s.cartRepo.WithTx(tx)
+		menuRepo := s.menuRepo.WithTx(tx)
+		restaurantRepo := s.restaurantRepo.WithTx(tx)
+		promotionRepo := s.promotionRepo.WithTx(tx)
+
+		cart, err := cartRepo.FindByUser(input.UserID)
+		if err != nil {
+			return err
+		}
+		if len(cart.Items) == 0 {
+			return ErrEmptyCart
+		}
+
+		ids := make([]uint, 0, len(cart.Items))
+		for _, item := range cart.Items {
+			ids = append(ids, item.MenuItemID)
+		}
+		menuItems, err := menuRepo.FindByIDs(ids)
+		if err != nil {
+			return err
+		}
+		if err := checkItemsAvailable(cart.Items, menuItems); err != nil {
+			return err
+		}
+
+		quote, err = s.pricing.QuoteMenuItems(cart.Items, menuItems)
+		if err != nil {
+			return err
+		}
+
+		restaurant, err := restaurantRepo.FindByID(quote.RestaurantID)
+		if err != nil {
+			return err
+		}
+		if !restaurant.IsActive || !restaurant.IsOpen {
+			return &CheckoutError{
+				Code:    CheckoutRestaurantUnavailable,
+				Message: fmt.Sprintf("%s is not accepting orders right now", restaurant.Name),
+			}
+		}
+
+		if cart.PromotionID != nil {
+			// Locking the promotion makes concurrent checkouts count its uses one at a time
+			promotion, err := promotionRepo.FindByIDForUpdate(*cart.PromotionID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return promotionUnavailable(ErrPromotionNotFound)
+			}
+			if err != nil {
+				return err
+			}
+			if err := checkPromotion(&promotionRepo, promotion, restaurant, input.UserID, quote); err != nil {
+				return promotionUnavailable(err)
+			}
+			s.pricing.ApplyPromotion(quote, promotion)
+		}
+
+		if err := s.pricing.CheckTotal(quote, input.ClientTotal); err != nil {
+			return err
+		}
+
+		order = &models.Order{
+			UserID:          input.UserID,
+			DeliveryAddress: input.DeliveryAddress,
+			PaymentMethod:   input.PaymentMethod,
+			Status:          models.OrderStatusPending,
+			PaymentStatus:   models.PaymentStatusPending,
+		}
+		quote.Apply(order)
+		if err := orderRepo.Create(order); err != nil {
+			return err
+		}
+
+		if err := orderRepo.CreateStatusHistory(&models.OrderStatusHistory{
+			OrderID:     order.ID,
+			Status:      order.Status,
+			Description: "Order placed",
+			ChangedByID: &input.UserID,
+		}); err != nil {
+			return err
+		}
+
+		return cartRepo.ClearCart(cart.ID)
+	})
+	if err != nil {
+		return nil, quote, err
+	}
+	return order, quote, nil
 }
 
-func (s *OrderService) GetOrder(id uint) (*models.Order, error) {
-	return s.repo.FindByID(id)
+// TransitionOrder moves an order to a new status if the lifecycle allows the actor to,
+// recording the change in the order's status history in the same transaction
+func (s *OrderService) TransitionOrder(orderID uint, req TransitionRequest) (*models.Order, error) {
+	var order *models.Order
+
+	err := s.repo.Transaction(func(tx *gorm.DB) error {
+		orderRepo := s.repo.WithTx(tx)
+
+		var err error
+		order, err = orderRepo.FindByID(orderID)
+		if err != nil {
+			return err
+		}
+		if err := CheckTransition(order, req); err != nil {
+			return err
+		}
+
+		from := order.Status
+		if err := orderRepo.UpdateStatus(order.ID, req.To); err != nil {
+			return err
+		}
+		order.Status = req.To
+
+		// Money already taken for an order that will never be fulfilled has to go back
+		if (req.To == models.OrderStatusCancelled || req.To == models.OrderStatusRejected) &&
+			(order.PaymentStatus == models.PaymentStatusPaid || order.PaymentStatus == models.PaymentStatusPartiallyRefunded) {
+			if err := orderRepo.UpdatePaymentStatus(order.ID, models.PaymentStatusRefundPending); err != nil {
+				return err
+			}
+			order.PaymentStatus = models.PaymentStatusRefundPending
+		}
+
+		description := fmt.Sprintf("Order moved from %s to %s by %s", from, req.To, req.Actor.Role)
+		if req.Reason != "" {
+			description = fmt.Sprintf("%s: %s", description, req.Reason)
+		}
+		return orderRepo.CreateStatusHistory(&models.OrderStatusHistory{
+			OrderID:     order.ID,
+			FromStatus:  from,
+			Status:      req.To,
+			Description: description,
+			ChangedByID: &req.Actor.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
 }
 
-func (s *OrderService) GetUserOrders(userID uint) ([]models.Order, error) {
-	return s.repo.FindByUser(userID)
+// CancelOrder cancels an order on behalf of the actor and lets the restaurant owner know
+func (s *OrderService) CancelOrder(orderID uint, actor *models.User, reason string) (*models.Order, error) {
+	order, err := s.TransitionOrder(orderID, TransitionRequest{
+		Actor:  actor,
+		To:     models.OrderStatusCancelled,
+		Reason: reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Restaurant.UserID != nil {
+		message := fmt.Sprintf("Order #%d was cancelled by the %s: %s", order.ID, actor.Role, reason)
+		if err := s.notifier.Notify(*order.Restaurant.UserID, "Order cancelled", message); err != nil {
+			// The cancellation is already committed, a failed notification must not undo it
+			slog.Error("Failed to notify restaurant owner", "order_id", order.ID, "error", err.Error())
+		}
+	}
+	return order, nil
 }
 
-func (s *OrderService) GetRestaurantOrders(restaurantID uint) ([]models.Order, error) {
-	return s.repo.FindByRestaurant(restaurantID)
+// checkItemsAvailable reports every cart item whose menu item was removed or marked
+// unavailable, or whose options can no longer be chosen. menuItems must have their
+// modifier groups loaded.
+func checkItemsAvailable(items []models.CartItem, menuItems []models.MenuItem) error {
+	byID := make(map[uint]models.MenuItem, len(menuItems))
+	for _, menuItem := range menuItems {
+		byID[menuItem.ID] = menuItem
+	}
+
+	var issues []CheckoutIssue
+	for _, item := range items {
+		menuItem, ok := byID[item.MenuItemID]
+		switch {
+		case !ok:
+			issues = append(issues, CheckoutIssue{MenuItemID: item.MenuItemID, Name: item.MenuItem.Name, Reason: "no longer on the menu"})
+		case !menuItem.IsAvailable:
+			issues = append(issues, CheckoutIssue{MenuItemID: item.MenuItemID, Name: menuItem.Name, Reason: "currently unavailable"})
+		default:
+			// the options may have changed since the item was added
+			optionIDs := make([]uint, 0, len(item.Options))
+			for _, option := range item.Options {
+				optionIDs = append(optionIDs, option.ModifierOptionID)
+			}
+			if _, err := selectOptions(&menuItem, optionIDs); err != nil {
+				issues = append(issues, CheckoutIssue{MenuItemID: item.MenuItemID, Name: menuItem.Name, Reason: "not available with the selected options"})
+			}
+		}
+	}
+
+	if len(issues) > 0 {
+		return &CheckoutError{
+			Code:    CheckoutItemsUnavailable,
+			Message: "Some items in your cart are unavailable",
+			Issues:  issues,
+		}
+	}
+	return nil
+}
+
+// promotionUnavailable turns an error saying the cart's promo code cannot be used into a
+// CheckoutError; other errors are returned as they are
+func promotionUnavailable(err error) error {
+	for _, target := range []error{ErrPromotionNotFound, ErrPromotionNotActive, ErrPromotionNotApplicable, ErrPromotionMinSubtotal, ErrPromotionUsedUp} {
+		if errors.Is(err, target) {
+			return &CheckoutError{
+				Code:    CheckoutPromotionUnavailable,
+				Message: fmt.Sprintf("The promo code on your cart cannot be used: %s", err),
+				Err:     err,
+			}
+		}
+	}
+	return err
 }
 
-func (s *OrderService) UpdateOrderStatus(id uint, status string) error {
-	return s.repo.UpdateStatus(id, status)
+func (s *OrderService) GetOrder(id uint) (*models.Order, error) {
+	return s.repo.FindByID(id)
 }
 
-func (s *OrderService) UpdatePaymentStatus(id uint, status string) error {
-	return s.repo.UpdatePaymentStatus(id, status)
+func (s *OrderService) GetUserOrders(userID uint) ([]models.Order, error) {
+	return s.repo.FindByUser(userID)
 }
 
-func (s *OrderService) GetAllOrders() ([]models.Order, error) {
-	return s.repo.FindAll()
+// GetManagedOrders lists orders placed at restaurants userID owns or works at
+func (s *OrderService) GetManagedOrders(userID uint, filter repositories.OrderFilter) ([]models.Order, int64, error) {
+	return s.repo.FindManagedBy(userID, filter)
 }
 
-func (s *OrderService) UpdateOrder(order *models.Order) error {
-	return s.repo.Update(order)
+// GetManagedOrder returns an order only if it was placed at a restaurant userID owns or works at
+func (s *OrderService) GetManagedOrder(orderID uint, userID uint) (*models.Order, error) {
+	return s.repo.FindByIDManagedBy(orderID, userID)
 }
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+func setupOrderTest(t *testing.T) (OrderService, CartService, *gorm.DB) {
+	db := newTestDB(t)
+	orderRepo := repositories.NewOrderRepository(db)
+	menuRepo := repositories.NewMenuRepository(db)
+	cartRepo := repositories.NewCartRepository(db)
+	restaurantRepo := repositories.NewRestaurantRepository(db)
+	promotionRepo := repositories.NewPromotionRepository(db)
+	pricing := NewPricingService(menuRepo, config.PricingConfig{Tolerance: 0.01})
+
+	orderService := NewOrderService(orderRepo, menuRepo, cartRepo, restaurantRepo, promotionRepo, pricing, NewLogNotifier())
+	cartService := NewCartService(cartRepo, menuRepo, promotionRepo, restaurantRepo, pricing)
+	return orderService, cartService, db
+}
+
+func TestCheckout(t *testing.T) {
+	orderService, cartService, db := setupOrderTest(t)
+
+	restaurant := models.Restaurant{Name: "Pizza Place", Address: "Main St", Phone: "123", Email: "pizza@example.com"}
+	db.Create(&restaurant)
+	pizza := models.MenuItem{Name: "Pizza", Price: 10, Category: "Main", RestaurantID: restaurant.ID}
+	soda := models.MenuItem{Name: "Soda", Price: 2, Category: "Drinks", RestaurantID: restaurant.ID}
+	db.Create(&pizza)
+	db.Create(&soda)
+
+	userID := uint(7)
+	assert.NoError(t, cartService.AddToCart(userID, CartItemInput{MenuItemID: pizza.ID, Quantity: 2}, false))
+	assert.NoError(t, cartService.AddToCart(userID, CartItemInput{MenuItemID: soda.ID, Quantity: 1}, false))
+
+	input := CheckoutInput{UserID: userID, DeliveryAddress: "1 Test Rd", PaymentMethod: "cash", ClientTotal: 22}
+
+	t.Run("Unavailable items are listed", func(t *testing.T) {
+		db.Model(&soda).Update("is_available", false)
+		defer db.Model(&soda).Update("is_available", true)
+
+		_, _, err := orderService.Checkout(input)
+		var checkoutErr *CheckoutError
+		require.ErrorAs(t, err, &checkoutErr)
+		assert.Equal(t, CheckoutItemsUnavailable, checkoutErr.Code)
+		assert.Len(t, checkoutErr.Issues, 1)
+		assert.Equal(t, soda.ID, checkoutErr.Issues[0].MenuItemID)
+	})
+
+	t.Run("Closed restaurant is rejected", func(t *testing.T) {
+		db.Model(&restaurant).Update("is_open", false)
+		defer db.Model(&restaurant).Update("is_open", true)
+
+		_, _, err := orderService.Checkout(input)
+		var checkoutErr *CheckoutError
+		require.ErrorAs(t, err, &checkoutErr)
+		assert.Equal(t, CheckoutRestaurantUnavailable, checkoutErr.Code)
+	})
+
+	t.Run("Price mismatch returns the quote", func(t *testing.T) {
+		tampered := input
+		tampered.ClientTotal = 0.01
+
+		_, quote, err := orderService.Checkout(tampered)
+		assert.ErrorIs(t, err, ErrPriceMismatch)
+		require.NotNil(t, quote)
+		assert.Equal(t, 22.0, quote.Total)
+	})
+
+	// none of the failed attempts may leave anything behind
+	var count int64
+	db.Model(&models.Order{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	t.Run("Success", func(t *testing.T) {
+		order, _, err := orderService.Checkout(input)
+		require.NoError(t, err)
+		assert.Equal(t, restaurant.ID, order.RestaurantID)
+		assert.Equal(t, 22.0, order.TotalAmount)
+		assert.Len(t, order.Items, 2)
+
+		var history []models.OrderStatusHistory
+		db.Where("order_id = ?", order.ID).Find(&history)
+		assert.Len(t, history, 1)
+		assert.Equal(t, "pending", history[0].Status)
+
+		cart, err := cartService.GetUserCart(userID)
+		assert.NoError(t, err)
+		assert.Empty(t, cart.Items)
+	})
+
+	t.Run("Empty cart", func(t *testing.T) {
+		_, _, err := orderService.Checkout(input)
+		assert.ErrorIs(t, err, ErrEmptyCart)
+	})
+}
+
+type recordingNotifier struct {
+	userIDs  []uint
+	messages []string
+}
+
+func (n *recordingNotifier) Notify(userID uint, subject, message string) error {
+	n.userIDs = append(n.userIDs, userID)
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func TestCancelOrder(t *testing.T) {
+	orderService, _, db := setupOrderTest(t)
+	notifier := &recordingNotifier{}
+	orderService.notifier = notifier
+
+	ownerID := uint(9)
+	restaurant := models.Restaurant{Name: "Pizza Place", Address: "Main St", Phone: "123", Email: "pizza@example.com", UserID: &ownerID}
+	db.Create(&restaurant)
+	customer := models.User{Name: "Customer", Email: "customer@example.com", Password: "x", Phone: "1", Role: models.RoleCustomer}
+	db.Create(&customer)
+
+	order := models.Order{
+		UserID:          customer.ID,
+		RestaurantID:    restaurant.ID,
+		Status:          models.OrderStatusPending,
+		DeliveryAddress: "1 Test Rd",
+		PaymentMethod:   "card",
+		PaymentStatus:   models.PaymentStatusPaid,
+	}
+	db.Create(&order)
+
+	cancelled, err := orderService.CancelOrder(order.ID, &customer, "Ordered by mistake")
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusCancelled, cancelled.Status)
+	assert.Equal(t, models.PaymentStatusRefundPending, cancelled.PaymentStatus)
+	assert.Equal(t, []uint{ownerID}, notifier.userIDs)
+
+	var stored models.Order
+	db.First(&stored, order.ID)
+	assert.Equal(t, models.PaymentStatusRefundPending, stored.PaymentStatus)
+
+	_, err = orderService.CancelOrder(order.ID, &customer, "Again")
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+}
+
+func TestGetManagedOrders(t *testing.T) {
+	orderService, _, db := setupOrderTest(t)
+
+	ownerID, otherOwnerID := uint(1), uint(2)
+	mine := models.Restaurant{Name: "Mine", Address: "A", Phone: "1", Email: "mine@example.com", UserID: &ownerID}
+	alsoMine := models.Restaurant{Name: "Also Mine", Address: "B", Phone: "2", Email: "also@example.com", UserID: &ownerID}
+	theirs := models.Restaurant{Name: "Theirs", Address: "C", Phone: "3", Email: "theirs@example.com", UserID: &otherOwnerID}
+	db.Create(&mine)
+	db.Create(&alsoMine)
+	db.Create(&theirs)
+
+	for _, order := range []models.Order{
+		{UserID: 10, RestaurantID: mine.ID, Status: models.OrderStatusPending},
+		{UserID: 10, RestaurantID: mine.ID, Status: models.OrderStatusDelivered},
+		{UserID: 11, RestaurantID: alsoMine.ID, Status: models.OrderStatusPending},
+		{UserID: 12, RestaurantID: theirs.ID, Status: models.OrderStatusPending},
+	} {
+		db.Create(&order)
+	}
+
+	tests := []struct {
+		name      string
+		filter    repositories.OrderFilter
+		wantTotal int64
+		wantLen   int
+	}{
+		{name: "All own orders", filter: repositories.OrderFilter{Page: 1, Limit: 10}, wantTotal: 3, wantLen: 3},
+		{name: "By status", filter: repositories.OrderFilter{Status: models.OrderStatusPending, Page: 1, Limit: 10}, wantTotal: 2, wantLen: 2},
+		{name: "By restaurant", filter: repositories.OrderFilter{RestaurantID: alsoMine.ID, Page: 1, Limit: 10}, wantTotal: 1, wantLen: 1},
+		{name: "Other owner's restaurant", filter: repositories.OrderFilter{RestaurantID: theirs.ID, Page: 1, Limit: 10}, wantTotal: 0, wantLen: 0},
+		{name: "Paginated", filter: repositories.OrderFilter{Page: 2, Limit: 2}, wantTotal: 3, wantLen: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, total, err := orderService.GetManagedOrders(ownerID, tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTotal, total)
+			assert.Len(t, orders, tt.wantLen)
+			for _, order := range orders {
+				assert.NotEqual(t, theirs.ID, order.RestaurantID)
+			}
+		})
+	}
+
+	var theirOrder models.Order
+	db.Where("restaurant_id = ?", theirs.ID).First(&theirOrder)
+	_, err := orderService.GetManagedOrder(theirOrder.ID, ownerID)
+	assert.Error(t, err)
+	_, err = orderService.GetManagedOrder(theirOrder.ID, otherOwnerID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidResetToken = errors.New("password reset link is invalid or has expired")
+
+type PasswordResetService interface {
+	// RequestReset emails a reset link if an account with the address exists. It does
+	// not report whether one does, so it cannot be used to find registered emails.
+	RequestReset(email string) error
+	// ResetPassword sets a new password using a reset token and ends every session
+	ResetPassword(token, newPassword string) error
+}
+
+type passwordResetService struct {
+	userRepo  repositories.UserRepository
+	tokenRepo repositories.TokenRepository
+	mailer    Mailer
+	hasher    PasswordHasher
+	cfg       config.PasswordResetConfig
+}
+
+func NewPasswordResetService(
+	userRepo repositories.UserRepository,
+	tokenRepo repositories.TokenRepository,
+	mailer Mailer,
+	hasher PasswordHasher,
+	cfg config.PasswordResetConfig,
+) PasswordResetService {
+	return &passwordResetService{userRepo: userRepo, tokenRepo: tokenRepo, mailer: mailer, hasher: hasher, cfg: cfg}
+}
+
+func (s *passwordResetService) RequestReset(email string) error {
+	user, err := s.userRepo.GetUserByEmail(strings.TrimSpace(email))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.Info("Password reset requested for unknown email", "email", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only the newest link works
+	if err := s.tokenRepo.InvalidatePasswordResetTokens(user.ID); err != nil {
+		return err
+	}
+	token, err := newRandomToken()
+	if err != nil {
+		return err
+	}
+	if err := s.tokenRepo.CreatePasswordResetToken(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.cfg.TokenTTL),
+	}); err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.cfg.LinkBaseURL, "/") + "/reset-password?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. To choose a new password, open the link below:\n\n%s\n\nThe link expires in %s. If you did not ask for this, you can ignore this email.\n",
+		user.Name, link, s.cfg.TokenTTL)
+	// The response must not tell registered addresses apart, so a failed send is
+	// only logged
+	if err := s.mailer.Send(user.Email, "Reset your password", body); err != nil {
+		slog.Error("Failed to send password reset email", "user_id", user.ID, "error", err)
+	}
+	return nil
+}
+
+func (s *passwordResetService) ResetPassword(token, newPassword string) error {
+	stored, err := s.tokenRepo.FindPasswordResetToken(hashToken(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	// The token is only spent if the new password is saved too
+	return s.tokenRepo.Transaction(func(tx *gorm.DB) error {
+		tokenRepo := s.tokenRepo.WithTx(tx)
+		used, err := tokenRepo.UsePasswordResetToken(stored.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidResetToken
+		}
+		return storePassword(repositories.NewUserRepository(tx), tokenRepo, stored.UserID, hashedPassword)
+	})
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+func TestPasswordReset(t *testing.T) {
+	userService, db := setupUserTest(t)
+	mailer := &recordingMailer{}
+	service := NewPasswordResetService(repositories.NewUserRepository(db), repositories.NewTokenRepository(db), mailer, testHasher, config.PasswordResetConfig{
+		LinkBaseURL: "http://localhost:3000",
+		TokenTTL:    time.Hour,
+	})
+
+	_, refresh, err := userService.LoginUser("jane@example.com", "secret123", testClient)
+	require.NoError(t, err)
+
+	t.Run("Unknown email", func(t *testing.T) {
+		require.NoError(t, service.RequestReset("nobody@example.com"))
+		assert.Empty(t, mailer.sent)
+	})
+
+	require.NoError(t, service.RequestReset("jane@example.com"))
+	require.Len(t, mailer.sent, 1)
+	firstToken := tokenFromMail(t, mailer.sent[0])
+	require.NoError(t, service.RequestReset("jane@example.com"))
+	token := tokenFromMail(t, mailer.sent[1])
+
+	var stored models.PasswordResetToken
+	db.Where("used_at IS NULL").First(&stored)
+	assert.NotEqual(t, token, stored.TokenHash, "tokens are hashed at rest")
+
+	t.Run("Older links stop working", func(t *testing.T) {
+		assert.ErrorIs(t, service.ResetPassword(firstToken, "newsecret"), ErrInvalidResetToken)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		require.NoError(t, service.ResetPassword(token, "newsecret"))
+
+		_, _, err := userService.LoginUser("jane@example.com", "newsecret", testClient)
+		assert.NoError(t, err)
+		_, _, err = userService.RefreshTokens(refresh)
+		assert.Error(t, err)
+
+		// tokens are single use
+		assert.ErrorIs(t, service.ResetPassword(token, "another1"), ErrInvalidResetToken)
+	})
+
+	t.Run("Expired token", func(t *testing.T) {
+		require.NoError(t, service.RequestReset("jane@example.com"))
+		token := tokenFromMail(t, mailer.sent[len(mailer.sent)-1])
+		db.Model(&models.PasswordResetToken{}).Where("used_at IS NULL").Update("expires_at", time.Now().Add(-time.Minute))
+
+		assert.ErrorIs(t, service.ResetPassword(token, "another1"), ErrInvalidResetToken)
+	})
+
+	t.Run("Mailer failure looks like success", func(t *testing.T) {
+		mailer.err = errors.New("smtp unavailable")
+		defer func() { mailer.err = nil }()
+		assert.NoError(t, service.RequestReset("jane@example.com"))
+	})
+}
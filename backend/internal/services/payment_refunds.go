@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefundReasonRequired = errors.New("a reason is required for a refund")
+	ErrNoCapturedPayment    = errors.New("order has no captured payment to refund")
+	ErrNothingToRefund      = errors.New("order has already been refunded in full")
+	ErrInvalidRefundItem    = errors.New("invalid refund item")
+)
+
+// refundTolerance absorbs float rounding when comparing refunded sums with a payment
+const refundTolerance = 0.005
+
+// RefundItemRequest asks to refund some units of an order item
+type RefundItemRequest struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,min=1"`
+}
+
+// RefundRequest describes a refund. Without items, everything still refundable on the
+// order's payment is returned, delivery fee included.
+type RefundRequest struct {
+	Actor  *models.User
+	Reason string
+	Items  []RefundItemRequest
+}
+
+// Refund returns money from an order's captured payment through its provider and
+// records it in the refund ledger. Item refunds return the item price less its share
+// of item discounts and plus its share of tax, and each unit can only be refunded once.
+// Refunds of the same payment run one at a time, so together they never exceed it.
+func (s *PaymentService) Refund(ctx context.Context, order *models.Order, req RefundRequest) (*models.Refund, error) {
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, ErrRefundReasonRequired
+	}
+	switch order.PaymentStatus {
+	case models.PaymentStatusPaid, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefundPending:
+	case models.PaymentStatusRefunded:
+		return nil, ErrNothingToRefund
+	default:
+		return nil, ErrNoCapturedPayment
+	}
+
+	payment, err := s.repo.FindCapturedByOrder(order.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoCapturedPayment
+	}
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := s.providers[payment.Provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedPaymentMethod, payment.Provider)
+	}
+
+	refund := &models.Refund{
+		OrderID:     order.ID,
+		PaymentID:   payment.ID,
+		Reason:      strings.TrimSpace(req.Reason),
+		CreatedByID: req.Actor.ID,
+	}
+	status := ""
+	issued := false
+	err = s.repo.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		refundRepo := s.refundRepo.WithTx(tx)
+		orderRepo := s.orderRepo.WithTx(tx)
+
+		// A concurrent refund of the payment waits here until this one is recorded,
+		// so the amounts below include it
+		if err := repo.Lock(payment.ID); err != nil {
+			return err
+		}
+		refunded, err := refundRepo.TotalByPayment(payment.ID)
+		if err != nil {
+			return err
+		}
+		remaining := roundMoney(payment.Amount - refunded)
+		if remaining < refundTolerance {
+			return ErrNothingToRefund
+		}
+
+		refund.Amount = remaining
+		if len(req.Items) > 0 {
+			refund.Items, err = refundItems(refundRepo, order, req.Items)
+			if err != nil {
+				return err
+			}
+			amount := 0.0
+			for _, item := range refund.Items {
+				amount += item.Amount
+			}
+			// rounding the tax share per item can overshoot the last cent
+			refund.Amount = min(roundMoney(amount), remaining)
+		}
+
+		refund.ProviderRef, err = provider.Refund(ctx, payment.ProviderRef, refund.Amount)
+		if err != nil {
+			return fmt.Errorf("refunding %s payment: %w", provider.Name(), err)
+		}
+		issued = true
+
+		status = models.PaymentStatusPartiallyRefunded
+		if refunded+refund.Amount >= payment.Amount-refundTolerance {
+			status = models.PaymentStatusRefunded
+		}
+		if err := refundRepo.Create(refund); err != nil {
+			return err
+		}
+		return orderRepo.UpdatePaymentStatus(order.ID, status)
+	})
+	if err != nil {
+		if issued {
+			// the money has left; someone has to record it by hand
+			slog.Error("Refund was issued but not recorded", "order_id", order.ID, "provider", provider.Name(),
+				"provider_ref", refund.ProviderRef, "amount", refund.Amount, "error", err)
+		}
+		return nil, err
+	}
+	order.PaymentStatus = status
+	return refund, nil
+}
+
+// GetOrderRefunds lists the refunds of an order, oldest first
+func (s *PaymentService) GetOrderRefunds(orderID uint) ([]models.Refund, error) {
+	return s.refundRepo.FindByOrder(orderID)
+}
+
+// refundItems prices the requested item refunds, checking that the order's Items are
+// not refunded beyond the quantity ordered
+func refundItems(refundRepo repositories.RefundRepository, order *models.Order, requested []RefundItemRequest) ([]models.RefundItem, error) {
+	refundedQuantities, err := refundRepo.RefundedQuantities(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	orderItems := make(map[uint]models.OrderItem, len(order.Items))
+	for _, item := range order.Items {
+		orderItems[item.ID] = item
+	}
+	// what was paid for the items per unit of their price
+	paidFactor := 1.0
+	if order.Subtotal > 0 {
+		paidFactor = (order.Subtotal - itemDiscount(order.Discounts) + order.TaxAmount) / order.Subtotal
+	}
+
+	items := make([]models.RefundItem, 0, len(requested))
+	for _, req := range requested {
+		orderItem, ok := orderItems[req.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("%w: order has no item %d", ErrInvalidRefundItem, req.OrderItemID)
+		}
+		if req.Quantity < 1 {
+			return nil, fmt.Errorf("%w: quantity of item %d must be positive", ErrInvalidRefundItem, req.OrderItemID)
+		}
+		available := orderItem.Quantity - refundedQuantities[req.OrderItemID]
+		if req.Quantity > available {
+			return nil, fmt.Errorf("%w: only %d of item %d can still be refunded", ErrInvalidRefundItem, available, req.OrderItemID)
+		}
+		refundedQuantities[req.OrderItemID] += req.Quantity
+
+		items = append(items, models.RefundItem{
+			OrderItemID: req.OrderItemID,
+			Quantity:    req.Quantity,
+			Amount:      roundMoney(orderItem.Price * float64(req.Quantity) * paidFactor),
+		})
+	}
+	return items, nil
+}
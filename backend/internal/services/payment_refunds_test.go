@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/payments"
+)
+
+// createRefundOrder adds two lines to a payment order: 2 x 5.00 and 1 x 10.00, with
+// 10% tax and a 2.50 delivery fee
+func createRefundOrder(t *testing.T, db *gorm.DB, method string) *models.Order {
+	t.Helper()
+	order := createPaymentOrder(t, db, method)
+	require.NoError(t, db.Model(order).Updates(map[string]any{"subtotal": 20, "tax_amount": 2, "delivery_fee": 2.5}).Error)
+	items := []models.OrderItem{
+		{OrderID: order.ID, MenuItemID: 1, Quantity: 2, Price: 5},
+		{OrderID: order.ID, MenuItemID: 2, Quantity: 1, Price: 10},
+	}
+	require.NoError(t, db.Create(&items).Error)
+	return reloadOrderWithItems(t, db, order)
+}
+
+func reloadOrderWithItems(t *testing.T, db *gorm.DB, order *models.Order) *models.Order {
+	t.Helper()
+	var stored models.Order
+	require.NoError(t, db.Preload("Items").First(&stored, order.ID).Error)
+	return &stored
+}
+
+func TestRefundItemsThenRest(t *testing.T) {
+	ctx := context.Background()
+	service, _, db := setupPaymentTest(t)
+	owner := &models.User{BaseModel: models.BaseModel{ID: 7}}
+	order := createRefundOrder(t, db, payments.CashName)
+
+	_, err := service.Refund(ctx, order, RefundRequest{Actor: owner, Reason: "cold food"})
+	assert.ErrorIs(t, err, ErrNoCapturedPayment, "nothing was paid yet")
+
+	_, err = service.Capture(ctx, order)
+	require.NoError(t, err)
+	order = reloadOrderWithItems(t, db, order)
+
+	_, err = service.Refund(ctx, order, RefundRequest{Actor: owner, Reason: "  "})
+	assert.ErrorIs(t, err, ErrRefundReasonRequired)
+
+	first := order.Items[0]
+	refund, err := service.Refund(ctx, order, RefundRequest{
+		Actor:  owner,
+		Reason: "one drink was missing",
+		Items:  []RefundItemRequest{{OrderItemID: first.ID, Quantity: 1}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 5.5, refund.Amount, "the item price plus its share of tax")
+	assert.Equal(t, owner.ID, refund.CreatedByID)
+	assert.NotEmpty(t, refund.ProviderRef)
+	require.Len(t, refund.Items, 1)
+	assert.Equal(t, models.PaymentStatusPartiallyRefunded, reloadOrder(t, db, order).PaymentStatus)
+
+	t.Run("invalid items", func(t *testing.T) {
+		_, err := service.Refund(ctx, order, RefundRequest{
+			Actor:  owner,
+			Reason: "again",
+			Items:  []RefundItemRequest{{OrderItemID: first.ID, Quantity: 2}},
+		})
+		assert.ErrorIs(t, err, ErrInvalidRefundItem, "one of the two units was refunded already")
+
+		_, err = service.Refund(ctx, order, RefundRequest{
+			Actor:  owner,
+			Reason: "wrong order",
+			Items:  []RefundItemRequest{{OrderItemID: 9999, Quantity: 1}},
+		})
+		assert.ErrorIs(t, err, ErrInvalidRefundItem)
+	})
+
+	rest, err := service.Refund(ctx, order, RefundRequest{Actor: owner, Reason: "order never arrived"})
+	require.NoError(t, err)
+	assert.Equal(t, 19.0, rest.Amount, "the rest of the payment, delivery fee included")
+	assert.Empty(t, rest.Items)
+	assert.Equal(t, models.PaymentStatusRefunded, reloadOrder(t, db, order).PaymentStatus)
+
+	_, err = service.Refund(ctx, reloadOrderWithItems(t, db, order), RefundRequest{Actor: owner, Reason: "once more"})
+	assert.ErrorIs(t, err, ErrNothingToRefund)
+
+	refunds, err := service.GetOrderRefunds(order.ID)
+	require.NoError(t, err)
+	require.Len(t, refunds, 2)
+	assert.Len(t, refunds[0].Items, 1)
+	assert.Equal(t, 24.5, refunds[0].Amount+refunds[1].Amount)
+}
+
+func TestRefundItemsInFull(t *testing.T) {
+	ctx := context.Background()
+	service, _, db := setupPaymentTest(t)
+	admin := &models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleAdmin}
+	order := createRefundOrder(t, db, payments.CashName)
+	_, err := service.Capture(ctx, order)
+	require.NoError(t, err)
+	order = reloadOrderWithItems(t, db, order)
+
+	refund, err := service.Refund(ctx, order, RefundRequest{
+		Actor:  admin,
+		Reason: "kitchen closed",
+		Items: []RefundItemRequest{
+			{OrderItemID: order.Items[0].ID, Quantity: 2},
+			{OrderItemID: order.Items[1].ID, Quantity: 1},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 22.0, refund.Amount)
+	assert.Equal(t, models.PaymentStatusPartiallyRefunded, reloadOrder(t, db, order).PaymentStatus,
+		"the delivery fee has not been returned")
+}
+
+func TestRefundCancelledCardPayment(t *testing.T) {
+	ctx := context.Background()
+	service, card, db := setupPaymentTest(t)
+	admin := &models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleAdmin}
+	order := createRefundOrder(t, db, payments.FakeCardName)
+
+	payment, err := service.StartPayment(ctx, order)
+	require.NoError(t, err)
+	db.Model(&models.Order{}).Where("id = ?", order.ID).Update("status", models.OrderStatusCancelled)
+	payload, header, err := card.Settle(payment.ProviderRef, payments.StatusSucceeded)
+	require.NoError(t, err)
+	require.NoError(t, service.HandleWebhook(ctx, payments.FakeCardName, payload, header))
+	order = reloadOrderWithItems(t, db, order)
+	require.Equal(t, models.PaymentStatusRefundPending, order.PaymentStatus)
+
+	refund, err := service.Refund(ctx, order, RefundRequest{Actor: admin, Reason: "order cancelled"})
+	require.NoError(t, err)
+	assert.Equal(t, 24.5, refund.Amount)
+	assert.Equal(t, payment.ID, refund.PaymentID)
+	assert.Equal(t, models.PaymentStatusRefunded, reloadOrder(t, db, order).PaymentStatus)
+
+	// the provider refuses to return more than it took even if the ledger is off
+	_, err = card.Refund(ctx, payment.ProviderRef, 0.01)
+	assert.ErrorIs(t, err, payments.ErrRefundExceedsAmount)
+}
+
+func TestWebhookAfterRefund(t *testing.T) {
+	ctx := context.Background()
+	service, card, db := setupPaymentTest(t)
+	admin := &models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleAdmin}
+	order := createRefundOrder(t, db, payments.FakeCardName)
+
+	payment, err := service.StartPayment(ctx, order)
+	require.NoError(t, err)
+	payload, header, err := card.Settle(payment.ProviderRef, payments.StatusSucceeded)
+	require.NoError(t, err)
+	require.NoError(t, service.HandleWebhook(ctx, payments.FakeCardName, payload, header))
+
+	_, err = service.Refund(ctx, reloadOrderWithItems(t, db, order), RefundRequest{Actor: admin, Reason: "order never arrived"})
+	require.NoError(t, err)
+	require.Equal(t, models.PaymentStatusRefunded, reloadOrder(t, db, order).PaymentStatus)
+
+	// a provider resending success under a new event ID must not undo the refund
+	payload, header = card.Webhook(payments.Event{ID: "evt_resent", IntentID: payment.ProviderRef, Status: payments.StatusSucceeded})
+	require.NoError(t, service.HandleWebhook(ctx, payments.FakeCardName, payload, header))
+	assert.Equal(t, models.PaymentStatusRefunded, reloadOrder(t, db, order).PaymentStatus)
+}
+
+func TestConcurrentRefunds(t *testing.T) {
+	ctx := context.Background()
+	service, _, db := setupPaymentTest(t)
+	admin := &models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleAdmin}
+	order := createRefundOrder(t, db, payments.CashName)
+	_, err := service.Capture(ctx, order)
+	require.NoError(t, err)
+	order = reloadOrderWithItems(t, db, order)
+
+	// every request starts from the same order, as if several admins refunded at once
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stale := *order
+			_, errs[i] = service.Refund(ctx, &stale, RefundRequest{Actor: admin, Reason: "order cancelled"})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			assert.ErrorIs(t, err, ErrNothingToRefund)
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+
+	refunds, err := service.GetOrderRefunds(order.ID)
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+	assert.Equal(t, 24.5, refunds[0].Amount)
+}
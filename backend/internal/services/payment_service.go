@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/payments"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnsupportedPaymentMethod = errors.New("unsupported payment method")
+	ErrOrderAlreadyPaid         = errors.New("order is already paid")
+	ErrOrderNotPayable          = errors.New("order can no longer be paid")
+	ErrInvalidWebhook           = errors.New("invalid webhook")
+	ErrUnknownPayment           = errors.New("webhook refers to an unknown payment")
+	ErrPaymentRecorded          = errors.New("order has a recorded payment, its status follows the payment provider")
+)
+
+// paymentStatusRank orders payment statuses so that late or repeated provider
+// responses never move a payment backwards. Failed is final like paid, but a failed
+// payment can be followed by a new one.
+var paymentStatusRank = map[string]int{
+	models.PaymentStatusPending:    0,
+	models.PaymentStatusAuthorized: 1,
+	models.PaymentStatusPaid:       2,
+	models.PaymentStatusFailed:     2,
+}
+
+type PaymentService struct {
+	repo       repositories.PaymentRepository
+	refundRepo repositories.RefundRepository
+	orderRepo  repositories.OrderRepository
+	providers  map[string]payments.PaymentProvider
+}
+
+func NewPaymentService(
+	repo repositories.PaymentRepository,
+	refundRepo repositories.RefundRepository,
+	orderRepo repositories.OrderRepository,
+	providers []payments.PaymentProvider,
+) PaymentService {
+	byName := make(map[string]payments.PaymentProvider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return PaymentService{repo: repo, refundRepo: refundRepo, orderRepo: orderRepo, providers: byName}
+}
+
+// NewPaymentProviders creates the enabled payment providers from configuration
+func NewPaymentProviders(cfg config.PaymentsConfig) []payments.PaymentProvider {
+	providers := make([]payments.PaymentProvider, 0, len(cfg.Providers))
+	for _, name := range cfg.Providers {
+		switch name {
+		case payments.CashName:
+			providers = append(providers, payments.NewCashOnDelivery())
+		case payments.FakeCardName:
+			providers = append(providers, payments.NewFakeCard([]byte(cfg.FakeCardWebhookSecret)))
+		}
+	}
+	return providers
+}
+
+// Methods lists the payment methods customers can choose at checkout
+func (s *PaymentService) Methods() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *PaymentService) Supports(method string) bool {
+	_, ok := s.providers[method]
+	return ok
+}
+
+// StartPayment starts collecting an order's total with the provider of its payment
+// method. An open payment is returned as it is, so retrying does not charge twice;
+// only a newly created payment carries the client secret.
+func (s *PaymentService) StartPayment(ctx context.Context, order *models.Order) (*models.Payment, error) {
+	provider, ok := s.providers[order.PaymentMethod]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedPaymentMethod, order.PaymentMethod)
+	}
+	switch {
+	case order.PaymentStatus == models.PaymentStatusPaid:
+		return nil, ErrOrderAlreadyPaid
+	case order.PaymentStatus == models.PaymentStatusRefundPending,
+		order.PaymentStatus == models.PaymentStatusPartiallyRefunded,
+		order.PaymentStatus == models.PaymentStatusRefunded,
+		order.Status == models.OrderStatusCancelled, order.Status == models.OrderStatusRejected:
+		return nil, ErrOrderNotPayable
+	}
+
+	if payment, err := s.repo.FindOpenByOrder(order.ID); err == nil {
+		return payment, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	intent, err := provider.CreateIntent(ctx, payments.IntentRequest{OrderID: order.ID, Amount: order.TotalAmount})
+	if err != nil {
+		return nil, fmt.Errorf("starting %s payment: %w", provider.Name(), err)
+	}
+	payment := &models.Payment{
+		OrderID:      order.ID,
+		Provider:     provider.Name(),
+		ProviderRef:  intent.ID,
+		Amount:       order.TotalAmount,
+		Status:       paymentStatus(intent.Status),
+		ClientSecret: intent.ClientSecret,
+	}
+	err = s.repo.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.Create(payment); err != nil {
+			return err
+		}
+		// a new attempt after a failed one puts the order back to pending
+		return s.mirrorStatus(tx, payment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// Capture takes the money of an order's open payment, starting one first for orders
+// that have none, such as cash orders placed before payments were recorded
+func (s *PaymentService) Capture(ctx context.Context, order *models.Order) (*models.Payment, error) {
+	payment, err := s.repo.FindOpenByOrder(order.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		payment, err = s.StartPayment(ctx, order)
+	}
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := s.providers[payment.Provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedPaymentMethod, payment.Provider)
+	}
+
+	status, err := provider.Capture(ctx, payment.ProviderRef, payment.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("capturing %s payment: %w", provider.Name(), err)
+	}
+	err = s.repo.Transaction(func(tx *gorm.DB) error {
+		return s.applyStatus(tx, payment, paymentStatus(status))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// HandleWebhook verifies a provider's webhook and applies its event. Events that were
+// already applied, or that would move a payment backwards, are acknowledged without
+// changing anything so the provider stops retrying.
+func (s *PaymentService) HandleWebhook(ctx context.Context, providerName string, payload []byte, header http.Header) error {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedPaymentMethod, providerName)
+	}
+	event, err := provider.VerifyWebhook(payload, header)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidWebhook, err)
+	}
+
+	return s.repo.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		payment, err := repo.FindByProviderRef(provider.Name(), event.IntentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnknownPayment
+		}
+		if err != nil {
+			return err
+		}
+
+		status := paymentStatus(event.Status)
+		recorded, err := repo.RecordEvent(&models.PaymentEvent{
+			Provider:  provider.Name(),
+			EventID:   event.ID,
+			PaymentID: payment.ID,
+			Status:    status,
+		})
+		if err != nil || !recorded {
+			return err
+		}
+		return s.applyStatus(tx, payment, status)
+	})
+}
+
+// GetOrderPayments lists the payment attempts of an order, oldest first
+func (s *PaymentService) GetOrderPayments(orderID uint) ([]models.Payment, error) {
+	return s.repo.FindByOrder(orderID)
+}
+
+// SetOrderPaymentStatus changes the payment status of an order by hand. Orders with a
+// recorded payment follow their provider instead, so they are refused.
+func (s *PaymentService) SetOrderPaymentStatus(orderID uint, status string) error {
+	return s.repo.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		orderRepo := s.orderRepo.WithTx(tx)
+		recorded, err := repo.FindByOrder(orderID)
+		if err != nil {
+			return err
+		}
+		if len(recorded) > 0 {
+			return ErrPaymentRecorded
+		}
+		return orderRepo.UpdatePaymentStatus(orderID, status)
+	})
+}
+
+// applyStatus moves a payment forward and mirrors the change on its order. A status
+// the payment already has changes nothing, so repeated events cannot undo a refund.
+func (s *PaymentService) applyStatus(tx *gorm.DB, payment *models.Payment, status string) error {
+	if paymentStatusRank[status] <= paymentStatusRank[payment.Status] {
+		return nil
+	}
+	repo := s.repo.WithTx(tx)
+	if err := repo.UpdateStatus(payment.ID, status); err != nil {
+		return err
+	}
+	payment.Status = status
+	return s.mirrorStatus(tx, payment)
+}
+
+// mirrorStatus copies a payment's status onto its order. Money arriving for an order
+// that was cancelled in the meantime is marked for refund, and orders whose refund
+// has started are left alone.
+func (s *PaymentService) mirrorStatus(tx *gorm.DB, payment *models.Payment) error {
+	orderRepo := s.orderRepo.WithTx(tx)
+	order, err := orderRepo.FindByID(payment.OrderID)
+	if err != nil {
+		return err
+	}
+	switch order.PaymentStatus {
+	case models.PaymentStatusRefundPending, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded:
+		return nil
+	}
+
+	orderStatus := payment.Status
+	switch {
+	case payment.Status == models.PaymentStatusAuthorized:
+		orderStatus = models.PaymentStatusPending
+	case payment.Status == models.PaymentStatusPaid &&
+		(order.Status == models.OrderStatusCancelled || order.Status == models.OrderStatusRejected):
+		orderStatus = models.PaymentStatusRefundPending
+	}
+	if order.PaymentStatus == orderStatus {
+		return nil
+	}
+	return orderRepo.UpdatePaymentStatus(order.ID, orderStatus)
+}
+
+// paymentStatus maps a provider status to the status stored on payments
+func paymentStatus(status payments.Status) string {
+	switch status {
+	case payments.StatusAuthorized:
+		return models.PaymentStatusAuthorized
+	case payments.StatusSucceeded:
+		return models.PaymentStatusPaid
+	case payments.StatusFailed:
+		return models.PaymentStatusFailed
+	default:
+		return models.PaymentStatusPending
+	}
+}
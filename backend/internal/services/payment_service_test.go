@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/payments"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+func setupPaymentTest(t *testing.T) (PaymentService, *payments.FakeCard, *gorm.DB) {
+	db := newTestDB(t)
+	card := payments.NewFakeCard([]byte("whsec_test"))
+	service := NewPaymentService(
+		repositories.NewPaymentRepository(db),
+		repositories.NewRefundRepository(db),
+		repositories.NewOrderRepository(db),
+		[]payments.PaymentProvider{payments.NewCashOnDelivery(), card},
+	)
+	return service, card, db
+}
+
+func createPaymentOrder(t *testing.T, db *gorm.DB, method string) *models.Order {
+	t.Helper()
+	restaurant := models.Restaurant{Name: "Pizza Place", Address: "Main St", Phone: "123", Email: "pizza@example.com"}
+	require.NoError(t, db.Create(&restaurant).Error)
+	order := models.Order{
+		UserID:          1,
+		RestaurantID:    restaurant.ID,
+		TotalAmount:     24.5,
+		Status:          models.OrderStatusPending,
+		DeliveryAddress: "1 Test Rd",
+		PaymentMethod:   method,
+		PaymentStatus:   models.PaymentStatusPending,
+	}
+	require.NoError(t, db.Create(&order).Error)
+	return &order
+}
+
+func reloadOrder(t *testing.T, db *gorm.DB, order *models.Order) *models.Order {
+	t.Helper()
+	var stored models.Order
+	require.NoError(t, db.First(&stored, order.ID).Error)
+	return &stored
+}
+
+func TestCardPaymentWebhooks(t *testing.T) {
+	ctx := context.Background()
+	service, card, db := setupPaymentTest(t)
+	order := createPaymentOrder(t, db, payments.FakeCardName)
+
+	payment, err := service.StartPayment(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPending, payment.Status)
+	assert.Equal(t, 24.5, payment.Amount)
+	assert.NotEmpty(t, payment.ClientSecret)
+
+	again, err := service.StartPayment(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, payment.ID, again.ID, "an open payment is reused")
+	assert.Empty(t, again.ClientSecret, "the client secret is not stored")
+
+	payload, header, err := card.Settle(payment.ProviderRef, payments.StatusSucceeded)
+	require.NoError(t, err)
+	require.NoError(t, service.HandleWebhook(ctx, payments.FakeCardName, payload, header))
+	assert.Equal(t, models.PaymentStatusPaid, reloadOrder(t, db, order).PaymentStatus)
+
+	t.Run("redelivered event is ignored", func(t *testing.T) {
+		db.Model(&models.Order{}).Where("id = ?", order.ID).Update("payment_status", models.PaymentStatusPending)
+		defer db.Model(&models.Order{}).Where("id = ?", order.ID).Update("payment_status", models.PaymentStatusPaid)
+
+		require.NoError(t, service.HandleWebhook(ctx, payments.FakeCardName, payload, header))
+		assert.Equal(t, models.PaymentStatusPending, reloadOrder(t, db, order).PaymentStatus)
+		var events int64
+		db.Model(&models.PaymentEvent{}).Count(&events)
+		assert.Equal(t, int64(1), events)
+	})
+
+	t.Run("late event does not move the payment back", func(t *testing.T) {
+		payload, header := card.Webhook(payments.Event{ID: "evt_late", IntentID: payment.ProviderRef, Status: payments.StatusFailed})
+		require.NoError(t, service.HandleWebhook(ctx, payments.FakeCardName, payload, header))
+		orderPayments, err := service.GetOrderPayments(order.ID)
+		require.NoError(t, err)
+		require.Len(t, orderPayments, 1)
+		assert.Equal(t, models.PaymentStatusPaid, orderPayments[0].Status)
+		assert.Equal(t, models.PaymentStatusPaid, reloadOrder(t, db, order).PaymentStatus)
+	})
+
+	t.Run("paid orders cannot be paid again", func(t *testing.T) {
+		_, err := service.StartPayment(ctx, reloadOrder(t, db, order))
+		assert.ErrorIs(t, err, ErrOrderAlreadyPaid)
+	})
+
+	t.Run("rejected webhooks", func(t *testing.T) {
+		err := service.HandleWebhook(ctx, payments.FakeCardName, append(payload, ' '), header)
+		assert.ErrorIs(t, err, ErrInvalidWebhook)
+
+		unknown, unknownHeader := card.Webhook(payments.Event{ID: "evt_unknown", IntentID: "fake_pi_unknown", Status: payments.StatusSucceeded})
+		err = service.HandleWebhook(ctx, payments.FakeCardName, unknown, unknownHeader)
+		assert.ErrorIs(t, err, ErrUnknownPayment)
+
+		err = service.HandleWebhook(ctx, "paypal", payload, header)
+		assert.ErrorIs(t, err, ErrUnsupportedPaymentMethod)
+
+		err = service.HandleWebhook(ctx, payments.CashName, payload, http.Header{})
+		assert.ErrorIs(t, err, ErrInvalidWebhook)
+	})
+}
+
+func TestCardAuthorizeAndCapture(t *testing.T) {
+	ctx := context.Background()
+	service, card, db := setupPaymentTest(t)
+	order := createPaymentOrder(t, db, payments.FakeCardName)
+
+	payment, err := service.StartPayment(ctx, order)
+	require.NoError(t, err)
+
+	_, err = service.Capture(ctx, order)
+	assert.ErrorIs(t, err, payments.ErrNotCapturable, "the customer has not entered a card yet")
+
+	payload, header, err := card.Settle(payment.ProviderRef, payments.StatusAuthorized)
+	require.NoError(t, err)
+	require.NoError(t, service.HandleWebhook(ctx, payments.FakeCardName, payload, header))
+	assert.Equal(t, models.PaymentStatusPending, reloadOrder(t, db, order).PaymentStatus, "authorized money is not taken yet")
+
+	captured, err := service.Capture(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, payment.ID, captured.ID)
+	assert.Equal(t, models.PaymentStatusPaid, captured.Status)
+	assert.Equal(t, models.PaymentStatusPaid, reloadOrder(t, db, order).PaymentStatus)
+}
+
+func TestFailedPaymentCanBeRetried(t *testing.T) {
+	ctx := context.Background()
+	service, card, db := setupPaymentTest(t)
+	order := createPaymentOrder(t, db, payments.FakeCardName)
+
+	first, err := service.StartPayment(ctx, order)
+	require.NoError(t, err)
+	payload, header, err := card.Settle(first.ProviderRef, payments.StatusFailed)
+	require.NoError(t, err)
+	require.NoError(t, service.HandleWebhook(ctx, payments.FakeCardName, payload, header))
+	order = reloadOrder(t, db, order)
+	assert.Equal(t, models.PaymentStatusFailed, order.PaymentStatus)
+
+	second, err := service.StartPayment(ctx, order)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Equal(t, models.PaymentStatusPending, reloadOrder(t, db, order).PaymentStatus)
+}
+
+func TestCashPayment(t *testing.T) {
+	ctx := context.Background()
+	service, _, db := setupPaymentTest(t)
+	order := createPaymentOrder(t, db, payments.CashName)
+
+	// cash orders usually have no payment until the courier collects the money
+	payment, err := service.Capture(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, payments.CashName, payment.Provider)
+	assert.Equal(t, models.PaymentStatusPaid, payment.Status)
+	assert.Equal(t, models.PaymentStatusPaid, reloadOrder(t, db, order).PaymentStatus)
+
+	t.Run("unsupported method", func(t *testing.T) {
+		other := createPaymentOrder(t, db, "bitcoin")
+		_, err := service.StartPayment(ctx, other)
+		assert.ErrorIs(t, err, ErrUnsupportedPaymentMethod)
+		assert.False(t, service.Supports("bitcoin"))
+		assert.Equal(t, []string{"card", "cash"}, service.Methods())
+	})
+}
+
+func TestManualPaymentStatus(t *testing.T) {
+	ctx := context.Background()
+	service, _, db := setupPaymentTest(t)
+	order := createPaymentOrder(t, db, payments.CashName)
+
+	require.NoError(t, service.SetOrderPaymentStatus(order.ID, models.PaymentStatusFailed))
+	assert.Equal(t, models.PaymentStatusFailed, reloadOrder(t, db, order).PaymentStatus)
+	require.NoError(t, service.SetOrderPaymentStatus(order.ID, models.PaymentStatusPending))
+
+	_, err := service.Capture(ctx, order)
+	require.NoError(t, err)
+
+	// once money is recorded the order follows its payment
+	assert.ErrorIs(t, service.SetOrderPaymentStatus(order.ID, models.PaymentStatusPending), ErrPaymentRecorded)
+	assert.Equal(t, models.PaymentStatusPaid, reloadOrder(t, db, order).PaymentStatus)
+}
+
+func TestPaymentAfterCancellation(t *testing.T) {
+	ctx := context.Background()
+	service, card, db := setupPaymentTest(t)
+	order := createPaymentOrder(t, db, payments.FakeCardName)
+
+	payment, err := service.StartPayment(ctx, order)
+	require.NoError(t, err)
+	db.Model(&models.Order{}).Where("id = ?", order.ID).Update("status", models.OrderStatusCancelled)
+
+	_, err = service.StartPayment(ctx, reloadOrder(t, db, order))
+	assert.ErrorIs(t, err, ErrOrderNotPayable)
+
+	// the customer may still finish the card form of the earlier payment
+
+	payload, header, err := card.Settle(payment.ProviderRef, payments.StatusSucceeded)
+	require.NoError(t, err)
+	require.NoError(t, service.HandleWebhook(ctx, payments.FakeCardName, payload, header))
+	assert.Equal(t, models.PaymentStatusRefundPending, reloadOrder(t, db, order).PaymentStatus)
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+var (
+	ErrEmptyCart     = errors.New("cart is empty")
+	ErrPriceMismatch = errors.New("order total does not match current prices")
+	ErrMixedCart     = errors.New("cart contains items from more than one restaurant")
+)
+
+// PriceQuote is the server-side price breakdown for a cart. Discounts come off the
+// subtotal before tax, or off the delivery fee.
+type PriceQuote struct {
+	RestaurantID   uint                   `json:"restaurant_id"`
+	Items          []models.OrderItem     `json:"items"`
+	Subtotal       float64                `json:"subtotal"`
+	Discounts      []models.OrderDiscount `json:"discounts,omitempty"`
+	DiscountAmount float64                `json:"discount_amount"`
+	TaxAmount      float64                `json:"tax_amount"`
+	DeliveryFee    float64                `json:"delivery_fee"`
+	Total          float64                `json:"total"`
+}
+
+// Apply copies the quoted items and amounts onto an order
+func (q *PriceQuote) Apply(order *models.Order) {
+	order.RestaurantID = q.RestaurantID
+	order.Items = q.Items
+	order.Subtotal = q.Subtotal
+	order.TaxAmount = q.TaxAmount
+	order.DeliveryFee = q.DeliveryFee
+	order.Discounts = q.Discounts
+	order.DiscountAmount = q.DiscountAmount
+	order.TotalAmount = q.Total
+}
+
+type PricingService interface {
+	QuoteCart(items []models.CartItem) (*PriceQuote, error)
+	QuoteMenuItems(items []models.CartItem, menuItems []models.MenuItem) (*PriceQuote, error)
+	ApplyPromotion(quote *PriceQuote, promotion *models.Promotion)
+	CheckTotal(quote *PriceQuote, clientTotal float64) error
+}
+
+type pricingService struct {
+	menuRepo repositories.MenuRepository
+	config   config.PricingConfig
+}
+
+func NewPricingService(menuRepo repositories.MenuRepository, config config.PricingConfig) PricingService {
+	return &pricingService{menuRepo: menuRepo, config: config}
+}
+
+// QuoteCart prices the cart items using the current prices of the menu items and their
+// selected options
+func (s *pricingService) QuoteCart(items []models.CartItem) (*PriceQuote, error) {
+	if len(items) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.MenuItemID)
+	}
+	menuItems, err := s.menuRepo.FindByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	return s.QuoteMenuItems(items, menuItems)
+}
+
+// QuoteMenuItems prices the cart items against menu items the caller already loaded
+// with their modifier groups
+func (s *pricingService) QuoteMenuItems(items []models.CartItem, menuItems []models.MenuItem) (*PriceQuote, error) {
+	if len(items) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	byID := make(map[uint]models.MenuItem, len(menuItems))
+	for _, menuItem := range menuItems {
+		byID[menuItem.ID] = menuItem
+	}
+
+	quote := &PriceQuote{}
+	for _, item := range items {
+		menuItem, ok := byID[item.MenuItemID]
+		if !ok {
+			return nil, fmt.Errorf("menu item %d no longer exists", item.MenuItemID)
+		}
+		// The order belongs to the restaurant of its items, never to one named by the client
+		if quote.RestaurantID == 0 {
+			quote.RestaurantID = menuItem.RestaurantID
+		} else if quote.RestaurantID != menuItem.RestaurantID {
+			return nil, ErrMixedCart
+		}
+		price := menuItem.Price
+		var options []models.OrderItemOption
+		for _, selected := range item.Options {
+			group, option, ok := findOption(&menuItem, selected.ModifierOptionID)
+			if !ok {
+				return nil, fmt.Errorf("an option of %s is no longer offered", menuItem.Name)
+			}
+			price += option.PriceDelta
+			options = append(options, models.OrderItemOption{
+				ModifierOptionID: option.ID,
+				GroupName:        group.Name,
+				Name:             option.Name,
+				PriceDelta:       option.PriceDelta,
+			})
+		}
+		price = roundMoney(price)
+		quote.Items = append(quote.Items, models.OrderItem{
+			MenuItemID: item.MenuItemID,
+			Quantity:   item.Quantity,
+			Price:      price,
+			Notes:      item.Notes,
+			Options:    options,
+		})
+		quote.Subtotal += price * float64(item.Quantity)
+	}
+
+	quote.Subtotal = roundMoney(quote.Subtotal)
+	quote.DeliveryFee = roundMoney(s.config.DeliveryFee)
+	s.total(quote)
+	return quote, nil
+}
+
+// ApplyPromotion adds the discount of a promotion to a quote and prices it again. The
+// caller checks that the promotion may be used.
+func (s *pricingService) ApplyPromotion(quote *PriceQuote, promotion *models.Promotion) {
+	discount := models.OrderDiscount{
+		PromotionID: promotion.ID,
+		Code:        promotion.Code,
+		Description: promotion.Description,
+		Target:      models.DiscountTargetItems,
+	}
+	switch promotion.Type {
+	case models.PromotionTypePercentage:
+		discount.Amount = roundMoney(quote.Subtotal * promotion.Value / 100)
+	case models.PromotionTypeFixed:
+		discount.Amount = roundMoney(min(promotion.Value, quote.Subtotal))
+	case models.PromotionTypeFreeDelivery:
+		discount.Target = models.DiscountTargetDelivery
+		discount.Amount = quote.DeliveryFee
+	}
+	quote.Discounts = append(quote.Discounts, discount)
+	s.total(quote)
+}
+
+// total sums up the discounts, tax and total of a quote
+func (s *pricingService) total(quote *PriceQuote) {
+	quote.DiscountAmount = 0
+	for _, discount := range quote.Discounts {
+		quote.DiscountAmount += discount.Amount
+	}
+	quote.DiscountAmount = roundMoney(quote.DiscountAmount)
+	quote.TaxAmount = roundMoney((quote.Subtotal - itemDiscount(quote.Discounts)) * s.config.TaxRate)
+	quote.Total = roundMoney(quote.Subtotal + quote.TaxAmount + quote.DeliveryFee - quote.DiscountAmount)
+}
+
+// itemDiscount is the part of the discounts that comes off the items rather than delivery
+func itemDiscount(discounts []models.OrderDiscount) float64 {
+	amount := 0.0
+	for _, discount := range discounts {
+		if discount.Target == models.DiscountTargetItems {
+			amount += discount.Amount
+		}
+	}
+	return amount
+}
+
+// CheckTotal rejects a client total that differs from the quote by more than the configured tolerance
+func (s *pricingService) CheckTotal(quote *PriceQuote, clientTotal float64) error {
+	// the epsilon absorbs float noise when the difference equals the tolerance
+	if math.Abs(quote.Total-clientTotal) > s.config.Tolerance+1e-9 {
+		return fmt.Errorf("%w: expected %.2f, got %.2f", ErrPriceMismatch, quote.Total, clientTotal)
+	}
+	return nil
+}
+
+// roundMoney rounds an amount to whole cents
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+func setupPricingTest(t *testing.T, cfg config.PricingConfig) (PricingService, *gorm.DB) {
+	db := newTestDB(t)
+	menuRepo := repositories.NewMenuRepository(db)
+	return NewPricingService(menuRepo, cfg), db
+}
+
+func TestQuoteCart(t *testing.T) {
+	service, db := setupPricingTest(t, config.PricingConfig{TaxRate: 0.1, DeliveryFee: 2.5, Tolerance: 0.01})
+
+	pizza := models.MenuItem{Name: "Pizza", Price: 12.99, Category: "Main", RestaurantID: 1}
+	soda := models.MenuItem{Name: "Soda", Price: 1.5, Category: "Drinks", RestaurantID: 1}
+	db.Create(&pizza)
+	db.Create(&soda)
+
+	// the stale price on the preloaded menu item must be ignored
+	items := []models.CartItem{
+		{MenuItemID: pizza.ID, Quantity: 2, MenuItem: models.MenuItem{Price: 0.01}},
+		{MenuItemID: soda.ID, Quantity: 3},
+	}
+
+	quote, err := service.QuoteCart(items)
+	assert.NoError(t, err)
+	assert.Equal(t, 30.48, quote.Subtotal)
+	assert.Equal(t, 3.05, quote.TaxAmount)
+	assert.Equal(t, 2.5, quote.DeliveryFee)
+	assert.Equal(t, 36.03, quote.Total)
+	assert.Len(t, quote.Items, 2)
+	assert.Equal(t, 12.99, quote.Items[0].Price)
+	assert.Equal(t, uint(1), quote.RestaurantID)
+
+	var order models.Order
+	quote.Apply(&order)
+	assert.Equal(t, 36.03, order.TotalAmount)
+	assert.Equal(t, 30.48, order.Subtotal)
+	assert.Equal(t, uint(1), order.RestaurantID)
+}
+
+func TestQuoteCartErrors(t *testing.T) {
+	service, db := setupPricingTest(t, config.PricingConfig{Tolerance: 0.01})
+
+	_, err := service.QuoteCart(nil)
+	assert.ErrorIs(t, err, ErrEmptyCart)
+
+	_, err = service.QuoteCart([]models.CartItem{{MenuItemID: 99, Quantity: 1}})
+	assert.Error(t, err)
+
+	burger := models.MenuItem{Name: "Burger", Price: 8, Category: "Main", RestaurantID: 1}
+	sushi := models.MenuItem{Name: "Sushi", Price: 15, Category: "Main", RestaurantID: 2}
+	db.Create(&burger)
+	db.Create(&sushi)
+
+	_, err = service.QuoteCart([]models.CartItem{
+		{MenuItemID: burger.ID, Quantity: 1},
+		{MenuItemID: sushi.ID, Quantity: 1},
+	})
+	assert.ErrorIs(t, err, ErrMixedCart)
+}
+
+func TestCheckTotal(t *testing.T) {
+	service, _ := setupPricingTest(t, config.PricingConfig{Tolerance: 0.01})
+	quote := &PriceQuote{Total: 20}
+
+	tests := []struct {
+		name        string
+		clientTotal float64
+		wantErr     bool
+	}{
+		{name: "Exact match", clientTotal: 20, wantErr: false},
+		{name: "Within tolerance", clientTotal: 20.01, wantErr: false},
+		{name: "Underpaid", clientTotal: 0.01, wantErr: true},
+		{name: "Overpaid", clientTotal: 25, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.CheckTotal(quote, tt.clientTotal)
+			assert.Equal(t, tt.wantErr, errors.Is(err, ErrPriceMismatch))
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPromotionNotFound      = errors.New("promo code does not exist")
+	ErrPromotionNotActive     = errors.New("promo code is not valid at this time")
+	ErrPromotionNotApplicable = errors.New("promo code does not apply to this restaurant")
+	ErrPromotionMinSubtotal   = errors.New("cart subtotal is below the promo code's minimum")
+	ErrPromotionUsedUp        = errors.New("promo code has reached its usage limit")
+
+	ErrInvalidPromotion   = errors.New("invalid promotion")
+	ErrPromotionCodeTaken = errors.New("a promotion with this code already exists")
+)
+
+// PromotionInput is a promotion as admins and restaurant owners create and replace it
+type PromotionInput struct {
+	Code           string     `json:"code" binding:"required,max=50"`
+	Description    string     `json:"description" binding:"max=255"`
+	Type           string     `json:"type" binding:"required,oneof=percentage fixed free_delivery"`
+	Value          float64    `json:"value" binding:"min=0"` // percent off for percentage, amount off for fixed
+	MinSubtotal    float64    `json:"min_subtotal" binding:"min=0"`
+	MaxUses        int        `json:"max_uses" binding:"min=0"`
+	MaxUsesPerUser int        `json:"max_uses_per_user" binding:"min=0"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	IsActive       *bool      `json:"is_active"` // defaults to true
+	RestaurantID   *uint      `json:"restaurant_id"`
+	CuisineID      *uint      `json:"cuisine_id"`
+}
+
+type PromotionService struct {
+	repo           repositories.PromotionRepository
+	restaurantRepo repositories.RestaurantRepository
+}
+
+func NewPromotionService(repo repositories.PromotionRepository, restaurantRepo repositories.RestaurantRepository) PromotionService {
+	return PromotionService{repo: repo, restaurantRepo: restaurantRepo}
+}
+
+// GetPromotions lists every promotion for admins and the promotions of their own
+// restaurants for anyone else
+func (s *PromotionService) GetPromotions(actor *models.User) ([]models.Promotion, error) {
+	if actor.Role == models.RoleAdmin {
+		return s.repo.FindAll()
+	}
+	return s.repo.FindOwnedBy(actor.ID)
+}
+
+// CreatePromotion adds a promotion. Only admins may create promotions that are not
+// limited to one restaurant.
+func (s *PromotionService) CreatePromotion(actor *models.User, input PromotionInput) (*models.Promotion, error) {
+	promotion := &models.Promotion{CreatedByID: actor.ID, IsActive: true}
+	if err := s.fill(promotion, input); err != nil {
+		return nil, err
+	}
+	if err := policy.Authorize(actor, policy.ActionCreate, promotion); err != nil {
+		return nil, err
+	}
+	if err := s.checkCodeFree(promotion.Code, 0); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(promotion); err != nil {
+		return nil, codeTaken(err)
+	}
+	return promotion, nil
+}
+
+// UpdatePromotion replaces a promotion with input
+func (s *PromotionService) UpdatePromotion(actor *models.User, id uint, input PromotionInput) (*models.Promotion, error) {
+	promotion, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.Authorize(actor, policy.ActionUpdate, promotion); err != nil {
+		return nil, err
+	}
+	// moving it to another restaurant needs the same rights there
+	if err := s.fill(promotion, input); err != nil {
+		return nil, err
+	}
+	if err := policy.Authorize(actor, policy.ActionUpdate, promotion); err != nil {
+		return nil, err
+	}
+	if err := s.checkCodeFree(promotion.Code, promotion.ID); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Update(promotion.ID, map[string]any{
+		"code":              promotion.Code,
+		"description":       promotion.Description,
+		"type":              promotion.Type,
+		"value":             promotion.Value,
+		"min_subtotal":      promotion.MinSubtotal,
+		"max_uses":          promotion.MaxUses,
+		"max_uses_per_user": promotion.MaxUsesPerUser,
+		"starts_at":         promotion.StartsAt,
+		"ends_at":           promotion.EndsAt,
+		"is_active":         promotion.IsActive,
+		"restaurant_id":     promotion.RestaurantID,
+		"cuisine_id":        promotion.CuisineID,
+	})
+	if err != nil {
+		return nil, codeTaken(err)
+	}
+	return s.repo.FindByID(promotion.ID)
+}
+
+// DeletePromotion removes a promotion. Orders keep their discount lines. Carts it was
+// applied to cannot be checked out until the customer takes the code off.
+func (s *PromotionService) DeletePromotion(actor *models.User, id uint) error {
+	promotion, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := policy.Authorize(actor, policy.ActionDelete, promotion); err != nil {
+		return err
+	}
+	return s.repo.Delete(promotion.ID)
+}
+
+// fill copies input onto promotion, loading the restaurant the policy checks against
+func (s *PromotionService) fill(promotion *models.Promotion, input PromotionInput) error {
+	switch {
+	case input.Type == models.PromotionTypePercentage && (input.Value <= 0 || input.Value > 100):
+		return fmt.Errorf("%w: a percentage must be above 0 and at most 100", ErrInvalidPromotion)
+	case input.Type == models.PromotionTypeFixed && input.Value <= 0:
+		return fmt.Errorf("%w: a fixed discount must be above 0", ErrInvalidPromotion)
+	case input.StartsAt != nil && input.EndsAt != nil && !input.EndsAt.After(*input.StartsAt):
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromotion)
+	}
+
+	promotion.Code = normalizePromoCode(input.Code)
+	if promotion.Code == "" {
+		return fmt.Errorf("%w: code must not be blank", ErrInvalidPromotion)
+	}
+	promotion.Description = strings.TrimSpace(input.Description)
+	promotion.Type = input.Type
+	promotion.Value = input.Value
+	if input.Type == models.PromotionTypeFreeDelivery {
+		promotion.Value = 0
+	}
+	promotion.MinSubtotal = input.MinSubtotal
+	promotion.MaxUses = input.MaxUses
+	promotion.MaxUsesPerUser = input.MaxUsesPerUser
+	promotion.StartsAt = input.StartsAt
+	promotion.EndsAt = input.EndsAt
+	if input.IsActive != nil {
+		promotion.IsActive = *input.IsActive
+	}
+	promotion.CuisineID = input.CuisineID
+
+	promotion.RestaurantID = input.RestaurantID
+	promotion.Restaurant = nil
+	if input.RestaurantID != nil {
+		restaurant, err := s.restaurantRepo.FindByID(*input.RestaurantID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: restaurant %d does not exist", ErrInvalidPromotion, *input.RestaurantID)
+		}
+		if err != nil {
+			return err
+		}
+		promotion.Restaurant = restaurant
+	}
+	return nil
+}
+
+// checkCodeFree gives a clear error for a code in use before anything is written; the
+// unique index on codes still catches promotions saved at the same time
+func (s *PromotionService) checkCodeFree(code string, id uint) error {
+	existing, err := s.repo.FindByCode(code)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != id {
+		return ErrPromotionCodeTaken
+	}
+	return nil
+}
+
+// codeTaken reports a unique index violation on promotion codes as ErrPromotionCodeTaken
+func codeTaken(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrPromotionCodeTaken
+	}
+	return err
+}
+
+// normalizePromoCode makes codes case insensitive
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// checkPromotion reports whether a customer may use a promotion on a priced cart from
+// restaurant, which must have its cuisines loaded. Uses are counted with repo so that
+// checkout can count inside its transaction.
+func checkPromotion(repo *repositories.PromotionRepository, promotion *models.Promotion, restaurant *models.Restaurant, userID uint, quote *PriceQuote) error {
+	now := time.Now()
+	if !promotion.IsActive || (promotion.StartsAt != nil && now.Before(*promotion.StartsAt)) ||
+		(promotion.EndsAt != nil && !now.Before(*promotion.EndsAt)) {
+		return ErrPromotionNotActive
+	}
+	if promotion.RestaurantID != nil && *promotion.RestaurantID != restaurant.ID {
+		return ErrPromotionNotApplicable
+	}
+	if promotion.CuisineID != nil && !servesCuisine(restaurant, *promotion.CuisineID) {
+		return ErrPromotionNotApplicable
+	}
+	if quote.Subtotal < promotion.MinSubtotal {
+		return fmt.Errorf("%w of %.2f", ErrPromotionMinSubtotal, promotion.MinSubtotal)
+	}
+
+	if promotion.MaxUses > 0 {
+		uses, err := repo.CountUses(promotion.ID, nil)
+		if err != nil {
+			return err
+		}
+		if uses >= int64(promotion.MaxUses) {
+			return ErrPromotionUsedUp
+		}
+	}
+	if promotion.MaxUsesPerUser > 0 {
+		uses, err := repo.CountUses(promotion.ID, &userID)
+		if err != nil {
+			return err
+		}
+		if uses >= int64(promotion.MaxUsesPerUser) {
+			return ErrPromotionUsedUp
+		}
+	}
+	return nil
+}
+
+func servesCuisine(restaurant *models.Restaurant, cuisineID uint) bool {
+	for _, cuisine := range restaurant.Cuisines {
+		if cuisine.ID == cuisineID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/policy"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+type promotionTest struct {
+	db         *gorm.DB
+	promotions PromotionService
+	carts      CartService
+	orders     OrderService
+	restaurant models.Restaurant
+	pizza      models.MenuItem
+}
+
+// setupPromotionTest prices with 10% tax and a 3.00 delivery fee, and sells pizza at 10.00
+func setupPromotionTest(t *testing.T) *promotionTest {
+	db := newTestDB(t)
+	orderRepo := repositories.NewOrderRepository(db)
+	menuRepo := repositories.NewMenuRepository(db)
+	cartRepo := repositories.NewCartRepository(db)
+	restaurantRepo := repositories.NewRestaurantRepository(db)
+	promotionRepo := repositories.NewPromotionRepository(db)
+	pricing := NewPricingService(menuRepo, config.PricingConfig{TaxRate: 0.1, DeliveryFee: 3, Tolerance: 0.01})
+
+	ownerID := uint(9)
+	test := &promotionTest{
+		db:         db,
+		promotions: NewPromotionService(promotionRepo, restaurantRepo),
+		carts:      NewCartService(cartRepo, menuRepo, promotionRepo, restaurantRepo, pricing),
+		orders:     NewOrderService(orderRepo, menuRepo, cartRepo, restaurantRepo, promotionRepo, pricing, NewLogNotifier()),
+		restaurant: models.Restaurant{Name: "Pizza Place", Address: "Main St", Phone: "123", Email: "pizza@example.com", UserID: &ownerID},
+	}
+	require.NoError(t, db.Create(&test.restaurant).Error)
+	test.pizza = models.MenuItem{Name: "Pizza", Price: 10, Category: "Main", RestaurantID: test.restaurant.ID}
+	require.NoError(t, db.Create(&test.pizza).Error)
+	return test
+}
+
+func (test *promotionTest) create(t *testing.T, input PromotionInput) *models.Promotion {
+	promotion, err := test.promotions.CreatePromotion(&models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleAdmin}, input)
+	require.NoError(t, err)
+	return promotion
+}
+
+func (test *promotionTest) fillCart(t *testing.T, userID uint, quantity int) {
+	require.NoError(t, test.carts.AddToCart(userID, CartItemInput{MenuItemID: test.pizza.ID, Quantity: quantity}, false))
+}
+
+func TestPromotionDiscounts(t *testing.T) {
+	test := setupPromotionTest(t)
+	test.create(t, PromotionInput{Code: "half", Type: models.PromotionTypePercentage, Value: 50})
+	test.create(t, PromotionInput{Code: "FIVE", Type: models.PromotionTypeFixed, Value: 5})
+	test.create(t, PromotionInput{Code: "BIG", Type: models.PromotionTypeFixed, Value: 100})
+	test.create(t, PromotionInput{Code: "SHIP", Type: models.PromotionTypeFreeDelivery})
+
+	userID := uint(7)
+	test.fillCart(t, userID, 2)
+
+	tests := []struct {
+		code     string
+		discount float64
+		tax      float64
+		total    float64
+	}{
+		{" Half ", 10, 1, 14},  // 20 - 10 + 1 tax + 3 delivery
+		{"five", 5, 1.5, 19.5}, // 20 - 5 + 1.5 tax + 3 delivery
+		{"big", 20, 0, 3},      // never more than the items cost
+		{"ship", 3, 2, 22},     // 20 + 2 tax, delivery is free
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			quote, err := test.carts.ApplyPromotion(userID, tt.code)
+			require.NoError(t, err)
+			assert.Equal(t, 20.0, quote.Subtotal)
+			assert.Equal(t, tt.discount, quote.DiscountAmount)
+			assert.Equal(t, tt.tax, quote.TaxAmount)
+			assert.Equal(t, tt.total, quote.Total)
+			require.Len(t, quote.Discounts, 1)
+		})
+	}
+
+	_, err := test.carts.ApplyPromotion(userID, "NOPE")
+	assert.ErrorIs(t, err, ErrPromotionNotFound)
+}
+
+func TestPromotionConditions(t *testing.T) {
+	test := setupPromotionTest(t)
+	userID := uint(7)
+	test.fillCart(t, userID, 1)
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	inactive := false
+
+	t.Run("Minimum subtotal", func(t *testing.T) {
+		test.create(t, PromotionInput{Code: "MIN", Type: models.PromotionTypeFixed, Value: 2, MinSubtotal: 15})
+		_, err := test.carts.ApplyPromotion(userID, "MIN")
+		assert.ErrorIs(t, err, ErrPromotionMinSubtotal)
+	})
+
+	t.Run("Validity window", func(t *testing.T) {
+		test.create(t, PromotionInput{Code: "LATER", Type: models.PromotionTypeFixed, Value: 2, StartsAt: &future})
+		_, err := test.carts.ApplyPromotion(userID, "LATER")
+		assert.ErrorIs(t, err, ErrPromotionNotActive)
+
+		test.create(t, PromotionInput{Code: "OVER", Type: models.PromotionTypeFixed, Value: 2, EndsAt: &past})
+		_, err = test.carts.ApplyPromotion(userID, "OVER")
+		assert.ErrorIs(t, err, ErrPromotionNotActive)
+	})
+
+	t.Run("Inactive", func(t *testing.T) {
+		promotion := test.create(t, PromotionInput{Code: "OFF", Type: models.PromotionTypeFixed, Value: 2, IsActive: &inactive})
+		var stored models.Promotion
+		require.NoError(t, test.db.First(&stored, promotion.ID).Error)
+		assert.False(t, stored.IsActive)
+
+		_, err := test.carts.ApplyPromotion(userID, "OFF")
+		assert.ErrorIs(t, err, ErrPromotionNotActive)
+	})
+
+	t.Run("Restaurant scope", func(t *testing.T) {
+		other := models.Restaurant{Name: "Sushi Bar", Address: "Side St", Phone: "456", Email: "sushi@example.com"}
+		require.NoError(t, test.db.Create(&other).Error)
+		test.create(t, PromotionInput{Code: "SUSHI", Type: models.PromotionTypeFixed, Value: 2, RestaurantID: &other.ID})
+		test.create(t, PromotionInput{Code: "PIZZA", Type: models.PromotionTypeFixed, Value: 2, RestaurantID: &test.restaurant.ID})
+
+		_, err := test.carts.ApplyPromotion(userID, "SUSHI")
+		assert.ErrorIs(t, err, ErrPromotionNotApplicable)
+		_, err = test.carts.ApplyPromotion(userID, "PIZZA")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Cuisine scope", func(t *testing.T) {
+		italian := models.Cuisine{Name: "Italian"}
+		japanese := models.Cuisine{Name: "Japanese"}
+		require.NoError(t, test.db.Create(&italian).Error)
+		require.NoError(t, test.db.Create(&japanese).Error)
+		require.NoError(t, test.db.Model(&test.restaurant).Association("Cuisines").Append(&italian))
+		test.create(t, PromotionInput{Code: "RAMEN", Type: models.PromotionTypeFixed, Value: 2, CuisineID: &japanese.ID})
+		test.create(t, PromotionInput{Code: "PASTA", Type: models.PromotionTypeFixed, Value: 2, CuisineID: &italian.ID})
+
+		_, err := test.carts.ApplyPromotion(userID, "RAMEN")
+		assert.ErrorIs(t, err, ErrPromotionNotApplicable)
+		_, err = test.carts.ApplyPromotion(userID, "PASTA")
+		assert.NoError(t, err)
+	})
+}
+
+func TestCheckoutWithPromotion(t *testing.T) {
+	test := setupPromotionTest(t)
+	promotion := test.create(t, PromotionInput{Code: "FIVE", Type: models.PromotionTypeFixed, Value: 5, MaxUses: 2, MaxUsesPerUser: 1})
+
+	checkout := func(userID uint) (*models.Order, error) {
+		test.fillCart(t, userID, 2)
+		if _, err := test.carts.ApplyPromotion(userID, "FIVE"); err != nil {
+			cart, cartErr := test.carts.GetUserCart(userID)
+			require.NoError(t, cartErr)
+			require.NoError(t, test.carts.ClearCart(cart.ID))
+			return nil, err
+		}
+		order, _, err := test.orders.Checkout(CheckoutInput{UserID: userID, DeliveryAddress: "1 Test Rd", PaymentMethod: "cash", ClientTotal: 19.5})
+		return order, err
+	}
+
+	order, err := checkout(7)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, order.DiscountAmount)
+	assert.Equal(t, 1.5, order.TaxAmount)
+	assert.Equal(t, 19.5, order.TotalAmount)
+
+	stored, err := test.orders.GetOrder(order.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Discounts, 1)
+	assert.Equal(t, promotion.ID, stored.Discounts[0].PromotionID)
+	assert.Equal(t, "FIVE", stored.Discounts[0].Code)
+	assert.Equal(t, 5.0, stored.Discounts[0].Amount)
+
+	cart, err := test.carts.GetUserCart(7)
+	require.NoError(t, err)
+	assert.Nil(t, cart.PromotionID, "checkout takes the code off the cart")
+
+	_, err = checkout(7)
+	assert.ErrorIs(t, err, ErrPromotionUsedUp, "once per customer")
+
+	_, err = checkout(8)
+	require.NoError(t, err)
+	_, err = checkout(10)
+	assert.ErrorIs(t, err, ErrPromotionUsedUp, "twice in total")
+
+	t.Run("Cancelled orders give the use back", func(t *testing.T) {
+		require.NoError(t, test.db.Model(order).Update("status", models.OrderStatusCancelled).Error)
+		_, err := checkout(10)
+		assert.NoError(t, err)
+	})
+}
+
+func TestPromotionPermissions(t *testing.T) {
+	test := setupPromotionTest(t)
+	owner := &models.User{BaseModel: models.BaseModel{ID: 9}, Role: models.RoleRestaurantOwner}
+	stranger := &models.User{BaseModel: models.BaseModel{ID: 11}, Role: models.RoleRestaurantOwner}
+
+	_, err := test.promotions.CreatePromotion(owner, PromotionInput{Code: "ALL", Type: models.PromotionTypeFreeDelivery})
+	assert.ErrorIs(t, err, policy.ErrForbidden, "owners cannot create promotions for every restaurant")
+
+	input := PromotionInput{Code: "OWN", Type: models.PromotionTypeFixed, Value: 2, RestaurantID: &test.restaurant.ID}
+	_, err = test.promotions.CreatePromotion(stranger, input)
+	assert.ErrorIs(t, err, policy.ErrForbidden)
+
+	promotion, err := test.promotions.CreatePromotion(owner, input)
+	require.NoError(t, err)
+
+	_, err = test.promotions.CreatePromotion(owner, PromotionInput{Code: "own", Type: models.PromotionTypeFixed, Value: 3, RestaurantID: &test.restaurant.ID})
+	assert.ErrorIs(t, err, ErrPromotionCodeTaken)
+
+	input.Value = 4
+	updated, err := test.promotions.UpdatePromotion(owner, promotion.ID, input)
+	require.NoError(t, err)
+	assert.Equal(t, 4.0, updated.Value)
+	_, err = test.promotions.UpdatePromotion(stranger, promotion.ID, input)
+	assert.ErrorIs(t, err, policy.ErrForbidden)
+
+	mine, err := test.promotions.GetPromotions(owner)
+	require.NoError(t, err)
+	assert.Len(t, mine, 1)
+	theirs, err := test.promotions.GetPromotions(stranger)
+	require.NoError(t, err)
+	assert.Empty(t, theirs)
+
+	assert.ErrorIs(t, test.promotions.DeletePromotion(stranger, promotion.ID), policy.ErrForbidden)
+	assert.NoError(t, test.promotions.DeletePromotion(owner, promotion.ID))
+}
+
+func TestPromotionCodesAreUnique(t *testing.T) {
+	test := setupPromotionTest(t)
+	repo := repositories.NewPromotionRepository(test.db)
+	promotion := test.create(t, PromotionInput{Code: "ONCE", Type: models.PromotionTypeFreeDelivery})
+
+	// the index catches promotions saved at the same time, after both passed the code check
+	err := repo.Create(&models.Promotion{Code: "ONCE", Type: models.PromotionTypeFreeDelivery})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	assert.ErrorIs(t, codeTaken(err), ErrPromotionCodeTaken)
+
+	other := test.create(t, PromotionInput{Code: "OTHER", Type: models.PromotionTypeFreeDelivery})
+	assert.ErrorIs(t, repo.Update(other.ID, map[string]any{"code": "ONCE"}), gorm.ErrDuplicatedKey)
+
+	admin := &models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleAdmin}
+	require.NoError(t, test.promotions.DeletePromotion(admin, promotion.ID))
+	test.create(t, PromotionInput{Code: "ONCE", Type: models.PromotionTypeFreeDelivery})
+}
+
+func TestCheckoutWithUnusablePromotion(t *testing.T) {
+	test := setupPromotionTest(t)
+	admin := &models.User{BaseModel: models.BaseModel{ID: 1}, Role: models.RoleAdmin}
+	promotion := test.create(t, PromotionInput{Code: "FIVE", Type: models.PromotionTypeFixed, Value: 5})
+
+	userID := uint(7)
+	test.fillCart(t, userID, 2)
+	_, err := test.carts.ApplyPromotion(userID, "FIVE")
+	require.NoError(t, err)
+	require.NoError(t, test.promotions.DeletePromotion(admin, promotion.ID))
+
+	input := CheckoutInput{UserID: userID, DeliveryAddress: "1 Test Rd", PaymentMethod: "cash", ClientTotal: 19.5}
+	_, _, err = test.orders.Checkout(input)
+	var checkoutErr *CheckoutError
+	require.ErrorAs(t, err, &checkoutErr)
+	assert.Equal(t, CheckoutPromotionUnavailable, checkoutErr.Code)
+	assert.ErrorIs(t, err, ErrPromotionNotFound)
+
+	// without the code the order goes through at the full price
+	require.NoError(t, test.carts.RemovePromotion(userID))
+	input.ClientTotal = 25
+	order, _, err := test.orders.Checkout(input)
+	require.NoError(t, err)
+	assert.Zero(t, order.DiscountAmount)
+}
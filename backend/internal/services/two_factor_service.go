@@ -0,0 +1,219 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets at a time
+const recoveryCodeCount = 10
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotStarted     = errors.New("two-factor setup has not been started")
+	ErrTwoFactorRequiredByRole = errors.New("two-factor authentication is required for your role")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+// TOTPEnrollment is what a user needs to add the account to an authenticator app
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_url"`
+}
+
+// TwoFactorStatus describes a user's two-factor setup
+type TwoFactorStatus struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+type TwoFactorService interface {
+	GetStatus(user *models.User) (*TwoFactorStatus, error)
+	// BeginEnrollment creates a new secret. It is not used for logins until confirmed.
+	BeginEnrollment(user *models.User) (*TOTPEnrollment, error)
+	// ConfirmEnrollment checks a code from the app, enables two-factor authentication
+	// and returns the recovery codes, which are only shown this once
+	ConfirmEnrollment(user *models.User, code string) ([]string, error)
+	// Disable turns two-factor authentication off after checking a code
+	Disable(user *models.User, code string) error
+	// RegenerateRecoveryCodes replaces the recovery codes after checking a code
+	RegenerateRecoveryCodes(user *models.User, code string) ([]string, error)
+	// VerifyCode accepts a current app code or an unused recovery code
+	VerifyCode(userID uint, code string) error
+	// Required reports whether the user's role must use two-factor authentication
+	Required(user *models.User) bool
+}
+
+type twoFactorService struct {
+	repo repositories.TwoFactorRepository
+	cfg  config.TwoFactorConfig
+}
+
+func NewTwoFactorService(repo repositories.TwoFactorRepository, cfg config.TwoFactorConfig) TwoFactorService {
+	return &twoFactorService{repo: repo, cfg: cfg}
+}
+
+func (s *twoFactorService) Required(user *models.User) bool {
+	for _, role := range s.cfg.RequiredRoles {
+		if role == user.Role {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *twoFactorService) GetStatus(user *models.User) (*TwoFactorStatus, error) {
+	status := &TwoFactorStatus{Enabled: user.TwoFactorEnabled, Required: s.Required(user)}
+	if user.TwoFactorEnabled {
+		remaining, err := s.repo.CountRecoveryCodes(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		status.RecoveryCodesRemaining = remaining
+	}
+	return status, nil
+}
+
+func (s *twoFactorService) BeginEnrollment(user *models.User) (*TOTPEnrollment, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveSecret(&models.TOTPSecret{UserID: user.ID, Secret: secret}); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Secret: secret, URI: totpURI(s.cfg.Issuer, user.Email, secret)}, nil
+}
+
+func (s *twoFactorService) ConfirmEnrollment(user *models.User, code string) ([]string, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	secret, err := s.repo.FindSecret(user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTwoFactorNotStarted
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.useTOTP(secret, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashed, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Enable(secret, hashed); err != nil {
+		return nil, err
+	}
+	user.TwoFactorEnabled = true
+	return codes, nil
+}
+
+func (s *twoFactorService) Disable(user *models.User, code string) error {
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if s.Required(user) {
+		return ErrTwoFactorRequiredByRole
+	}
+	if err := s.VerifyCode(user.ID, code); err != nil {
+		return err
+	}
+	if err := s.repo.Disable(user.ID); err != nil {
+		return err
+	}
+	user.TwoFactorEnabled = false
+	return nil
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(user *models.User, code string) ([]string, error) {
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.VerifyCode(user.ID, code); err != nil {
+		return nil, err
+	}
+	codes, hashed, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(user.ID, hashed); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *twoFactorService) VerifyCode(userID uint, code string) error {
+	secret, err := s.repo.FindSecret(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && secret.ConfirmedAt == nil) {
+		return ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(strings.TrimSpace(code)) == totpDigits {
+		return s.useTOTP(secret, code)
+	}
+	used, err := s.repo.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// useTOTP checks an app code and records its time step so it cannot be used twice
+func (s *twoFactorService) useTOTP(secret *models.TOTPSecret, code string) error {
+	step, ok := matchTOTP(secret.Secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	fresh, err := s.repo.UseStep(secret.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// newRecoveryCodes returns readable codes like "k3v9q-7hx2m" and their hashed records
+func newRecoveryCodes(userID uint) ([]string, []models.RecoveryCode, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, recoveryCodeCount)
+	hashed := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		for j, b := range buf {
+			buf[j] = alphabet[int(b)%len(alphabet)]
+		}
+		codes[i] = string(buf[:5]) + "-" + string(buf[5:])
+		hashed[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(codes[i]))}
+	}
+	return codes, hashed, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
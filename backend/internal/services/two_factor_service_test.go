@@ -0,0 +1,168 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA1, truncated to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"} {
+		code, err := totpCode(secret, totpStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, "time %d", unix)
+	}
+
+	now := time.Unix(1111111109, 0)
+	previous, _ := totpCode(secret, totpStep(now)-1)
+	_, ok := matchTOTP(secret, previous, now)
+	assert.True(t, ok, "codes from the previous period are accepted")
+	old, _ := totpCode(secret, totpStep(now)-2)
+	_, ok = matchTOTP(secret, old, now)
+	assert.False(t, ok)
+}
+
+func currentCode(t *testing.T, secret string, offset int64) string {
+	code, err := totpCode(secret, totpStep(time.Now())+offset)
+	require.NoError(t, err)
+	return code
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	service, db := setupUserTest(t)
+	twoFactor := NewTwoFactorService(repositories.NewTwoFactorRepository(db), config.TwoFactorConfig{Issuer: "Foodie", RequiredRoles: []string{models.RoleAdmin}})
+	var user models.User
+	db.Where("email = ?", "jane@example.com").First(&user)
+
+	enrollment, err := twoFactor.BeginEnrollment(&user)
+	require.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/Foodie:jane@example.com?")
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+	// not enforced before it is confirmed
+	_, _, err = service.LoginUser("jane@example.com", "secret123", testClient)
+	require.NoError(t, err)
+
+	_, err = twoFactor.ConfirmEnrollment(&user, "000000")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	recoveryCodes, err := twoFactor.ConfirmEnrollment(&user, currentCode(t, enrollment.Secret, -1))
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, recoveryCodeCount)
+
+	_, _, err = service.LoginUser("jane@example.com", "secret123", testClient)
+	var challengeErr *TwoFactorRequiredError
+	require.ErrorAs(t, err, &challengeErr)
+
+	t.Run("Challenge is not an access token", func(t *testing.T) {
+		_, err := service.VerifyAccessToken(challengeErr.ChallengeToken)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("Codes cannot be replayed", func(t *testing.T) {
+		_, _, err := service.CompleteTwoFactorLogin(challengeErr.ChallengeToken, currentCode(t, enrollment.Secret, -1), testClient)
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	})
+
+	t.Run("Complete with an app code", func(t *testing.T) {
+		access, _, err := service.CompleteTwoFactorLogin(challengeErr.ChallengeToken, currentCode(t, enrollment.Secret, 0), testClient)
+		require.NoError(t, err)
+		_, err = service.VerifyAccessToken(access)
+		assert.NoError(t, err)
+
+		// each challenge completes once
+		_, _, err = service.CompleteTwoFactorLogin(challengeErr.ChallengeToken, recoveryCodes[0], testClient)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("Complete with a recovery code", func(t *testing.T) {
+		_, _, err := service.LoginUser("jane@example.com", "secret123", testClient)
+		require.ErrorAs(t, err, &challengeErr)
+		_, _, err = service.CompleteTwoFactorLogin(challengeErr.ChallengeToken, " "+recoveryCodes[0]+" ", testClient)
+		require.NoError(t, err)
+
+		_, _, err = service.LoginUser("jane@example.com", "secret123", testClient)
+		require.ErrorAs(t, err, &challengeErr)
+		_, _, err = service.CompleteTwoFactorLogin(challengeErr.ChallengeToken, recoveryCodes[0], testClient)
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorCode, "recovery codes are single use")
+
+		status, err := twoFactor.GetStatus(&user)
+		require.NoError(t, err)
+		assert.Equal(t, int64(recoveryCodeCount-1), status.RecoveryCodesRemaining)
+	})
+
+	t.Run("Racing completions start one session", func(t *testing.T) {
+		_, _, err := service.LoginUser("jane@example.com", "secret123", testClient)
+		require.ErrorAs(t, err, &challengeErr)
+
+		// different codes so every request gets past the code check
+		codes := recoveryCodes[3:6]
+		errs := make(chan error, len(codes))
+		var wg sync.WaitGroup
+		for _, code := range codes {
+			wg.Add(1)
+			go func(code string) {
+				defer wg.Done()
+				_, _, err := service.CompleteTwoFactorLogin(challengeErr.ChallengeToken, code, testClient)
+				errs <- err
+			}(code)
+		}
+		wg.Wait()
+		close(errs)
+
+		succeeded := 0
+		for err := range errs {
+			if err == nil {
+				succeeded++
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+			}
+		}
+		assert.Equal(t, 1, succeeded)
+	})
+
+	t.Run("Required roles cannot disable it", func(t *testing.T) {
+		admin := user
+		admin.Role = models.RoleAdmin
+		assert.True(t, twoFactor.Required(&admin))
+		assert.ErrorIs(t, twoFactor.Disable(&admin, recoveryCodes[1]), ErrTwoFactorRequiredByRole)
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		require.NoError(t, twoFactor.Disable(&user, recoveryCodes[2]))
+		_, _, err := service.LoginUser("jane@example.com", "secret123", testClient)
+		assert.NoError(t, err)
+	})
+}
+
+func TestTwoFactorWrongCodesLockAccount(t *testing.T) {
+	service, db := setupUserTest(t)
+	twoFactor := NewTwoFactorService(repositories.NewTwoFactorRepository(db), config.TwoFactorConfig{Issuer: "Foodie"})
+	var user models.User
+	db.Where("email = ?", "jane@example.com").First(&user)
+	enrollment, err := twoFactor.BeginEnrollment(&user)
+	require.NoError(t, err)
+	_, err = twoFactor.ConfirmEnrollment(&user, currentCode(t, enrollment.Secret, 0))
+	require.NoError(t, err)
+
+	// the right password must not clear the failures of wrong codes
+	for i := 0; i < 3; i++ {
+		_, _, err := service.LoginUser("jane@example.com", "secret123", testClient)
+		var challengeErr *TwoFactorRequiredError
+		require.ErrorAs(t, err, &challengeErr, "attempt %d", i+1)
+		_, _, err = service.CompleteTwoFactorLogin(challengeErr.ChallengeToken, "000000", testClient)
+		require.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	}
+
+	_, _, err = service.LoginUser("jane@example.com", "secret123", testClient)
+	var lockedErr *LoginLockedError
+	assert.ErrorAs(t, err, &lockedErr)
+}
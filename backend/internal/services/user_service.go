@@ -1,8 +1,13 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -16,39 +21,125 @@ import (
 const (
 	accessTokenExpiry  = time.Hour * 24 * 7  // 7 days
 	refreshTokenExpiry = time.Hour * 24 * 30 // 30 days
+	// how long a user has to enter their two-factor code after the password
+	twoFactorChallengeExpiry = 5 * time.Minute
 )
 
+// Token types, stored in the "typ" claim so one kind of token cannot stand in for the other
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// TokenTypeTwoFactorChallenge proves the password was correct; it is exchanged
+	// for an access and refresh token together with a two-factor code
+	TokenTypeTwoFactorChallenge = "2fa_challenge"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrTokenReused means a refresh token was presented after it had been rotated,
+	// which is treated as theft: the whole session is revoked
+	ErrTokenReused     = errors.New("refresh token reuse detected, please log in again")
+	ErrWrongPassword   = errors.New("current password is incorrect")
+	ErrAccountDisabled = errors.New("account is deactivated")
+	ErrInvalidRole     = errors.New("invalid role")
+)
+
+// TwoFactorRequiredError is returned by LoginUser when the password was right but
+// the account needs a second factor. ChallengeToken is passed to CompleteTwoFactorLogin.
+type TwoFactorRequiredError struct {
+	ChallengeToken string
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	return "two-factor authentication code required"
+}
+
+// roles lists the roles an admin can give a user
+var roles = map[string]bool{
+	models.RoleCustomer:        true,
+	models.RoleRestaurantOwner: true,
+	models.RoleRestaurantStaff: true,
+	models.RoleAdmin:           true,
+	models.RoleModerator:       true,
+}
+
 type UserService interface {
 	RegisterUser(name, email, password, phone string) error
-	LoginUser(email, password string) (string, string, error)
+	// LoginUser checks the credentials and returns an access and refresh token. Repeated
+	// failures lock the account or client out with a *LoginLockedError.
+	LoginUser(email, password string, client LoginClient) (string, string, error)
+	// LoginAuthenticatedUser starts a session for a user authenticated by other means,
+	// such as an OpenID provider. It returns the same errors as LoginUser.
+	LoginAuthenticatedUser(user *models.User) (string, string, error)
+	// CompleteTwoFactorLogin finishes a login that returned a *TwoFactorRequiredError
+	CompleteTwoFactorLogin(challengeToken, code string, client LoginClient) (string, string, error)
+	// RefreshTokens rotates a refresh token, returning a new access and refresh token
+	RefreshTokens(refreshToken string) (string, string, error)
+	// Logout revokes the access token and, if given, the session of the refresh token
+	Logout(claims *JWTCustomClaims, refreshToken string) error
 	GetUserById(id uint) (*models.PublicUser, error)
 	GetUserByEmail(email string) (*models.PublicUser, error)
 	VerifyToken(token string) (*JWTCustomClaims, error)
+	// VerifyAccessToken verifies a token and checks that it is an access token that was not revoked
+	VerifyAccessToken(token string) (*JWTCustomClaims, error)
 	GetAllUsers() ([]models.PublicUser, error)
 	UpdateUser(id uint, name, email, phone string) error
+	// ChangePassword sets a new password after checking the current one and ends every session
+	ChangePassword(id uint, oldPassword, newPassword string) error
+	// SetUserActive deactivates or reactivates an account. Deactivating ends every session.
+	SetUserActive(id uint, active bool) error
+	// ChangeRole sets a user's role and ends their sessions, so new tokens carry the new role
+	ChangeRole(id uint, role string) error
+	// RevokeSessions logs a user out everywhere
+	RevokeSessions(id uint) error
 }
 
-var jwtSecret = []byte("ABC1234567890")
-
 type JWTCustomClaims struct {
 	jwt.RegisteredClaims
-	Role  string `json:"role"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Id    uint   `json:"id"`
+	Role      string `json:"role"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Id        uint   `json:"id"`
+	TokenType string `json:"typ"`
+	// FamilyID groups the refresh tokens of one login session
+	FamilyID string `json:"fam,omitempty"`
+	// Version is the user's TokenVersion when the token was issued
+	Version uint `json:"ver,omitempty"`
 }
 
 type userService struct {
-	userRepo repositories.UserRepository
+	userRepo  repositories.UserRepository
+	tokenRepo repositories.TokenRepository
+	keys      *KeySet
+	guard     *LoginGuard
+	twoFactor TwoFactorService
+	hasher    PasswordHasher
 }
 
-func NewUserService(repo repositories.UserRepository) UserService {
-	return &userService{userRepo: repo}
+func NewUserService(
+	repo repositories.UserRepository,
+	tokenRepo repositories.TokenRepository,
+	keys *KeySet,
+	guard *LoginGuard,
+	twoFactor TwoFactorService,
+	hasher PasswordHasher,
+) UserService {
+	return &userService{userRepo: repo, tokenRepo: tokenRepo, keys: keys, guard: guard, twoFactor: twoFactor, hasher: hasher}
 }
 
-// Password Hashing and Verification
-func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+// PasswordHasher hashes new passwords with bcrypt at the configured cost
+type PasswordHasher struct {
+	cost int
+}
+
+func NewPasswordHasher(cost int) PasswordHasher {
+	return PasswordHasher{cost: cost}
+}
+
+// Hash returns the bcrypt hash of a password
+func (h PasswordHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
 	return string(bytes), err
 }
 
@@ -57,9 +148,40 @@ func checkPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
+// newRandomToken returns a random token for links sent by email. Only its hash is stored.
+func newRandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// setPassword stores a new password and ends every session of the user
+func setPassword(hasher PasswordHasher, userRepo repositories.UserRepository, tokenRepo repositories.TokenRepository, userID uint, password string) error {
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	return storePassword(userRepo, tokenRepo, userID, hashedPassword)
+}
+
+// storePassword saves an already hashed password and ends every session of the user
+func storePassword(userRepo repositories.UserRepository, tokenRepo repositories.TokenRepository, userID uint, hashedPassword string) error {
+	if err := userRepo.UpdateUser(userID, map[string]interface{}{"password": hashedPassword}); err != nil {
+		return err
+	}
+	return tokenRepo.RevokeAllSessions(userID)
+}
+
 // RegisterUser Register User
 func (s *userService) RegisterUser(name, email, password, phone string) error {
-	hashedPassword, err := hashPassword(password)
+	hashedPassword, err := s.hasher.Hash(password)
 	if err != nil {
 		return err
 	}
@@ -76,23 +198,93 @@ func (s *userService) RegisterUser(name, email, password, phone string) error {
 }
 
 // LoginUser Login User
-func (s *userService) LoginUser(email, password string) (string, string, error) {
+func (s *userService) LoginUser(email, password string, client LoginClient) (string, string, error) {
+	// Locked accounts are refused before the password is checked, so guessing stops working
+	if err := s.guard.Check(email, client); err != nil {
+		return "", "", err
+	}
+
 	user, err := s.userRepo.GetUserByEmail(email)
 	if err != nil {
+		s.guard.RecordFailure(email, nil, client, LoginFailureUnknownEmail)
 		return "", "", errors.New("invalid email or password")
 	}
 
 	if !checkPasswordHash(password, user.Password) {
+		s.guard.RecordFailure(email, &user.ID, client, LoginFailureWrongPassword)
 		return "", "", errors.New("invalid email or password")
 	}
+	// With two-factor authentication the login only succeeds once the code is checked,
+	// otherwise the password would clear the failures of wrong codes
+	if !user.TwoFactorEnabled {
+		s.guard.RecordSuccess(email)
+	}
+	return s.LoginAuthenticatedUser(user)
+}
+
+// LoginAuthenticatedUser logs in a user whose first factor was already checked, by
+// password or an external provider. Two-factor authentication still applies.
+func (s *userService) LoginAuthenticatedUser(user *models.User) (string, string, error) {
+	if !user.IsActive {
+		return "", "", ErrAccountDisabled
+	}
+	if user.TwoFactorEnabled {
+		challenge, _, err := s.generateToken(user, TokenTypeTwoFactorChallenge, "", twoFactorChallengeExpiry)
+		if err != nil {
+			return "", "", err
+		}
+		return "", "", &TwoFactorRequiredError{ChallengeToken: challenge}
+	}
 
-	// Generate Access and Refresh Tokens
-	accessToken, err := s.generateToken(user, accessTokenExpiry)
+	return s.startSession(user)
+}
+
+// CompleteTwoFactorLogin checks the second factor of a login. Wrong codes count as
+// failed logins, and a challenge can only be completed once.
+func (s *userService) CompleteTwoFactorLogin(challengeToken, code string, client LoginClient) (string, string, error) {
+	claims, err := s.VerifyToken(challengeToken)
+	if err != nil || claims.TokenType != TokenTypeTwoFactorChallenge {
+		return "", "", ErrInvalidToken
+	}
+	if err := s.guard.Check(claims.Email, client); err != nil {
+		return "", "", err
+	}
+	revoked, err := s.tokenRepo.IsAccessTokenRevoked(claims.ID)
 	if err != nil {
 		return "", "", err
 	}
+	if revoked {
+		return "", "", ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetUserById(claims.Id)
+	if err != nil || !user.IsActive {
+		return "", "", ErrInvalidToken
+	}
+	if err := s.twoFactor.VerifyCode(user.ID, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.guard.RecordFailure(user.Email, &user.ID, client, LoginFailureWrongCode)
+		}
+		return "", "", err
+	}
 
-	refreshToken, err := s.generateToken(user, refreshTokenExpiry)
+	// The check above only spares used challenges a code check; claiming the
+	// challenge is what makes it single use when requests race
+	claimed, err := s.tokenRepo.ClaimAccessToken(claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return "", "", err
+	}
+	if !claimed {
+		return "", "", ErrInvalidToken
+	}
+	s.guard.RecordSuccess(user.Email)
+	return s.startSession(user)
+}
+
+// startSession issues the tokens of a new login session
+func (s *userService) startSession(user *models.User) (string, string, error) {
+	// Every login starts a new session with its own refresh token family
+	accessToken, refreshToken, err := s.issueTokens(user, uuid.New().String())
 	if err != nil {
 		return "", "", err
 	}
@@ -103,23 +295,120 @@ func (s *userService) LoginUser(email, password string) (string, string, error)
 	return accessToken, refreshToken, nil
 }
 
+// RefreshTokens exchanges a refresh token for a new access and refresh token. The old
+// refresh token is revoked; presenting it again revokes the whole session.
+func (s *userService) RefreshTokens(refreshToken string) (string, string, error) {
+	claims, err := s.VerifyToken(refreshToken)
+	if err != nil || claims.TokenType != TokenTypeRefresh {
+		return "", "", ErrInvalidToken
+	}
+
+	stored, err := s.tokenRepo.FindRefreshToken(claims.ID)
+	if err != nil {
+		return "", "", ErrInvalidToken
+	}
+	if stored.RevokedAt != nil {
+		return "", "", s.revokeReusedFamily(stored)
+	}
+
+	user, err := s.userRepo.GetUserById(stored.UserID)
+	if err != nil {
+		return "", "", ErrInvalidToken
+	}
+	if !user.IsActive {
+		return "", "", ErrAccountDisabled
+	}
+
+	accessToken, newRefreshToken, err := s.issueTokens(user, stored.FamilyID)
+	if err != nil {
+		return "", "", err
+	}
+	newClaims, err := s.VerifyToken(newRefreshToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	rotated, err := s.tokenRepo.RevokeRefreshToken(stored.JTI, newClaims.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if !rotated {
+		// another request rotated this token first
+		return "", "", s.revokeReusedFamily(stored)
+	}
+	return accessToken, newRefreshToken, nil
+}
+
+func (s *userService) revokeReusedFamily(stored *models.RefreshToken) error {
+	slog.Warn("Refresh token reuse detected", "user_id", stored.UserID, "family_id", stored.FamilyID)
+	if err := s.tokenRepo.RevokeFamily(stored.FamilyID); err != nil {
+		return err
+	}
+	return ErrTokenReused
+}
+
+// Logout revokes the given access token and the refresh token session, if one is given
+func (s *userService) Logout(claims *JWTCustomClaims, refreshToken string) error {
+	if claims.ExpiresAt != nil {
+		if err := s.tokenRepo.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	refreshClaims, err := s.VerifyToken(refreshToken)
+	if err != nil || refreshClaims.TokenType != TokenTypeRefresh || refreshClaims.Id != claims.Id {
+		return ErrInvalidToken
+	}
+	return s.tokenRepo.RevokeFamily(refreshClaims.FamilyID)
+}
+
+// issueTokens creates an access token and a refresh token in the given session and
+// records the refresh token so it can be rotated and revoked
+func (s *userService) issueTokens(user *models.User, familyID string) (string, string, error) {
+	accessToken, _, err := s.generateToken(user, TokenTypeAccess, "", accessTokenExpiry)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, claims, err := s.generateToken(user, TokenTypeRefresh, familyID, refreshTokenExpiry)
+	if err != nil {
+		return "", "", err
+	}
+	err = s.tokenRepo.CreateRefreshToken(&models.RefreshToken{
+		UserID:    user.ID,
+		JTI:       claims.ID,
+		FamilyID:  familyID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
 // generateToken Generate JWT Token
-func (s *userService) generateToken(user *models.User, expiry time.Duration) (string, error) {
-	claims := JWTCustomClaims{
+func (s *userService) generateToken(user *models.User, tokenType, familyID string, expiry time.Duration) (string, *JWTCustomClaims, error) {
+	claims := &JWTCustomClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   fmt.Sprintf("%d", user.ID),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ID:        uuid.New().String(),
 		},
-		Role:  user.Role,
-		Name:  user.Name,
-		Email: user.Email,
-		Id:    user.ID,
+		Role:      user.Role,
+		Name:      user.Name,
+		Email:     user.Email,
+		Id:        user.ID,
+		TokenType: tokenType,
+		FamilyID:  familyID,
+		Version:   user.TokenVersion,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	signed, err := s.keys.Sign(claims)
+	return signed, claims, err
 }
 
 func (s *userService) GetUserById(id uint) (*models.PublicUser, error) {
@@ -156,9 +445,7 @@ func (s *userService) GetUserByEmail(email string) (*models.PublicUser, error) {
 
 // VerifyToken Verify token
 func (s *userService) VerifyToken(tokenString string) (*JWTCustomClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	token, err := s.keys.Parse(tokenString, &JWTCustomClaims{})
 
 	if err != nil {
 		return nil, err
@@ -170,10 +457,90 @@ func (s *userService) VerifyToken(tokenString string) (*JWTCustomClaims, error)
 	return claims, nil
 }
 
+func (s *userService) VerifyAccessToken(tokenString string) (*JWTCustomClaims, error) {
+	claims, err := s.VerifyToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeAccess {
+		return nil, ErrInvalidToken
+	}
+	revoked, err := s.tokenRepo.IsAccessTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	user, err := s.userRepo.GetUserById(claims.Id)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.Version != user.TokenVersion {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
 func (s *userService) GetAllUsers() ([]models.PublicUser, error) {
 	return s.userRepo.FindAllUsers()
 }
 
 func (s *userService) UpdateUser(id uint, name, email, phone string) error {
-	return s.userRepo.UpdateUser(id, map[string]interface{}{"name": name, "email": email, "phone": phone})
+	user, err := s.userRepo.GetUserById(id)
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"name": name, "email": email, "phone": phone}
+	// A new address has to be verified again before it unlocks anything
+	if !strings.EqualFold(email, user.Email) {
+		updates["is_email_verified"] = false
+		updates["email_verification_sent_at"] = nil
+	}
+	return s.userRepo.UpdateUser(id, updates)
+}
+
+func (s *userService) ChangePassword(id uint, oldPassword, newPassword string) error {
+	user, err := s.userRepo.GetUserById(id)
+	if err != nil {
+		return err
+	}
+	if !checkPasswordHash(oldPassword, user.Password) {
+		return ErrWrongPassword
+	}
+	return setPassword(s.hasher, s.userRepo, s.tokenRepo, user.ID, newPassword)
+}
+
+func (s *userService) SetUserActive(id uint, active bool) error {
+	if _, err := s.userRepo.GetUserById(id); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateUser(id, map[string]interface{}{"is_active": active}); err != nil {
+		return err
+	}
+	if !active {
+		return s.tokenRepo.RevokeAllSessions(id)
+	}
+	return nil
+}
+
+func (s *userService) ChangeRole(id uint, role string) error {
+	if !roles[role] {
+		return ErrInvalidRole
+	}
+	if _, err := s.userRepo.GetUserById(id); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateUser(id, map[string]interface{}{"role": role}); err != nil {
+		return err
+	}
+	return s.tokenRepo.RevokeAllSessions(id)
+}
+
+func (s *userService) RevokeSessions(id uint) error {
+	if _, err := s.userRepo.GetUserById(id); err != nil {
+		return err
+	}
+	return s.tokenRepo.RevokeAllSessions(id)
 }
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/manjurulhoque/foodie/backend/internal/config"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
+	"github.com/manjurulhoque/foodie/backend/internal/repositories"
+)
+
+var testClient = LoginClient{IP: "192.0.2.1", UserAgent: "test"}
+
+// testHasher keeps password hashing fast in tests
+var testHasher = NewPasswordHasher(bcrypt.MinCost)
+
+func setupUserTest(t *testing.T) (UserService, *gorm.DB) {
+	db := newTestDB(t)
+	keys, err := NewKeySet(config.JWTConfig{Algorithm: "HS256", KeyID: "test", Secret: "test-secret"})
+	require.NoError(t, err)
+	guard := NewLoginGuard(NewMemoryAttemptStore(), repositories.NewLoginFailureRepository(db), config.LoginProtectionConfig{
+		MaxAccountFailures: 3,
+		MaxIPFailures:      5,
+		BaseLockout:        time.Minute,
+		MaxLockout:         time.Hour,
+		FailureWindow:      15 * time.Minute,
+	})
+	twoFactor := NewTwoFactorService(repositories.NewTwoFactorRepository(db), config.TwoFactorConfig{Issuer: "Foodie", RequiredRoles: []string{models.RoleAdmin}})
+	service := NewUserService(repositories.NewUserRepository(db), repositories.NewTokenRepository(db), keys, guard, twoFactor, testHasher)
+
+	password, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	db.Create(&models.User{Name: "Jane", Email: "jane@example.com", Password: string(password), Phone: "1", Role: models.RoleCustomer})
+	return service, db
+}
+
+func TestTokenTypes(t *testing.T) {
+	service, _ := setupUserTest(t)
+
+	access, refresh, err := service.LoginUser("jane@example.com", "secret123", testClient)
+	require.NoError(t, err)
+
+	claims, err := service.VerifyAccessToken(access)
+	require.NoError(t, err)
+	assert.Equal(t, TokenTypeAccess, claims.TokenType)
+
+	// a refresh token must not work as an access token
+	_, err = service.VerifyAccessToken(refresh)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// and an access token must not be usable to refresh
+	_, _, err = service.RefreshTokens(access)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	service, _ := setupUserTest(t)
+
+	_, refresh, err := service.LoginUser("jane@example.com", "secret123", testClient)
+	require.NoError(t, err)
+
+	access2, refresh2, err := service.RefreshTokens(refresh)
+	require.NoError(t, err)
+	assert.NotEqual(t, refresh, refresh2)
+	_, err = service.VerifyAccessToken(access2)
+	assert.NoError(t, err)
+
+	// replaying the rotated token revokes the whole session, including the newest token
+	_, _, err = service.RefreshTokens(refresh)
+	assert.ErrorIs(t, err, ErrTokenReused)
+	_, _, err = service.RefreshTokens(refresh2)
+	assert.ErrorIs(t, err, ErrTokenReused)
+
+	// other sessions are untouched
+	_, otherRefresh, err := service.LoginUser("jane@example.com", "secret123", testClient)
+	require.NoError(t, err)
+	_, _, err = service.RefreshTokens(otherRefresh)
+	assert.NoError(t, err)
+}
+
+func TestLogout(t *testing.T) {
+	service, _ := setupUserTest(t)
+
+	access, refresh, err := service.LoginUser("jane@example.com", "secret123", testClient)
+	require.NoError(t, err)
+	claims, err := service.VerifyAccessToken(access)
+	require.NoError(t, err)
+
+	require.NoError(t, service.Logout(claims, refresh))
+
+	_, err = service.VerifyAccessToken(access)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	_, _, err = service.RefreshTokens(refresh)
+	assert.Error(t, err)
+}
+
+func TestChangePassword(t *testing.T) {
+	service, _ := setupUserTest(t)
+
+	access, refresh, err := service.LoginUser("jane@example.com", "secret123", testClient)
+	require.NoError(t, err)
+	claims, err := service.VerifyAccessToken(access)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, service.ChangePassword(claims.Id, "wrong", "newsecret"), ErrWrongPassword)
+	require.NoError(t, service.ChangePassword(claims.Id, "secret123", "newsecret"))
+
+	// every existing session ends
+	_, err = service.VerifyAccessToken(access)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	_, _, err = service.RefreshTokens(refresh)
+	assert.Error(t, err)
+
+	_, _, err = service.LoginUser("jane@example.com", "secret123", testClient)
+	assert.Error(t, err)
+	access, _, err = service.LoginUser("jane@example.com", "newsecret", testClient)
+	require.NoError(t, err)
+	_, err = service.VerifyAccessToken(access)
+	assert.NoError(t, err)
+}
+
+func TestUpdateUserEmailResetsVerification(t *testing.T) {
+	service, db := setupUserTest(t)
+	var user models.User
+	db.Where("email = ?", "jane@example.com").First(&user)
+	sentAt := time.Now()
+	db.Model(&user).Updates(map[string]interface{}{"is_email_verified": true, "email_verification_sent_at": &sentAt})
+
+	require.NoError(t, service.UpdateUser(user.ID, "Jane Doe", "jane@example.com", "2"))
+	db.First(&user, user.ID)
+	assert.True(t, user.IsEmailVerified, "keeping the address keeps it verified")
+
+	require.NoError(t, service.UpdateUser(user.ID, "Jane Doe", "jane.doe@example.com", "2"))
+	var updated models.User
+	db.First(&updated, user.ID)
+	assert.Equal(t, "jane.doe@example.com", updated.Email)
+	assert.False(t, updated.IsEmailVerified)
+	assert.Nil(t, updated.EmailVerificationSentAt)
+}
+
+func TestDeactivateUser(t *testing.T) {
+	service, db := setupUserTest(t)
+	var user models.User
+	db.Where("email = ?", "jane@example.com").First(&user)
+
+	access, refresh, err := service.LoginUser("jane@example.com", "secret123", testClient)
+	require.NoError(t, err)
+
+	require.NoError(t, service.SetUserActive(user.ID, false))
+
+	_, err = service.VerifyAccessToken(access)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	_, _, err = service.RefreshTokens(refresh)
+	assert.Error(t, err)
+	_, _, err = service.LoginUser("jane@example.com", "secret123", testClient)
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+	_, _, err = service.LoginUser("jane@example.com", "wrong", testClient)
+	assert.NotErrorIs(t, err, ErrAccountDisabled, "the account state is only revealed to the owner")
+
+	require.NoError(t, service.SetUserActive(user.ID, true))
+	_, _, err = service.LoginUser("jane@example.com", "secret123", testClient)
+	assert.NoError(t, err)
+}
+
+func TestChangeRole(t *testing.T) {
+	service, db := setupUserTest(t)
+	var user models.User
+	db.Where("email = ?", "jane@example.com").First(&user)
+
+	access, _, err := service.LoginUser("jane@example.com", "secret123", testClient)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, service.ChangeRole(user.ID, "superuser"), ErrInvalidRole)
+	require.NoError(t, service.ChangeRole(user.ID, models.RoleRestaurantOwner))
+
+	db.First(&user, user.ID)
+	assert.Equal(t, models.RoleRestaurantOwner, user.Role)
+	_, err = service.VerifyAccessToken(access)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+
+	assert.Error(t, service.ChangeRole(9999, models.RoleAdmin))
+}
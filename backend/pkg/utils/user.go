@@ -2,6 +2,7 @@ package utils
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/manjurulhoque/foodie/backend/internal/models"
 )
 
 // GetUserID extracts the user ID from the gin context
@@ -13,3 +14,14 @@ func GetUserID(c *gin.Context) uint {
 	}
 	return userID.(uint)
 }
+
+// GetUser extracts the authenticated user from the gin context
+// This is set by the auth middleware after validating the token
+func GetUser(c *gin.Context) *models.User {
+	user, exists := c.Get("user")
+	if !exists {
+		return nil
+	}
+	authUser, _ := user.(*models.User)
+	return authUser
+}
@@ -57,7 +57,7 @@ export default function OwnerOrdersPage() {
                         </TableHeader>
                         <TableBody>
                             {isLoading && <TableRow><TableCell colSpan={7} className="h-24 text-center">Loading...</TableCell></TableRow>}
-                            {!isLoading && orders?.data?.map((order) => (
+                            {!isLoading && orders?.data?.data?.map((order) => (
                                 <TableRow key={order.id}>
                                     <TableCell>#{order.id}</TableCell>
                                     <TableCell>
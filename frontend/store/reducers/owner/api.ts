@@ -13,7 +13,13 @@ export const OwnerApi = createApi({
         getOwnerRestaurants: builder.query<Response<Restaurant[]>, void>({
             query: () => "/owner/restaurants",
         }),
-        getOwnerOrders: builder.query<Response<Order[]>, void>({
+        getOwnerOrders: builder.query<
+            Response<{
+                data: Order[];
+                meta: { total: number; page: number; limit: number; totalPages: number };
+            }>,
+            void
+        >({
             query: () => "/owner/orders",
         }),
         updateOrderStatus: builder.mutation<Response<Order>, { id: number; status: string; payment_status: string }>({